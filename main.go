@@ -1,9 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/sters/go-file-splitter/splitter"
 )
@@ -19,13 +24,99 @@ func main() {
 		showVersion    bool
 		publicFunc     bool
 		testOnly       bool
+		includeTests   bool
 		methodStrategy string
+		abbreviations  string
+		maxDepth       int
+		groupVars      bool
+		keepLineDirs   bool
+		splitIfaces    bool
+		splitTypes     bool
+		noCommonFile   bool
+		groupByParam   bool
+		groupByType    bool
+		inclPrivate    bool
+		moveHelpers    bool
+		provenance     bool
+		license        bool
+		docFile        bool
+		numberPrefix   bool
+		mergeTarget    string
+		includeVendor  bool
+		processGen     bool
+		minimalDiff    bool
+		minFunctions   int
+		maxFiles       int
+		groupTests     bool
+		jsonOutput     bool
+		dryRun         bool
+		check          bool
+		colocateTests  bool
+		scaffoldTests  bool
+		consolidate    bool
+		include        string
+		exclude        string
+		includeGlob    string
+		excludeGlob    string
+		keepGoing      bool
+		moveMarker     bool
+		keepOriginal   bool
+		verbose        bool
+		verify         bool
+		singleFile     bool
+		sortDecls      bool
+		testSuffix     string
+		formatter      string
+		stdoutArchive  bool
 	)
 
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
 	flag.BoolVar(&publicFunc, "public-func", true, "Split public functions into individual files (default)")
 	flag.BoolVar(&testOnly, "test", false, "Split only test functions")
-	flag.StringVar(&methodStrategy, "method-strategy", "separate", "Strategy for methods: 'separate' (individual files) or 'with-struct' (keep with struct)")
+	flag.BoolVar(&includeTests, "include-tests", false, "Split public functions, then the test functions left in other test files, in one pass")
+	flag.StringVar(&methodStrategy, "method-strategy", "separate", "Strategy for methods: 'separate' (individual files), 'with-struct' (keep with struct) or 'mixed' (exported methods in individual files, unexported ones in <type>_private.go)")
+	flag.StringVar(&abbreviations, "abbrev", "", "Comma-separated additional abbreviations kept together in file names (e.g. ACL,SKU,CIDR)")
+	flag.IntVar(&maxDepth, "max-depth", -1, "Maximum directory depth to walk below the target directory (0 = only the target, -1 = unlimited)")
+	flag.BoolVar(&groupVars, "group-vars-by-block", false, "Write each public var/const block to its own file instead of common.go")
+	flag.BoolVar(&keepLineDirs, "keep-line-directives", false, "Skip files containing //line directives instead of reformatting them")
+	flag.BoolVar(&splitIfaces, "split-interfaces", false, "Write each public interface to its own file instead of common.go")
+	flag.BoolVar(&noCommonFile, "no-common-file", false, "Write each public type and var/const block to its own file instead of gathering them in common.go")
+	flag.BoolVar(&splitTypes, "split-types", false, "Write each public type to its own type_<name>.go file instead of common.go (separate strategy)")
+	flag.BoolVar(&groupByParam, "group-by-first-param", false, "Group functions into files named after the local type of their first parameter")
+	flag.BoolVar(&groupByType, "group-by-type", false, "Write each type with its constructors and methods to <type>.go, whatever the method strategy")
+	flag.BoolVar(&inclPrivate, "include-private", false, "Also split unexported functions into individual files")
+	flag.BoolVar(&moveHelpers, "move-exclusive-helpers", false, "Move private functions used by only one extracted function into its file")
+	flag.BoolVar(&provenance, "provenance", false, "Start each generated file with a comment naming the file and declaration it was split from")
+	flag.BoolVar(&license, "license-header", false, "Start each generated file with the license or copyright comment block heading the file it was split from")
+	flag.BoolVar(&numberPrefix, "number-prefix", false, "Start each function file name with the function's zero-padded position in its source file (01_parse.go)")
+	flag.BoolVar(&docFile, "doc-file", false, "Move the package doc comment of a split file to doc.go, with a comment listing the file's declarations in their original order")
+	flag.BoolVar(&groupTests, "group-tests-by-prefix", false, "Write tests sharing their first name segment (TestUserCreate, TestUserDelete) into one file")
+	flag.IntVar(&minFunctions, "min-functions", 1, "Leave files with fewer public functions (and no declarations or methods) untouched")
+	flag.IntVar(&maxFiles, "max-files", 0, "Gather functions in files by alphabet range (a_i.go, j_r.go, ...) when a file per function would leave more Go files than this in a directory (0 = no limit)")
+	flag.BoolVar(&processGen, "process-generated", false, "Also split generated files (marked '// Code generated ... DO NOT EDIT.'), which are skipped by default")
+	flag.BoolVar(&minimalDiff, "minimal-diff", false, "Only cut the moved code out of the original files, leaving the rest exactly as written instead of reformatting it")
+	flag.BoolVar(&includeVendor, "include-vendor", false, "Also process vendor directories (testdata and hidden directories are always skipped)")
+	flag.BoolVar(&colocateTests, "colocate-tests", true, "Move the tests of each split function into <name>_test.go (set to false to leave test files alone)")
+	flag.BoolVar(&singleFile, "single-file", false, "Write all split functions of a package into one public.go instead of a file per function")
+	flag.BoolVar(&sortDecls, "sort-declarations", false, "Order the contents of common.go, with-struct type files and grouped test files: types, consts, vars, functions, then methods by name")
+	flag.BoolVar(&scaffoldTests, "scaffold-tests", false, "Write a skipped Test<Name> stub to <name>_test.go for each split public function without a test")
+	flag.BoolVar(&consolidate, "consolidate-test-helpers", false, "Move the helpers left in the test file of a split file to testhelpers_test.go, so the test file can be deleted")
+	flag.StringVar(&testSuffix, "test-suffix", "_test.go", "Suffix of generated test file names (e.g. _internal_test.go); -test only splits test files ending in it")
+	flag.StringVar(&formatter, "formatter", "", "Command each written file is piped through after gofmt, e.g. 'gofumpt' or 'goimports -local example.com'")
+	flag.StringVar(&include, "include", "", "Only split functions whose name matches this regular expression (e.g. '^(Handle|Serve)')")
+	flag.StringVar(&exclude, "exclude", "", "Leave functions whose name matches this regular expression in the original file")
+	flag.StringVar(&includeGlob, "include-glob", "", "Only process files whose base name matches this glob (e.g. '*_handler.go')")
+	flag.StringVar(&excludeGlob, "exclude-glob", "", "Leave files whose base name matches this glob untouched (e.g. 'zz_*.go')")
+	flag.BoolVar(&keepGoing, "continue-on-error", false, "Skip files that fail to parse or split, with a warning, and report their errors at the end")
+	flag.BoolVar(&moveMarker, "leave-move-marker", false, "Leave a '// Moved to <file>' comment in the original file where each moved function used to be")
+	flag.BoolVar(&keepOriginal, "keep-original", false, "Write the split files but leave the files they were split from unchanged (for migrating by hand)")
+	flag.BoolVar(&verbose, "verbose", false, "Explain for each comment next to a split function whether it moves along, and why")
+	flag.BoolVar(&verify, "verify", false, "Type-check the changed packages after splitting and fail when the split files don't compile (slower)")
+	flag.BoolVar(&jsonOutput, "json", false, "Print the run's result as JSON to stdout (progress goes to stderr)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print what would be created, updated and deleted without changing any file")
+	flag.BoolVar(&check, "check", false, "Like -dry-run, but exit non-zero when splitting would change anything (for CI)")
+	flag.BoolVar(&stdoutArchive, "stdout-archive", false, "Read the file named by the argument from stdin and write every resulting file to stdout as a txtar archive, changing nothing on disk")
+	flag.StringVar(&mergeTarget, "merge", "", "Merge the package's non-test files in the directory back into the given file instead of splitting")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <directory>\n", os.Args[0])
@@ -55,22 +146,171 @@ func main() {
 		publicFunc = false
 	}
 
-	var err error
-	if publicFunc {
-		var strategy splitter.MethodStrategy
-		switch methodStrategy {
-		case "with-struct":
-			strategy = splitter.MethodStrategyWithStruct
-		default:
-			strategy = splitter.MethodStrategySeparate
+	opts := splitter.Options{
+		MethodStrategy:         splitter.MethodStrategySeparate,
+		GroupVarsByBlock:       groupVars,
+		KeepLineDirectives:     keepLineDirs,
+		SplitInterfaces:        splitIfaces,
+		SplitTypes:             splitTypes,
+		NoCommonFile:           noCommonFile,
+		GroupByFirstParam:      groupByParam,
+		GroupByType:            groupByType,
+		IncludePrivate:         inclPrivate,
+		MoveExclusiveHelpers:   moveHelpers,
+		AddProvenance:          provenance,
+		LicenseHeader:          license,
+		DocFile:                docFile,
+		NumberPrefix:           numberPrefix,
+		IncludeGlob:            includeGlob,
+		ExcludeGlob:            excludeGlob,
+		IncludeVendor:          includeVendor,
+		ProcessGenerated:       processGen,
+		MinimalDiff:            minimalDiff,
+		MinFunctionsToSplit:    minFunctions,
+		MaxFilesPerDirectory:   maxFiles,
+		GroupTestsByPrefix:     groupTests,
+		SkipCorrespondingTests: !colocateTests,
+		ScaffoldMissingTests:   scaffoldTests,
+		ConsolidateTestHelpers: consolidate,
+		SingleFile:             singleFile,
+		SortDeclarations:       sortDecls,
+		TestFileSuffix:         testSuffix,
+		LeaveMoveMarker:        moveMarker,
+		KeepOriginal:           keepOriginal,
+		ContinueOnError:        keepGoing,
+		Verbose:                verbose,
+		Verify:                 verify,
+		DryRun:                 dryRun,
+		Check:                  check,
+		Formatter:              splitter.CommandFormatter(formatter),
+		Result:                 &splitter.SplitResult{},
+	}
+	if jsonOutput {
+		opts.Output = os.Stderr
+	}
+	switch methodStrategy {
+	case "with-struct":
+		opts.MethodStrategy = splitter.MethodStrategyWithStruct
+	case "mixed":
+		opts.MethodStrategy = splitter.MethodStrategyMixed
+	}
+	if abbreviations != "" {
+		opts.Abbreviations = strings.Split(abbreviations, ",")
+	}
+	if maxDepth >= 0 {
+		opts.MaxDepth = &maxDepth
+	}
+	if include != "" {
+		pattern, err := regexp.Compile(include)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -include pattern: %v\n", err)
+			os.Exit(1)
 		}
-		err = splitter.SplitPublicFunctions(directory, strategy)
+		opts.IncludePattern = pattern
+	}
+	if exclude != "" {
+		pattern, err := regexp.Compile(exclude)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -exclude pattern: %v\n", err)
+			os.Exit(1)
+		}
+		opts.ExcludePattern = pattern
+	}
+	for name, glob := range map[string]string{"include-glob": includeGlob, "exclude-glob": excludeGlob} {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -%s pattern: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+
+	// A config file in the directory or above it fills in the flags not given
+	configDir := directory
+	if stdoutArchive {
+		configDir = filepath.Dir(directory)
+	}
+	if err := applyConfig(configDir, &opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if stdoutArchive {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read stdin: %v\n", err)
+			os.Exit(1)
+		}
+		files, err := splitter.SplitSource(directory, src, opts)
+		if err == nil {
+			err = splitter.WriteArchive(os.Stdout, files)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	var err error
+	if mergeTarget != "" {
+		err = splitter.MergePackage(directory, mergeTarget, opts)
+	} else if publicFunc && includeTests {
+		err = splitter.SplitAll(directory, opts)
+	} else if publicFunc {
+		err = splitter.SplitPublicFunctions(directory, opts)
 	} else {
-		err = splitter.SplitTestFunctions(directory)
+		err = splitter.SplitTestFunctions(directory, opts)
 	}
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	if mergeTarget != "" {
+		return
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(opts.Result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+	if dryRun || check {
+		fmt.Println("Dry run: " + opts.Result.Summary())
+
+		return
+	}
+	fmt.Println(opts.Result.Summary())
+}
+
+// applyConfig applies the nearest config file of directory to opts, skipping
+// the options whose flag was given on the command line.
+func applyConfig(directory string, opts *splitter.Options) error {
+	path, err := splitter.FindConfig(directory)
+	if err != nil || path == "" {
+		return err
+	}
+
+	cfg, err := splitter.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	return cfg.Apply(opts, func(key string) bool {
+		if key == "abbreviations" {
+			return setFlags["abbrev"]
+		}
+
+		return setFlags[strings.ReplaceAll(key, "_", "-")]
+	})
 }
@@ -0,0 +1,334 @@
+package splitter
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SymbolKind is the kind of a public symbol found by AnalyzePackage.
+type SymbolKind string
+
+const (
+	SymbolFunction SymbolKind = "function"
+	SymbolMethod   SymbolKind = "method"
+	SymbolType     SymbolKind = "type"
+	SymbolConst    SymbolKind = "const"
+	SymbolVar      SymbolKind = "var"
+)
+
+// FilePlan lists the public symbols of a source file and where splitting
+// would write them, ordered by target.
+type FilePlan struct {
+	File    string       `json:"file"`
+	Symbols []SymbolPlan `json:"symbols"`
+
+	// buildSuffix ends the names of the files split from File, see
+	// splitFileSuffix.
+	buildSuffix string
+	// commonFile is the file the public declarations of File's package are
+	// gathered in, see commonFileFor.
+	commonFile string
+}
+
+// SymbolPlan describes where one public symbol would be written.
+type SymbolPlan struct {
+	// Name is the symbol's name. Methods are named Type.Method, and a
+	// const/var block by its first public name.
+	Name string     `json:"name"`
+	Kind SymbolKind `json:"kind"`
+	// SnakeName is the snake_case form of Name the target is derived from.
+	SnakeName string `json:"snakeName"`
+	// Target is the path of the file the symbol would be written to. It is
+	// the source file itself when the symbol already lives in its own file.
+	Target string `json:"target"`
+	// Collision reports that Target is claimed by an unrelated symbol or is an
+	// existing file other than the source, so splitting would mix or
+	// overwrite their code. Shared files like common.go are not collisions.
+	Collision bool `json:"collision"`
+}
+
+// AnalyzePackage reports, for each non-test Go file in directory, the public
+// functions, methods and declarations splitting would move and the files
+// they would be written to. Nothing is written.
+func AnalyzePackage(directory string, opts Options) ([]FilePlan, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var plans []FilePlan
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		filename := filepath.Join(directory, name)
+		plan, err := analyzeFile(filename, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", filename, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	markCollisions(plans)
+
+	return plans, nil
+}
+
+// IsSplit reports whether filename already has the layout splitting leaves
+// behind, so splitting it would move nothing: its public function is in the
+// file named after it, or, as with-struct writes them, its type is there with
+// its constructors and methods. Private helpers may stay alongside. A test
+// file is split when each of its tests is in the file named after it.
+func IsSplit(filename string) (bool, error) {
+	filename = filepath.Clean(filename)
+	if strings.HasSuffix(filename, "_test.go") {
+		return isTestFileSplit(filename)
+	}
+
+	for _, strategy := range []MethodStrategy{MethodStrategySeparate, MethodStrategyWithStruct} {
+		plan, err := analyzeFile(filename, Options{MethodStrategy: strategy})
+		if err != nil {
+			return false, err
+		}
+		if !slices.ContainsFunc(plan.Symbols, func(symbol SymbolPlan) bool {
+			return symbol.Target != filename
+		}) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isTestFileSplit reports whether every test in the test file filename is
+// named after it, like TestParse in parse_test.go.
+func isTestFileSplit(filename string) (bool, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	opts := Options{buildSuffix: splitFileSuffix(filename, buildConstraintLines(node))}
+	for _, test := range extractTestFunctions(node, fset) {
+		if opts.withBuildSuffix(opts.testBaseName(test.Name)+"_test.go") != filepath.Base(filename) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// analyzeFile plans the symbols of one file the way processGoFile would write
+// them, leaving Collision for markCollisions.
+func analyzeFile(filename string, opts Options) (FilePlan, error) {
+	plan := FilePlan{File: filename}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return plan, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	// Files that splitting skips have nothing to move
+	if findCgoImportDecl(node.Decls) != nil || (opts.KeepLineDirectives && hasLineDirective(node)) {
+		return plan, nil
+	}
+
+	opts.buildSuffix = splitFileSuffix(filename, buildConstraintLines(node))
+	plan.buildSuffix = opts.buildSuffix
+	outputDir := filepath.Dir(filename)
+	target := func(snakeName string) string {
+		return filepath.Join(outputDir, opts.goFileName(snakeName))
+	}
+	snake := func(name string) string {
+		return opts.fileBaseName(name)
+	}
+
+	packageTypes, err := packageTypeNames(filename, node)
+	if err != nil {
+		return plan, err
+	}
+	opts.typeBaseNames = opts.collidingTypeBaseNames(packageTypes)
+
+	publicFuncs := extractPublicFunctions(node, opts, fset)
+	publicDecls := extractPublicDeclarations(node)
+	publicMethods := extractPublicMethods(node, fset)
+	if !opts.SingleFile {
+		if opts.buckets, err = opts.bucketCount(filename, len(publicFuncs)); err != nil {
+			return plan, err
+		}
+	}
+	withStruct := opts.groupsByType()
+
+	// Types declared here get a file of their own under with-struct
+	typeNames := make(map[string]bool)
+	for _, decl := range publicDecls {
+		for _, spec := range decl.GenDecl.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok {
+				typeNames[ts.Name.Name] = true
+			}
+		}
+	}
+
+	// Functions that end up in another symbol's file
+	groupedInto := make(map[string]string)
+	if withStruct {
+		for typeName, fns := range findConstructors(publicFuncs, publicDecls) {
+			for _, fn := range fns {
+				groupedInto[fn.Name] = typeName
+			}
+		}
+	}
+	if opts.GroupByFirstParam {
+		var ungrouped []PublicFunction
+		for _, fn := range publicFuncs {
+			if _, grouped := groupedInto[fn.Name]; !grouped {
+				ungrouped = append(ungrouped, fn)
+			}
+		}
+		for typeName, fns := range groupByFirstParam(ungrouped) {
+			for _, fn := range fns {
+				groupedInto[fn.Name] = typeName
+			}
+		}
+	}
+
+	for _, fn := range publicFuncs {
+		symbol := SymbolPlan{Name: fn.Name, Kind: SymbolFunction, SnakeName: snake(fn.Name)}
+		switch typeName, grouped := groupedInto[fn.Name]; {
+		case opts.SingleFile:
+			symbol.Target = filepath.Join(outputDir, opts.withBuildSuffix(singleFileName))
+		case grouped:
+			symbol.Target = target(opts.typeBaseName(typeName))
+		default:
+			symbol.Target = filepath.Join(outputDir, opts.functionFileName(filename, node, fn.FuncDecl))
+		}
+		plan.Symbols = append(plan.Symbols, symbol)
+	}
+
+	aliases := aliasOwners(publicDecls)
+	for _, method := range publicMethods {
+		symbol := SymbolPlan{
+			Name:      method.ReceiverType + "." + method.Name,
+			Kind:      SymbolMethod,
+			SnakeName: opts.methodBaseName(method.ReceiverType, method.Name),
+		}
+		symbol.Target = target(symbol.SnakeName)
+		if alias, ok := aliases[method.ReceiverType]; withStruct && ok {
+			symbol.Target = target(opts.typeBaseName(alias))
+		} else if withStruct && typeNames[method.ReceiverType] {
+			symbol.Target = target(opts.typeBaseName(method.ReceiverType))
+		}
+		plan.Symbols = append(plan.Symbols, symbol)
+	}
+
+	// Under the mixed strategy, unexported methods go to their type's private file
+	if opts.MethodStrategy == MethodStrategyMixed {
+		for _, method := range extractPrivateMethods(node, fset) {
+			plan.Symbols = append(plan.Symbols, SymbolPlan{
+				Name:      method.ReceiverType + "." + method.Name,
+				Kind:      SymbolMethod,
+				SnakeName: opts.methodBaseName(method.ReceiverType, method.Name),
+				Target:    filepath.Join(outputDir, opts.privateMethodsFileName(method.ReceiverType)),
+			})
+		}
+	}
+
+	commonFile, err := opts.commonFileFor(outputDir, node.Name.Name)
+	if err != nil {
+		return plan, err
+	}
+	plan.commonFile = commonFile
+	for _, decl := range publicDecls {
+		if decl.GenDecl.Tok == token.TYPE {
+			for _, spec := range decl.GenDecl.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok || !isPublicName(ts.Name.Name) {
+					continue
+				}
+
+				symbol := SymbolPlan{Name: ts.Name.Name, Kind: SymbolType, SnakeName: opts.typeBaseName(ts.Name.Name)}
+				_, isInterface := ts.Type.(*ast.InterfaceType)
+				switch {
+				case withStruct || (opts.SplitInterfaces && isInterface):
+					symbol.Target = target(symbol.SnakeName)
+				case opts.SplitTypes:
+					symbol.Target = target("type_" + symbol.SnakeName)
+				case opts.NoCommonFile:
+					symbol.Target = target(symbol.SnakeName)
+				default:
+					symbol.Target = commonFile
+				}
+				plan.Symbols = append(plan.Symbols, symbol)
+			}
+
+			continue
+		}
+
+		name := firstPublicName(decl.GenDecl)
+		symbol := SymbolPlan{Name: name, Kind: SymbolVar, SnakeName: snake(name), Target: commonFile}
+		if decl.GenDecl.Tok == token.CONST {
+			symbol.Kind = SymbolConst
+		}
+		if typeName := associatedTypeName(decl.GenDecl, typeNames); withStruct && typeName != "" {
+			symbol.Target = target(opts.typeBaseName(typeName))
+		} else if opts.GroupVarsByBlock || opts.NoCommonFile {
+			symbol.Target = target(symbol.SnakeName)
+		}
+		plan.Symbols = append(plan.Symbols, symbol)
+	}
+
+	sort.SliceStable(plan.Symbols, func(i, j int) bool {
+		return plan.Symbols[i].Target < plan.Symbols[j].Target
+	})
+
+	return plan, nil
+}
+
+// markCollisions flags symbols whose target is named after more than one
+// symbol, like GetURL and GetUrl both writing get_url.go, or is an existing
+// file they don't come from. Symbols grouped into another symbol's file, such
+// as methods into their type's file under with-struct, and the shared
+// common.go and public.go don't collide.
+func markCollisions(plans []FilePlan) {
+	namedAfter := make(map[string]map[string]bool)
+	for _, plan := range plans {
+		opts := Options{buildSuffix: plan.buildSuffix}
+		for _, symbol := range plan.Symbols {
+			if filepath.Base(symbol.Target) != opts.goFileName(symbol.SnakeName) {
+				continue
+			}
+			if namedAfter[symbol.Target] == nil {
+				namedAfter[symbol.Target] = make(map[string]bool)
+			}
+			namedAfter[symbol.Target][string(symbol.Kind)+":"+symbol.Name] = true
+		}
+	}
+
+	for _, plan := range plans {
+		opts := Options{buildSuffix: plan.buildSuffix}
+		for i, symbol := range plan.Symbols {
+			if opts.isSharedFile(symbol.Target) || symbol.Target == plan.commonFile || symbol.Target == plan.File {
+				continue
+			}
+			_, err := os.Stat(symbol.Target)
+			plan.Symbols[i].Collision = len(namedAfter[symbol.Target]) > 1 || err == nil
+		}
+	}
+}
+
+func (opts Options) isSharedFile(filename string) bool {
+	base := filepath.Base(filename)
+
+	return base == opts.withBuildSuffix(commonFileName) || base == opts.withBuildSuffix(singleFileName)
+}
@@ -0,0 +1,221 @@
+package splitter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyzePackage(t *testing.T) {
+	files := map[string]string{
+		"service.go": `package service
+
+import "time"
+
+// Timeout is the default timeout.
+const Timeout = time.Second
+
+// Server serves.
+type Server struct{}
+
+// NewServer creates a Server.
+func NewServer() *Server { return &Server{} }
+
+// Start starts the server.
+func (s *Server) Start() {}
+
+// GetURL returns the URL.
+func GetURL() string { return "" }
+
+func helper() {}
+`,
+		"legacy.go": `package service
+
+// GetUrl returns the URL, too.
+func GetUrl() string { return "" }
+
+// Legacy is already in its own file.
+func Legacy() {}
+`,
+		"service_test.go": `package service
+
+import "testing"
+
+func TestGetURL(t *testing.T) {}
+`,
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected map[string][]SymbolPlan
+	}{
+		{
+			name: "separate strategy",
+			opts: Options{},
+			expected: map[string][]SymbolPlan{
+				"legacy.go": {
+					{Name: "GetUrl", Kind: SymbolFunction, SnakeName: "get_url", Target: "get_url.go", Collision: true},
+					{Name: "Legacy", Kind: SymbolFunction, SnakeName: "legacy", Target: "legacy.go"},
+				},
+				"service.go": {
+					{Name: "Timeout", Kind: SymbolConst, SnakeName: "timeout", Target: "common.go"},
+					{Name: "Server", Kind: SymbolType, SnakeName: "server", Target: "common.go"},
+					{Name: "GetURL", Kind: SymbolFunction, SnakeName: "get_url", Target: "get_url.go", Collision: true},
+					{Name: "NewServer", Kind: SymbolFunction, SnakeName: "new_server", Target: "new_server.go"},
+					{Name: "Server.Start", Kind: SymbolMethod, SnakeName: "server_start", Target: "server_start.go"},
+				},
+			},
+		},
+		{
+			name: "with-struct strategy",
+			opts: Options{MethodStrategy: MethodStrategyWithStruct},
+			expected: map[string][]SymbolPlan{
+				"legacy.go": {
+					{Name: "GetUrl", Kind: SymbolFunction, SnakeName: "get_url", Target: "get_url.go", Collision: true},
+					{Name: "Legacy", Kind: SymbolFunction, SnakeName: "legacy", Target: "legacy.go"},
+				},
+				"service.go": {
+					{Name: "Timeout", Kind: SymbolConst, SnakeName: "timeout", Target: "common.go"},
+					{Name: "GetURL", Kind: SymbolFunction, SnakeName: "get_url", Target: "get_url.go", Collision: true},
+					{Name: "NewServer", Kind: SymbolFunction, SnakeName: "new_server", Target: "server.go"},
+					{Name: "Server.Start", Kind: SymbolMethod, SnakeName: "server_start", Target: "server.go"},
+					{Name: "Server", Kind: SymbolType, SnakeName: "server", Target: "server.go"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plans, err := AnalyzePackage(tmpDir, tt.opts)
+			if err != nil {
+				t.Fatalf("AnalyzePackage failed: %v", err)
+			}
+
+			if len(plans) != len(tt.expected) {
+				t.Fatalf("Expected %d file plans, got %d: %+v", len(tt.expected), len(plans), plans)
+			}
+			for _, plan := range plans {
+				expected, ok := tt.expected[filepath.Base(plan.File)]
+				if !ok {
+					t.Errorf("Unexpected plan for %s", plan.File)
+
+					continue
+				}
+				if len(plan.Symbols) != len(expected) {
+					t.Errorf("%s: expected %d symbols, got %+v", plan.File, len(expected), plan.Symbols)
+
+					continue
+				}
+				for i, want := range expected {
+					want.Target = filepath.Join(tmpDir, want.Target)
+					if plan.Symbols[i] != want {
+						t.Errorf("%s symbol %d = %+v, want %+v", plan.File, i, plan.Symbols[i], want)
+					}
+				}
+			}
+
+			// Analysis must not touch the directory
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(files) {
+				t.Errorf("AnalyzePackage should not write files, found %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestIsSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     bool
+	}{
+		{
+			name:     "function in its own file",
+			filename: "parse_config.go",
+			content:  "package p\n\n// ParseConfig parses.\nfunc ParseConfig() { trim() }\n\nfunc trim() {}\n",
+			want:     true,
+		},
+		{
+			name:     "function in another file",
+			filename: "config.go",
+			content:  "package p\n\nfunc ParseConfig() {}\n",
+			want:     false,
+		},
+		{
+			name:     "two public functions",
+			filename: "parse.go",
+			content:  "package p\n\nfunc Parse() {}\n\nfunc Format() {}\n",
+			want:     false,
+		},
+		{
+			name:     "type with constructor and methods",
+			filename: "server.go",
+			content:  "package p\n\ntype Server struct{}\n\nfunc NewServer() *Server { return &Server{} }\n\nfunc (s *Server) Start() {}\n",
+			want:     true,
+		},
+		{
+			name:     "type with methods in another file",
+			filename: "types.go",
+			content:  "package p\n\ntype Server struct{}\n\nfunc (s *Server) Start() {}\n",
+			want:     false,
+		},
+		{
+			name:     "declarations in common.go",
+			filename: "common.go",
+			content:  "package p\n\nconst Version = \"1\"\n\nvar Default = 1\n",
+			want:     true,
+		},
+		{
+			name:     "only private code",
+			filename: "helpers.go",
+			content:  "package p\n\nfunc helper() {}\n",
+			want:     true,
+		},
+		{
+			name:     "test in its own file",
+			filename: "parse_test.go",
+			content:  "package p\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) {}\n\nfunc helper(t *testing.T) {}\n",
+			want:     true,
+		},
+		{
+			name:     "tests of several functions",
+			filename: "parse_test.go",
+			content:  "package p\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) {}\n\nfunc TestFormat(t *testing.T) {}\n",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.filename)
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := IsSplit(filename)
+			if err != nil {
+				t.Fatalf("IsSplit failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsSplit(%s) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := IsSplit(filepath.Join(t.TempDir(), "missing.go")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
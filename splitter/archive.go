@@ -0,0 +1,87 @@
+package splitter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceFile is a file produced by SplitSource, named relative to the
+// directory of the split file.
+type SourceFile struct {
+	Name    string
+	Content []byte
+}
+
+// SplitSource splits src, the content of a file named filename, on a scratch
+// copy so nothing on disk changes, and returns every Go file the split leaves
+// in the directory, sorted by name. The source file itself is missing from the
+// result when nothing is left in it. A _test.go file is split like
+// SplitTestFunctions does, any other file like SplitPublicFunctions. Progress
+// lines are not written.
+func SplitSource(filename string, src []byte, opts Options) ([]SourceFile, error) {
+	scratch, err := os.MkdirTemp("", "go-file-splitter-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	name := filepath.Base(filename)
+	if err := os.WriteFile(filepath.Join(scratch, name), src, newFileMode); err != nil {
+		return nil, fmt.Errorf("failed to write scratch file: %w", err)
+	}
+
+	opts.DryRun = false
+	opts.Check = false
+	opts.Result = nil
+	opts.Output = io.Discard
+	split := SplitPublicFunctions
+	if strings.HasSuffix(name, "_test.go") {
+		split = SplitTestFunctions
+	}
+	if err := split(scratch, opts); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(scratch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scratch directory: %w", err)
+	}
+
+	var files []SourceFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(scratch, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		files = append(files, SourceFile{Name: entry.Name(), Content: content})
+	}
+
+	return files, nil
+}
+
+// WriteArchive writes files to w as a txtar archive: each file starts with a
+// "-- name --" line followed by its content, which always ends in a newline.
+func WriteArchive(w io.Writer, files []SourceFile) error {
+	var buf bytes.Buffer
+	for _, file := range files {
+		fmt.Fprintf(&buf, "-- %s --\n", file.Name)
+		buf.Write(file.Content)
+		if len(file.Content) > 0 && !bytes.HasSuffix(file.Content, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package splitter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitSourceArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "handlers.go")
+	src := `package handlers
+
+import "fmt"
+
+// Index serves the index.
+func Index() { fmt.Println("index") }
+
+// About serves the about page.
+func About() {}
+
+func helper() {}
+`
+
+	files, err := SplitSource(filename, []byte(src), Options{})
+	if err != nil {
+		t.Fatalf("SplitSource failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, files); err != nil {
+		t.Fatalf("WriteArchive failed: %v", err)
+	}
+
+	// Decode the archive: a "-- name --" line starts each file
+	decoded := make(map[string]string)
+	var names []string
+	var current string
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "-- "); ok && strings.HasSuffix(name, " --") {
+			current = strings.TrimSuffix(name, " --")
+			names = append(names, current)
+
+			continue
+		}
+		decoded[current] += line
+	}
+
+	if want := []string{"about.go", "handlers.go", "index.go"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("archive files = %v, want %v", names, want)
+	}
+	if _, ok := decoded[""]; ok {
+		t.Errorf("archive should start with a file header, got:\n%s", buf.String())
+	}
+
+	expected := map[string]string{
+		"about.go":    "package handlers\n\n// About serves the about page.\nfunc About() {}\n",
+		"handlers.go": "package handlers\n\nfunc helper() {}\n",
+		"index.go":    "package handlers\n\nimport \"fmt\"\n\n// Index serves the index.\nfunc Index() { fmt.Println(\"index\") }\n",
+	}
+	for name, want := range expected {
+		if decoded[name] != want {
+			t.Errorf("%s = %q, want %q", name, decoded[name], want)
+		}
+	}
+
+	// Nothing is written next to the source
+	if entries, err := os.ReadDir(tmpDir); err != nil || len(entries) != 0 {
+		t.Errorf("SplitSource should not write to the source directory, found %d entries (%v)", len(entries), err)
+	}
+}
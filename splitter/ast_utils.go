@@ -1,103 +1,351 @@
 package splitter
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-func isFunctionSpecificComment(cg *ast.CommentGroup, fn *ast.FuncDecl, allDecls []ast.Decl) bool {
-	// Skip if comment is inside the function body
-	if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
-		return false
+// filterOrphanedComments returns the comments of node except those of the
+// top-level functions named in removed: their doc, standalone and body
+// comments. Comments are matched by position, so a kept function whose
+// comments read the same as a removed one's keeps them.
+func filterOrphanedComments(node *ast.File, removed map[string]bool, fset *token.FileSet) []*ast.CommentGroup {
+	orphaned := make(map[*ast.CommentGroup]bool)
+	for _, decl := range node.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !removed[fn.Name.Name] {
+			continue
+		}
+
+		orphaned[fn.Doc] = true
+		standaloneComments, inlineComments := collectFunctionComments(node, fn, fset)
+		for _, cg := range append(standaloneComments, inlineComments...) {
+			orphaned[cg] = true
+		}
 	}
 
-	// Skip if this comment is inside another function body
-	for _, otherDecl := range allDecls {
-		if otherFn, ok := otherDecl.(*ast.FuncDecl); ok && otherFn != fn {
-			if otherFn.Body != nil && cg.Pos() >= otherFn.Body.Lbrace && cg.End() <= otherFn.Body.Rbrace {
-				return false
+	var remaining []*ast.CommentGroup
+	for _, cg := range node.Comments {
+		if !orphaned[cg] {
+			remaining = append(remaining, cg)
+		}
+	}
+
+	return remaining
+}
+
+// commentsInside returns the comments of node between the start and the end of
+// d, such as the comments on and between struct fields. The doc comment lies
+// before d and is not included.
+func commentsInside(node *ast.File, d *ast.GenDecl) []*ast.CommentGroup {
+	var comments []*ast.CommentGroup
+	for _, cg := range node.Comments {
+		if cg.Pos() > d.Pos() && cg.End() <= d.End() {
+			comments = append(comments, cg)
+		}
+	}
+
+	return comments
+}
+
+// commentsWithin returns the comments that belong to n, lying between the
+// start of its doc comment and the end of its line comment.
+func commentsWithin(comments []*ast.CommentGroup, n ast.Node) []*ast.CommentGroup {
+	start, end := commentRange(n)
+
+	var within []*ast.CommentGroup
+	for _, cg := range comments {
+		if cg.Pos() >= start && cg.End() <= end {
+			within = append(within, cg)
+		}
+	}
+
+	return within
+}
+
+// commentRange returns the range of the comments belonging to a spec or a
+// declaration: from its doc comment to the end of its line comment.
+func commentRange(n ast.Node) (token.Pos, token.Pos) {
+	var doc, comment *ast.CommentGroup
+	switch x := n.(type) {
+	case *ast.GenDecl:
+		doc = x.Doc
+		if !x.Lparen.IsValid() && len(x.Specs) == 1 {
+			start, end := commentRange(x.Specs[0])
+			if doc != nil {
+				start = doc.Pos()
 			}
+
+			return start, end
 		}
+	case *ast.TypeSpec:
+		doc, comment = x.Doc, x.Comment
+	case *ast.ValueSpec:
+		doc, comment = x.Doc, x.Comment
 	}
 
-	// Find the function's position in the declarations
-	fnIndex := -1
-	for i, decl := range allDecls {
-		if decl == fn {
-			fnIndex = i
+	start, end := n.Pos(), n.End()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	if comment != nil {
+		end = comment.End()
+	}
 
-			break
+	return start, end
+}
+
+// collectFunctionComments returns the standalone comments attributed to fn,
+// including one trailing its last line, and the comments inside it, in its
+// signature or its body. Comments above the package clause (build
+// constraints, the package doc) never belong to a function.
+func collectFunctionComments(node *ast.File, fn *ast.FuncDecl, fset *token.FileSet) ([]*ast.CommentGroup, []*ast.CommentGroup) {
+	var standaloneComments []*ast.CommentGroup
+	var inlineComments []*ast.CommentGroup
+	for _, cg := range node.Comments {
+		if cg == fn.Doc || cg.End() <= node.Name.End() {
+			continue
+		}
+		// Check if comment is inside the function, like /* unused */ by a parameter
+		if cg.Pos() >= fn.Pos() && cg.End() <= fn.End() {
+			inlineComments = append(inlineComments, cg)
+		} else if cg.Pos() >= fn.End() && fset.Position(cg.Pos()).Line == fset.Position(fn.End()).Line {
+			// A comment trailing the closing brace, like //nolint:unused
+			standaloneComments = append(standaloneComments, cg)
+		} else if isFunctionSpecificComment(cg, fn, node.Decls, fset) {
+			standaloneComments = append(standaloneComments, cg)
 		}
 	}
 
-	if fnIndex == -1 {
+	return standaloneComments, inlineComments
+}
+
+// functionSource returns the text of fn in src, the file node was parsed
+// from, from its first comment to the end of its last. It is nil when a
+// comment that isn't fn's lies in between, so the text can't be moved as it
+// is.
+func functionSource(src []byte, node *ast.File, fn PublicFunction, fset *token.FileSet) []byte {
+	start, end := fn.FuncDecl.Pos(), fn.FuncDecl.End()
+	own := make(map[*ast.CommentGroup]bool)
+	for _, cg := range slices.Concat([]*ast.CommentGroup{fn.Comments}, fn.StandaloneComments, fn.InlineComments) {
+		if cg == nil {
+			continue
+		}
+		own[cg] = true
+		start, end = min(start, cg.Pos()), max(end, cg.End())
+	}
+	for _, cg := range node.Comments {
+		if cg.Pos() < end && cg.End() > start && !own[cg] {
+			return nil
+		}
+	}
+
+	file := fset.File(node.Pos())
+	if file == nil || file.Size() != len(src) {
+		return nil
+	}
+
+	return src[file.Offset(start):file.Offset(end)]
+}
+
+// maxCommentGapLines is how many lines, usually blank, may separate a
+// standalone comment from the declaration it is attributed to.
+const maxCommentGapLines = 1
+
+func isFunctionSpecificComment(cg *ast.CommentGroup, fn *ast.FuncDecl, allDecls []ast.Decl, fset *token.FileSet) bool {
+	// Skip if comment is inside the function body
+	if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
 		return false
 	}
 
-	// Check comments before the function
-	if cg.End() >= fn.Pos() {
-		if fn.Body != nil && cg.Pos() > fn.Body.Rbrace {
-			return false
+	// Skip if this comment is inside another function body
+	if isInsideFunctionBody(cg, allDecls) {
+		return false
+	}
+
+	return isLeadingComment(cg, fn, fn.Doc, allDecls, fset)
+}
+
+// isLeadingComment reports whether the standalone comment cg, somewhere above
+// decl, belongs to it: it must be nearer to decl than to the declaration
+// before, and at most maxCommentGapLines lines above decl or its doc comment.
+func isLeadingComment(cg *ast.CommentGroup, decl ast.Decl, doc *ast.CommentGroup, allDecls []ast.Decl, fset *token.FileSet) bool {
+	leading, _ := leadingCommentDecision(cg, decl, doc, allDecls, fset)
+
+	return leading
+}
+
+// leadingCommentDecision is isLeadingComment, along with the reason for its
+// answer and the distances it was based on, for Verbose output.
+func leadingCommentDecision(cg *ast.CommentGroup, decl ast.Decl, doc *ast.CommentGroup, allDecls []ast.Decl, fset *token.FileSet) (bool, string) {
+	// Find the declaration's position in the declarations
+	declIndex := -1
+	for i, d := range allDecls {
+		if d == decl {
+			declIndex = i
+
+			break
 		}
+	}
 
-		return false
+	if declIndex == -1 || cg.End() >= decl.Pos() {
+		return false, "not above the declaration"
 	}
 
 	// Find the previous declaration
 	var prevDecl ast.Decl
 	prevDeclEnd := token.Pos(0)
-	for i := fnIndex - 1; i >= 0; i-- {
-		if decl := allDecls[i]; decl != nil {
-			prevDecl = decl
-			if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Body != nil {
+	for i := declIndex - 1; i >= 0; i-- {
+		if d := allDecls[i]; d != nil {
+			prevDecl = d
+			if funcDecl, ok := d.(*ast.FuncDecl); ok && funcDecl.Body != nil {
 				prevDeclEnd = funcDecl.Body.Rbrace
 			} else {
-				prevDeclEnd = decl.End()
+				prevDeclEnd = d.End()
 			}
 
 			break
 		}
 	}
 
-	// If there's a previous declaration, check which function the comment is closer to
+	if cg.Pos() <= prevDeclEnd {
+		return false, "not below the previous declaration"
+	}
+
+	// A comment trailing the previous declaration on its last line is its own
+	if prevDecl != nil && fset.Position(cg.Pos()).Line == fset.Position(prevDeclEnd).Line {
+		return false, "trails the previous declaration"
+	}
+
+	declStart := decl.Pos()
+	if doc != nil {
+		declStart = doc.Pos()
+	}
+	commentEndLine := fset.Position(cg.End()).Line
+	gap := fset.Position(declStart).Line - commentEndLine - 1
+	withinGap := gap <= maxCommentGapLines
+
+	// Directives such as //nolint:gocyclo always apply to what follows them
+	if isDirectiveGroup(cg) {
+		if !withinGap {
+			return false, fmt.Sprintf("directive with a gap of %d lines to the declaration (at most %d)", gap, maxCommentGapLines)
+		}
+
+		return true, fmt.Sprintf("directive with a gap of %d lines to the declaration", gap)
+	}
+
+	// If there's a previous declaration, check which one the comment is closer to
+	distances := fmt.Sprintf("a gap of %d lines to the declaration (at most %d)", gap, maxCommentGapLines)
 	if prevDecl != nil {
-		distToPrevDecl := cg.Pos() - prevDeclEnd
-		distToCurrentFunc := fn.Pos() - cg.End()
+		linesToPrevDecl := fset.Position(cg.Pos()).Line - fset.Position(prevDeclEnd).Line
+		linesToDecl := fset.Position(decl.Pos()).Line - commentEndLine
 
 		// If comment is closer to previous declaration, it belongs to that
-		if distToPrevDecl < distToCurrentFunc {
+		if linesToPrevDecl < linesToDecl {
+			return false, fmt.Sprintf("closer to the previous declaration (%d lines) than to this one (%d lines)", linesToPrevDecl, linesToDecl)
+		}
+		distances = fmt.Sprintf("%d lines from the previous declaration, %d from this one, ", linesToPrevDecl, linesToDecl) + distances
+	}
+
+	// Comment belongs to this declaration if at most maxCommentGapLines lines
+	// separate it from the declaration or its doc comment
+	return withinGap, distances
+}
+
+// isDirectiveGroup reports whether every comment in cg is a directive, such
+// as //go:generate, //nolint:gocyclo or //lint:ignore.
+func isDirectiveGroup(cg *ast.CommentGroup) bool {
+	for _, c := range cg.List {
+		if !isDirective(c.Text) {
 			return false
 		}
 	}
 
-	// Comment belongs to this function if it's after the previous declaration
-	// and reasonably close to the function
-	return cg.Pos() > prevDeclEnd && fn.Pos()-cg.End() < token.Pos(50*80)
+	return len(cg.List) > 0
 }
 
-func findUsedImports(fn *ast.FuncDecl, allImports []*ast.ImportSpec) []*ast.ImportSpec {
-	usedPackages := make(map[string]bool)
+// isDirective reports whether a comment is a directive: "//nolint" or, like
+// go/ast recognizes them, "//" followed by a lowercase word, a colon and a
+// letter ("//go:embed", "//lint:ignore").
+func isDirective(text string) bool {
+	rest, ok := strings.CutPrefix(text, "//")
+	if !ok {
+		return false
+	}
+	if rest == "nolint" || strings.HasPrefix(rest, "nolint:") {
+		return true
+	}
 
-	// Walk through the function body to find used packages
-	ast.Inspect(fn, func(n ast.Node) bool {
-		switch x := n.(type) {
-		case *ast.SelectorExpr:
-			if ident, ok := x.X.(*ast.Ident); ok {
-				usedPackages[ident.Name] = true
-			}
-		case *ast.CallExpr:
-			if ident, ok := x.Fun.(*ast.Ident); ok {
-				usedPackages[ident.Name] = true
-			}
-		case *ast.Ident:
-			if x.Obj == nil && x.Name != "" {
-				usedPackages[x.Name] = true
-			}
+	word, arg, found := strings.Cut(rest, ":")
+	if !found || word == "" || arg == "" {
+		return false
+	}
+	for _, r := range word {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
 		}
+	}
+	first, _ := utf8.DecodeRuneInString(arg)
 
-		return true
-	})
+	return 'a' <= first && first <= 'z'
+}
+
+// collectDeclarationComments returns the standalone comments above a
+// const/var/type declaration that belong to it, such as a //go:generate
+// directive separated from the doc comment by a blank line.
+func collectDeclarationComments(node *ast.File, d *ast.GenDecl, fset *token.FileSet) []*ast.CommentGroup {
+	var comments []*ast.CommentGroup
+	for _, cg := range node.Comments {
+		if cg == d.Doc || cg.End() <= node.Name.End() || cg.Pos() >= d.Pos() {
+			continue
+		}
+		if isInsideFunctionBody(cg, node.Decls) {
+			continue
+		}
+		if isLeadingComment(cg, d, d.Doc, node.Decls, fset) {
+			comments = append(comments, cg)
+		}
+	}
+
+	return comments
+}
+
+// parenComment returns the comment trailing the closing paren of the block d
+// on the same line, like ") // end of settings", or nil.
+func parenComment(node *ast.File, d *ast.GenDecl, fset *token.FileSet) *ast.CommentGroup {
+	if !d.Rparen.IsValid() {
+		return nil
+	}
+	for _, cg := range node.Comments {
+		if cg.Pos() > d.Rparen && fset.Position(cg.Pos()).Line == fset.Position(d.Rparen).Line {
+			return cg
+		}
+	}
+
+	return nil
+}
+
+func isInsideFunctionBody(cg *ast.CommentGroup, decls []ast.Decl) bool {
+	for _, decl := range decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findUsedImports(fn *ast.FuncDecl, allImports []*ast.ImportSpec) []*ast.ImportSpec {
+	usedPackages := make(map[string]bool)
+
+	// Walk through the whole function; ast.Inspect also visits type expressions,
+	// so selectors in type assertions, conversions and composite literals count
+	addPackageNames(usedPackages, fn)
 
 	// For test functions, always include "testing"
 	if strings.HasPrefix(fn.Name.Name, "Test") || strings.HasPrefix(fn.Name.Name, "Benchmark") {
@@ -122,7 +370,22 @@ func findUsedImports(fn *ast.FuncDecl, allImports []*ast.ImportSpec) []*ast.Impo
 		}
 	}
 
-	return result
+	return dedupeImports(result)
+}
+
+// addPackageNames adds the names root may refer to packages by to used: the
+// identifiers the parser couldn't resolve. Parameters, locals and the
+// variables of range loops, like the yield function of an iterator, resolve
+// to their declaration, so a parameter named strings doesn't keep the import
+// of package strings.
+func addPackageNames(used map[string]bool, root ast.Node) {
+	ast.Inspect(root, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj == nil && ident.Name != "" {
+			used[ident.Name] = true
+		}
+
+		return true
+	})
 }
 
 func findUsedImportsInDecls(decls []ast.Decl, allImports []*ast.ImportSpec) []*ast.ImportSpec {
@@ -141,24 +404,7 @@ func findUsedImportsInDecls(decls []ast.Decl, allImports []*ast.ImportSpec) []*a
 
 	// Walk through all declarations to find used packages
 	for _, decl := range decls {
-		ast.Inspect(decl, func(n ast.Node) bool {
-			switch x := n.(type) {
-			case *ast.SelectorExpr:
-				if ident, ok := x.X.(*ast.Ident); ok {
-					usedPackages[ident.Name] = true
-				}
-			case *ast.CallExpr:
-				if ident, ok := x.Fun.(*ast.Ident); ok {
-					usedPackages[ident.Name] = true
-				}
-			case *ast.Ident:
-				if x.Obj == nil && x.Name != "" {
-					usedPackages[x.Name] = true
-				}
-			}
-
-			return true
-		})
+		addPackageNames(usedPackages, decl)
 	}
 
 	// Filter imports to only include used ones
@@ -179,9 +425,122 @@ func findUsedImportsInDecls(decls []ast.Decl, allImports []*ast.ImportSpec) []*a
 		}
 	}
 
+	return dedupeImports(keepEmbedImport(decls, result, allImports))
+}
+
+// keepEmbedImport adds an import of "embed" to used when decls contain a
+// //go:embed directive, which only compiles in files importing it, but nothing
+// refers to the package by name, as with a directive above a string or []byte
+// variable. The blank import of allImports is reused if there is one.
+func keepEmbedImport(decls []ast.Decl, used, allImports []*ast.ImportSpec) []*ast.ImportSpec {
+	if !hasEmbedDirective(decls) || slices.ContainsFunc(used, isEmbedImport) {
+		return used
+	}
+
+	embedImport := &ast.ImportSpec{
+		Name: ast.NewIdent("_"),
+		Path: &ast.BasicLit{Kind: token.STRING, Value: `"embed"`},
+	}
+	for _, imp := range allImports {
+		if isEmbedImport(imp) && imp.Name != nil && imp.Name.Name == "_" {
+			embedImport = imp
+		}
+	}
+
+	return append(used, embedImport)
+}
+
+func isEmbedImport(imp *ast.ImportSpec) bool {
+	return imp.Path.Value == `"embed"`
+}
+
+func hasEmbedDirective(decls []ast.Decl) bool {
+	found := false
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if c, ok := n.(*ast.Comment); ok && strings.HasPrefix(c.Text, "//go:embed ") {
+				found = true
+			}
+
+			return !found
+		})
+	}
+
+	return found
+}
+
+// dedupeImports drops import specs binding the same name to the same path,
+// which would not compile, preferring the spec that spells out its alias.
+// Specs importing one path under different names are all kept.
+func dedupeImports(imports []*ast.ImportSpec) []*ast.ImportSpec {
+	index := make(map[string]int, len(imports))
+	result := make([]*ast.ImportSpec, 0, len(imports))
+	for _, imp := range imports {
+		key := importName(imp) + " " + imp.Path.Value
+		if i, ok := index[key]; ok {
+			if result[i].Name == nil && imp.Name != nil {
+				result[i] = imp
+			}
+
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, imp)
+	}
+
 	return result
 }
 
+// importDeclaration returns the import declaration of a generated file: the
+// standard library first, then everything else, each sorted by path, the way
+// goimports lays them out. The specs are copied without their positions, so
+// the grouping of the source file doesn't carry over; formatFile separates
+// the two groups with a blank line.
+func importDeclaration(imports []*ast.ImportSpec) *ast.GenDecl {
+	sorted := slices.Clone(imports)
+	slices.SortStableFunc(sorted, func(a, b *ast.ImportSpec) int {
+		if aStd, bStd := isStandardImport(a), isStandardImport(b); aStd != bStd {
+			if aStd {
+				return -1
+			}
+
+			return 1
+		}
+
+		return strings.Compare(a.Path.Value, b.Path.Value)
+	})
+
+	decl := &ast.GenDecl{Tok: token.IMPORT, Specs: make([]ast.Spec, len(sorted))}
+	for i, imp := range sorted {
+		spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: imp.Path.Value}}
+		if imp.Name != nil {
+			spec.Name = &ast.Ident{Name: imp.Name.Name}
+		}
+		decl.Specs[i] = spec
+	}
+
+	return decl
+}
+
+// isStandardImport reports whether imp is a standard library package, whose
+// path has no dot in its first element.
+func isStandardImport(imp *ast.ImportSpec) bool {
+	first, _, _ := strings.Cut(strings.Trim(imp.Path.Value, `"`), "/")
+
+	return !strings.Contains(first, ".")
+}
+
+// importName returns the name an import is referred to by: its alias, or the
+// last element of its path.
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	parts := strings.Split(strings.Trim(imp.Path.Value, `"`), "/")
+
+	return parts[len(parts)-1]
+}
+
 func findUsedPackages(fn *ast.FuncDecl) map[string]bool {
 	usedPackages := make(map[string]bool)
 
@@ -208,3 +567,142 @@ func findUsedPackages(fn *ast.FuncDecl) map[string]bool {
 
 	return usedPackages
 }
+
+// firstPublicName returns the first exported name declared in a const/var/type block.
+func firstPublicName(d *ast.GenDecl) string {
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				if isPublicName(name.Name) {
+					return name.Name
+				}
+			}
+		case *ast.TypeSpec:
+			if isPublicName(s.Name.Name) {
+				return s.Name.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// isNamed reports whether ident holds a name. Parsed files always have them,
+// but ASTs built or edited in memory, like partial ones in an editor, may not.
+func isNamed(ident *ast.Ident) bool {
+	return ident != nil && ident.Name != ""
+}
+
+// isPublicName reports whether name starts with an uppercase letter. The first
+// rune is decoded properly so non-ASCII identifiers such as "Größe" work.
+func isPublicName(name string) bool {
+	first, _ := utf8.DecodeRuneInString(name)
+
+	return unicode.IsUpper(first)
+}
+
+// hasLineDirective reports whether the file contains a //line directive, whose
+// position mapping would no longer be valid once the file is reformatted.
+func hasLineDirective(node *ast.File) bool {
+	for _, cg := range node.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//line ") || strings.HasPrefix(c.Text, "/*line ") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hasBuildConstraint reports whether the file carries a //go:build or
+// // +build line above its package clause.
+func hasBuildConstraint(node *ast.File) bool {
+	return buildConstraintLines(node) != ""
+}
+
+// buildConstraintLines returns the //go:build and // +build lines above the
+// package clause of the file, one per line, or "" when it has none.
+func buildConstraintLines(node *ast.File) string {
+	var lines []string
+	for _, cg := range node.Comments {
+		if cg.Pos() >= node.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//go:build") || strings.HasPrefix(c.Text, "// +build") {
+				lines = append(lines, c.Text)
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// findLicenseHeader returns the first comment block above the package clause
+// of the file, like a copyright or license notice, unless it is the package
+// doc or holds build constraints. It returns "" when there is none.
+func findLicenseHeader(node *ast.File) string {
+	if len(node.Comments) == 0 {
+		return ""
+	}
+	cg := node.Comments[0]
+	if cg.Pos() >= node.Package || cg == node.Doc {
+		return ""
+	}
+
+	lines := make([]string, 0, len(cg.List))
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//go:build") || strings.HasPrefix(c.Text, "// +build") {
+			return ""
+		}
+		lines = append(lines, c.Text)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// findCgoImportDecl returns the import declaration holding import "C", whose
+// doc comment is the cgo preamble.
+func findCgoImportDecl(decls []ast.Decl) *ast.GenDecl {
+	for _, decl := range decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if imp, ok := spec.(*ast.ImportSpec); ok && imp.Path.Value == `"C"` {
+				return genDecl
+			}
+		}
+	}
+
+	return nil
+}
+
+// declaredNames returns every name declared by a const/var/type block.
+func declaredNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		names = append(names, specNames(spec)...)
+	}
+
+	return names
+}
+
+func specNames(spec ast.Spec) []string {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		names := make([]string, 0, len(s.Names))
+		for _, name := range s.Names {
+			names = append(names, name.Name)
+		}
+
+		return names
+	case *ast.TypeSpec:
+		return []string{s.Name.Name}
+	default:
+		return nil
+	}
+}
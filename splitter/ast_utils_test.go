@@ -61,6 +61,59 @@ func TestExample(t *testing.T) {
 	}
 }
 
+func TestFindUsedImportsInTypeExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "type assertion", body: "_, ok := x.(net.Conn)\n\treturn ok", want: "net"},
+		{name: "type switch", body: "switch x.(type) {\n\tcase net.Conn:\n\t\treturn true\n\t}\n\treturn false", want: "net"},
+		{name: "conversion", body: "return time.Duration(1) > 0", want: "time"},
+		{name: "slice literal", body: "return len([]url.URL{{}}) > 0", want: "net/url"},
+		{name: "generic instantiation", body: "return len(make(map[string][]*list.List)) > 0", want: "container/list"},
+		{name: "func literal parameter", body: "f := func(io.Reader) {}\n\treturn f != nil", want: "io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := `package test
+
+import (
+	"container/list"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+func Check(x any) bool {
+	` + tt.body + `
+}
+`
+			fset := token.NewFileSet()
+			node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+
+			fn, ok := node.Decls[len(node.Decls)-1].(*ast.FuncDecl)
+			if !ok {
+				t.Fatal("Function not found")
+			}
+
+			usedImports := findUsedImports(fn, node.Imports)
+			if len(usedImports) != 1 || strings.Trim(usedImports[0].Path.Value, `"`) != tt.want {
+				var paths []string
+				for _, imp := range usedImports {
+					paths = append(paths, imp.Path.Value)
+				}
+				t.Errorf("Expected only %q to be used, got %v", tt.want, paths)
+			}
+		})
+	}
+}
+
 func TestFindUsedPackages(t *testing.T) {
 	src := `package test
 
@@ -164,7 +217,7 @@ func SecondFunc() {
 	// Test each comment group
 	for _, cg := range node.Comments {
 		commentText := cg.List[0].Text
-		isSpecific := isFunctionSpecificComment(cg, secondFunc, node.Decls)
+		isSpecific := isFunctionSpecificComment(cg, secondFunc, node.Decls, fset)
 
 		// Only the comment "This comment belongs to SecondFunc" should be specific
 		shouldBeSpecific := strings.Contains(commentText, "belongs to SecondFunc")
@@ -174,3 +227,126 @@ func SecondFunc() {
 		}
 	}
 }
+
+func TestIsFunctionSpecificCommentLineDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		gap      string
+		expected bool
+	}{
+		{"one blank line", "\n", true},
+		{"two blank lines", "\n\n", false},
+		{"many blank lines", strings.Repeat("\n", 20), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package test\n\nvar x = 1\n" + strings.Repeat("\n", 30) + "// Standalone comment\n" + tt.gap + "func Target() {}\n"
+
+			fset := token.NewFileSet()
+			node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+
+			fn, ok := node.Decls[1].(*ast.FuncDecl)
+			if !ok {
+				t.Fatal("Expected FuncDecl")
+			}
+
+			if got := isFunctionSpecificComment(node.Comments[0], fn, node.Decls, fset); got != tt.expected {
+				t.Errorf("isFunctionSpecificComment = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsFunctionSpecificCommentAfterLongFunction(t *testing.T) {
+	// A long previous body must not push the comment out of reach
+	src := "package test\n\nfunc Long() {\n" + strings.Repeat("\t_ = 1 // padding to make the body long\n", 200) + "}\n\n\n\n// Belongs to Next\n\nfunc Next() {}\n"
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	next, ok := node.Decls[1].(*ast.FuncDecl)
+	if !ok {
+		t.Fatal("Expected FuncDecl")
+	}
+
+	cg := node.Comments[len(node.Comments)-1]
+	if !isFunctionSpecificComment(cg, next, node.Decls, fset) {
+		t.Error("Comment one blank line above Next should belong to it")
+	}
+}
+
+func TestFindUsedImportsWithRedundantAlias(t *testing.T) {
+	src := `package test
+
+import (
+	"errors"
+	errors "errors"
+	e "errors"
+)
+
+func Wrap() error {
+	return errors.Join(errors.New("a"), e.New("b"))
+}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	fn, ok := node.Decls[1].(*ast.FuncDecl)
+	if !ok {
+		t.Fatal("Expected FuncDecl")
+	}
+
+	for name, usedImports := range map[string][]*ast.ImportSpec{
+		"findUsedImports":        findUsedImports(fn, node.Imports),
+		"findUsedImportsInDecls": findUsedImportsInDecls(node.Decls[1:], node.Imports),
+	} {
+		var names []string
+		for _, imp := range usedImports {
+			if imp.Name == nil {
+				names = append(names, "")
+			} else {
+				names = append(names, imp.Name.Name)
+			}
+		}
+		// The redundant "errors" collapses into its aliased form, e stays
+		if strings.Join(names, ",") != "errors,e" {
+			t.Errorf("%s: expected imports named [errors e], got %q", name, names)
+		}
+	}
+}
+
+func TestIsDirective(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"//nolint", true},
+		{"//nolint:gocyclo", true},
+		{"//nolint:gocyclo // too many branches", true},
+		{"//go:generate mockgen -source=a.go", true},
+		{"//go:embed templates/*", true},
+		{"//lint:ignore U1000 kept for later", true},
+		{"// nolint:gocyclo", false},
+		{"// Complex does things.", false},
+		{"// Note: something", false},
+		{"//Go:generate", false},
+		{"/* go:generate */", false},
+	}
+
+	for _, tt := range tests {
+		if got := isDirective(tt.text); got != tt.want {
+			t.Errorf("isDirective(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
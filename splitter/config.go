@@ -0,0 +1,217 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ConfigFileName is the name of the config file FindConfig looks for.
+const ConfigFileName = ".filesplitter.json"
+
+// ErrInvalidConfig is returned for a config file that is malformed or holds
+// invalid values.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Config is the content of a config file. Each field maps to the option of
+// the same name; fields left out of the file leave their option alone.
+type Config struct {
+	Abbreviations        []string `json:"abbreviations"`
+	MethodStrategy       string   `json:"method_strategy"`
+	Include              string   `json:"include"`
+	Exclude              string   `json:"exclude"`
+	IncludeGlob          string   `json:"include_glob"`
+	ExcludeGlob          string   `json:"exclude_glob"`
+	TestSuffix           string   `json:"test_suffix"`
+	Formatter            string   `json:"formatter"`
+	MaxDepth             *int     `json:"max_depth"`
+	MinFunctions         *int     `json:"min_functions"`
+	MaxFiles             *int     `json:"max_files"`
+	GroupVarsByBlock     *bool    `json:"group_vars_by_block"`
+	KeepLineDirectives   *bool    `json:"keep_line_directives"`
+	SplitInterfaces      *bool    `json:"split_interfaces"`
+	SplitTypes           *bool    `json:"split_types"`
+	NoCommonFile         *bool    `json:"no_common_file"`
+	GroupByFirstParam    *bool    `json:"group_by_first_param"`
+	GroupByType          *bool    `json:"group_by_type"`
+	IncludePrivate       *bool    `json:"include_private"`
+	MoveExclusiveHelpers *bool    `json:"move_exclusive_helpers"`
+	Provenance           *bool    `json:"provenance"`
+	LicenseHeader        *bool    `json:"license_header"`
+	DocFile              *bool    `json:"doc_file"`
+	NumberPrefix         *bool    `json:"number_prefix"`
+	IncludeVendor        *bool    `json:"include_vendor"`
+	ProcessGenerated     *bool    `json:"process_generated"`
+	MinimalDiff          *bool    `json:"minimal_diff"`
+	GroupTestsByPrefix   *bool    `json:"group_tests_by_prefix"`
+	ColocateTests        *bool    `json:"colocate_tests"`
+	ScaffoldTests        *bool    `json:"scaffold_tests"`
+	ConsolidateHelpers   *bool    `json:"consolidate_test_helpers"`
+	SingleFile           *bool    `json:"single_file"`
+	SortDeclarations     *bool    `json:"sort_declarations"`
+	LeaveMoveMarker      *bool    `json:"leave_move_marker"`
+	KeepOriginal         *bool    `json:"keep_original"`
+	ContinueOnError      *bool    `json:"continue_on_error"`
+	Verbose              *bool    `json:"verbose"`
+	Verify               *bool    `json:"verify"`
+}
+
+// FindConfig returns the path of the nearest config file in directory or one
+// of its parents, or "" when there is none.
+func FindConfig(directory string) (string, error) {
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check for config file: %w", err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the config file at path. Unknown keys are errors, so a
+// misspelled option doesn't go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+	}
+
+	return &cfg, nil
+}
+
+// Apply sets the options given in the config on opts, except those whose key
+// (e.g. "method_strategy") skip reports as set elsewhere, like by a flag.
+func (c *Config) Apply(opts *Options, skip func(key string) bool) error {
+	use := func(key string, present bool) bool {
+		return present && (skip == nil || !skip(key))
+	}
+
+	if use("abbreviations", c.Abbreviations != nil) {
+		opts.Abbreviations = c.Abbreviations
+	}
+	if use("method_strategy", c.MethodStrategy != "") {
+		switch strategy := MethodStrategy(c.MethodStrategy); strategy {
+		case MethodStrategySeparate, MethodStrategyWithStruct, MethodStrategyMixed:
+			opts.MethodStrategy = strategy
+		default:
+			return fmt.Errorf("%w: unknown method_strategy %q", ErrInvalidConfig, c.MethodStrategy)
+		}
+	}
+	for _, pattern := range []struct {
+		key   string
+		value string
+		dst   **regexp.Regexp
+	}{
+		{"include", c.Include, &opts.IncludePattern},
+		{"exclude", c.Exclude, &opts.ExcludePattern},
+	} {
+		if !use(pattern.key, pattern.value != "") {
+			continue
+		}
+		re, err := regexp.Compile(pattern.value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidConfig, pattern.key, err)
+		}
+		*pattern.dst = re
+	}
+	for _, glob := range []struct {
+		key   string
+		value string
+		dst   *string
+	}{
+		{"include_glob", c.IncludeGlob, &opts.IncludeGlob},
+		{"exclude_glob", c.ExcludeGlob, &opts.ExcludeGlob},
+	} {
+		if !use(glob.key, glob.value != "") {
+			continue
+		}
+		if _, err := filepath.Match(glob.value, ""); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidConfig, glob.key, err)
+		}
+		*glob.dst = glob.value
+	}
+	if use("test_suffix", c.TestSuffix != "") {
+		opts.TestFileSuffix = c.TestSuffix
+	}
+	if use("formatter", c.Formatter != "") {
+		opts.Formatter = CommandFormatter(c.Formatter)
+	}
+	if use("max_depth", c.MaxDepth != nil) {
+		opts.MaxDepth = nil
+		if *c.MaxDepth >= 0 {
+			opts.MaxDepth = c.MaxDepth
+		}
+	}
+	if use("min_functions", c.MinFunctions != nil) {
+		opts.MinFunctionsToSplit = *c.MinFunctions
+	}
+	if use("max_files", c.MaxFiles != nil) {
+		opts.MaxFilesPerDirectory = *c.MaxFiles
+	}
+
+	for _, option := range []struct {
+		key   string
+		value *bool
+		dst   *bool
+	}{
+		{"group_vars_by_block", c.GroupVarsByBlock, &opts.GroupVarsByBlock},
+		{"keep_line_directives", c.KeepLineDirectives, &opts.KeepLineDirectives},
+		{"split_interfaces", c.SplitInterfaces, &opts.SplitInterfaces},
+		{"split_types", c.SplitTypes, &opts.SplitTypes},
+		{"no_common_file", c.NoCommonFile, &opts.NoCommonFile},
+		{"group_by_first_param", c.GroupByFirstParam, &opts.GroupByFirstParam},
+		{"group_by_type", c.GroupByType, &opts.GroupByType},
+		{"include_private", c.IncludePrivate, &opts.IncludePrivate},
+		{"move_exclusive_helpers", c.MoveExclusiveHelpers, &opts.MoveExclusiveHelpers},
+		{"provenance", c.Provenance, &opts.AddProvenance},
+		{"license_header", c.LicenseHeader, &opts.LicenseHeader},
+		{"doc_file", c.DocFile, &opts.DocFile},
+		{"number_prefix", c.NumberPrefix, &opts.NumberPrefix},
+		{"include_vendor", c.IncludeVendor, &opts.IncludeVendor},
+		{"process_generated", c.ProcessGenerated, &opts.ProcessGenerated},
+		{"minimal_diff", c.MinimalDiff, &opts.MinimalDiff},
+		{"group_tests_by_prefix", c.GroupTestsByPrefix, &opts.GroupTestsByPrefix},
+		{"single_file", c.SingleFile, &opts.SingleFile},
+		{"sort_declarations", c.SortDeclarations, &opts.SortDeclarations},
+		{"scaffold_tests", c.ScaffoldTests, &opts.ScaffoldMissingTests},
+		{"consolidate_test_helpers", c.ConsolidateHelpers, &opts.ConsolidateTestHelpers},
+		{"leave_move_marker", c.LeaveMoveMarker, &opts.LeaveMoveMarker},
+		{"keep_original", c.KeepOriginal, &opts.KeepOriginal},
+		{"continue_on_error", c.ContinueOnError, &opts.ContinueOnError},
+		{"verbose", c.Verbose, &opts.Verbose},
+		{"verify", c.Verify, &opts.Verify},
+	} {
+		if use(option.key, option.value != nil) {
+			*option.dst = *option.value
+		}
+	}
+	// Colocating is the default, so the option is stored inverted
+	if use("colocate_tests", c.ColocateTests != nil) {
+		opts.SkipCorrespondingTests = !*c.ColocateTests
+	}
+
+	return nil
+}
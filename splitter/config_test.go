@@ -0,0 +1,129 @@
+package splitter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "pkg", "inner")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing inside root yet; a config above the temp directory is not ours
+	path, err := FindConfig(nested)
+	if err != nil {
+		t.Fatalf("FindConfig failed: %v", err)
+	}
+	if strings.HasPrefix(path, root) {
+		t.Errorf("FindConfig(%s) = %q, want no config inside %s", nested, path, root)
+	}
+
+	configFile := filepath.Join(root, ConfigFileName)
+	if err := os.WriteFile(configFile, []byte(`{"method_strategy": "with-struct"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{root, filepath.Join(root, "pkg"), nested} {
+		path, err := FindConfig(dir)
+		if err != nil {
+			t.Fatalf("FindConfig(%s) failed: %v", dir, err)
+		}
+		if path != configFile {
+			t.Errorf("FindConfig(%s) = %q, want %q", dir, path, configFile)
+		}
+	}
+
+	// The nearest config wins
+	innerConfig := filepath.Join(nested, ConfigFileName)
+	if err := os.WriteFile(innerConfig, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := FindConfig(nested); path != innerConfig {
+		t.Errorf("FindConfig(%s) = %q, want %q", nested, path, innerConfig)
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ConfigFileName)
+	content := `{
+	"abbreviations": ["ACL", "SKU"],
+	"method_strategy": "with-struct",
+	"exclude": "^Legacy",
+	"max_depth": 2,
+	"split_types": true,
+	"colocate_tests": false
+}`
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// Flags given on the command line win over the config
+	opts := Options{MethodStrategy: MethodStrategySeparate, SplitTypes: false}
+	flags := map[string]bool{"method_strategy": true}
+	if err := cfg.Apply(&opts, func(key string) bool { return flags[key] }); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if opts.MethodStrategy != MethodStrategySeparate {
+		t.Errorf("MethodStrategy = %q, the flag should win", opts.MethodStrategy)
+	}
+	if len(opts.Abbreviations) != 2 || opts.Abbreviations[0] != "ACL" {
+		t.Errorf("Abbreviations = %v, want [ACL SKU]", opts.Abbreviations)
+	}
+	if opts.ExcludePattern == nil || !opts.ExcludePattern.MatchString("LegacyHandler") {
+		t.Errorf("ExcludePattern = %v, want ^Legacy", opts.ExcludePattern)
+	}
+	if opts.MaxDepth == nil || *opts.MaxDepth != 2 {
+		t.Errorf("MaxDepth = %v, want 2", opts.MaxDepth)
+	}
+	if !opts.SplitTypes {
+		t.Error("SplitTypes should be set by the config")
+	}
+	if !opts.SkipCorrespondingTests {
+		t.Error("colocate_tests: false should skip corresponding tests")
+	}
+	if opts.GroupVarsByBlock {
+		t.Error("options missing from the config should stay unset")
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"malformed JSON", `{"method_strategy": `},
+		{"unknown key", `{"method-strategy": "with-struct"}`},
+		{"wrong type", `{"split_types": "yes"}`},
+		{"unknown strategy", `{"method_strategy": "grouped"}`},
+		{"invalid pattern", `{"include": "("}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), ConfigFileName)
+			if err := os.WriteFile(configFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(configFile)
+			if err == nil {
+				err = cfg.Apply(&Options{}, nil)
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNotSplit is returned in check mode when splitting would change files.
+var ErrNotSplit = errors.New("package is not split")
+
+// dryRun runs split on a scratch copy of the Go files below directory, so
+// directory itself is left untouched. Progress lines and opts.Result name the
+// original paths. With opts.Check, any planned change fails the run.
+func dryRun(directory string, opts Options, split func(string, Options) error) error {
+	scratch, err := os.MkdirTemp("", "go-file-splitter-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	if err := copyGoFiles(directory, scratch, opts); err != nil {
+		return err
+	}
+
+	planned := &SplitResult{}
+	scratchOpts := opts
+	scratchOpts.DryRun = false
+	scratchOpts.Check = false
+	scratchOpts.Result = planned
+	var progress bytes.Buffer
+	scratchOpts.Output = &progress
+	splitErr := split(scratch, scratchOpts)
+
+	// A run writes some files again as they were, like a type file holding
+	// its methods already, so only files whose content differs count
+	unchanged := dropUnchanged(planned, scratch, directory)
+	out := &dryRunWriter{w: opts.output(), scratch: scratch, directory: filepath.Clean(directory)}
+	for _, line := range strings.SplitAfter(progress.String(), "\n") {
+		if line == "" || slices.ContainsFunc(strings.Fields(line), func(field string) bool {
+			return slices.Contains(unchanged, field)
+		}) {
+			continue
+		}
+		if _, err := out.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	if splitErr != nil {
+		return splitErr
+	}
+
+	originalPath := func(path string) string {
+		rel, err := filepath.Rel(scratch, path)
+		if err != nil {
+			return path
+		}
+
+		return filepath.Join(directory, rel)
+	}
+	for _, list := range []*[]string{&planned.CreatedFiles, &planned.UpdatedFiles, &planned.DeletedFiles} {
+		for i, path := range *list {
+			(*list)[i] = originalPath(path)
+		}
+	}
+
+	if r := opts.Result; r != nil {
+		r.Files += planned.Files
+		r.Created += planned.Created
+		r.Updated += planned.Updated
+		r.Deleted += planned.Deleted
+		r.CreatedFiles = append(r.CreatedFiles, planned.CreatedFiles...)
+		r.UpdatedFiles = append(r.UpdatedFiles, planned.UpdatedFiles...)
+		r.DeletedFiles = append(r.DeletedFiles, planned.DeletedFiles...)
+	}
+
+	if opts.Check && planned.changes() > 0 {
+		return fmt.Errorf("%w: %d files would change", ErrNotSplit, planned.changes())
+	}
+
+	return nil
+}
+
+// dropUnchanged removes from r the created and updated files below scratch
+// whose content is the same as that of the file at the same path below
+// directory, and returns them.
+func dropUnchanged(r *SplitResult, scratch, directory string) []string {
+	var unchanged []string
+	isUnchanged := func(path string) bool {
+		rel, err := filepath.Rel(scratch, path)
+		if err != nil {
+			return false
+		}
+		written, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		original, err := os.ReadFile(filepath.Join(directory, rel))
+		if err != nil || !bytes.Equal(written, original) {
+			return false
+		}
+		unchanged = append(unchanged, path)
+
+		return true
+	}
+
+	created, updated := len(r.CreatedFiles), len(r.UpdatedFiles)
+	r.CreatedFiles = slices.DeleteFunc(r.CreatedFiles, isUnchanged)
+	r.UpdatedFiles = slices.DeleteFunc(r.UpdatedFiles, isUnchanged)
+	r.Created -= created - len(r.CreatedFiles)
+	r.Updated -= updated - len(r.UpdatedFiles)
+	if r.changes() == 0 {
+		r.Files = 0
+	}
+
+	return unchanged
+}
+
+// copyGoFiles copies the Go files below directory that a run would look at
+// into the same relative paths below dst.
+func copyGoFiles(directory, dst string, opts Options) error {
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if (path != directory && isIgnoredDir(d.Name(), opts)) || exceedsMaxDepth(directory, path, opts.MaxDepth) {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		if err := os.WriteFile(target, content, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", path, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy go files: %w", err)
+	}
+
+	return nil
+}
+
+// dryRunWriter rewrites the progress lines of a run on a scratch copy so they
+// name the original paths and say what would happen rather than what did.
+type dryRunWriter struct {
+	w         io.Writer
+	scratch   string
+	directory string
+}
+
+//nolint:gochecknoglobals
+var dryRunVerbs = strings.NewReplacer(
+	"Created", "Would create",
+	"Updated", "Would update",
+	"Preserved", "Would update",
+	"Deleted", "Would delete",
+)
+
+func (d *dryRunWriter) Write(p []byte) (int, error) {
+	line := strings.ReplaceAll(string(p), d.scratch, d.directory)
+	if verb, rest, found := strings.Cut(line, " "); found {
+		line = dryRunVerbs.Replace(verb) + " " + rest
+	}
+	if _, err := io.WriteString(d.w, line); err != nil {
+		return 0, fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return len(p), nil
+}
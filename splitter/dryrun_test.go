@@ -0,0 +1,171 @@
+package splitter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		opts    Options
+		wantErr bool
+	}{
+		{
+			name: "unsplit file",
+			files: map[string]string{
+				"funcs.go": `package funcs
+
+// Parse parses.
+func Parse() {}
+
+// Format formats.
+func Format() {}
+
+func helper() {}
+`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "already split",
+			files: map[string]string{
+				"parse.go": `package funcs
+
+// Parse parses.
+func Parse() {}
+`,
+				"format.go": `package funcs
+
+// Format formats.
+func Format() { helper() }
+
+func helper() {}
+`,
+				"format_test.go": `package funcs
+
+import "testing"
+
+func TestFormat(t *testing.T) {}
+`,
+				"common.go": `package funcs
+
+const Version = "v1"
+`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "already split with methods",
+			files: map[string]string{
+				"common.go": `package server
+
+// Server serves.
+type Server struct{}
+`,
+				"server_start.go": `package server
+
+// Start starts.
+func (s *Server) Start() {}
+`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "already split with-struct",
+			opts: Options{MethodStrategy: MethodStrategyWithStruct},
+			files: map[string]string{
+				"server.go": `package server
+
+// Server serves.
+type Server struct{}
+
+// NewServer returns a Server.
+func NewServer() *Server { return &Server{} }
+
+// Start starts.
+func (s *Server) Start() {}
+`,
+				"mode.go": `package server
+
+// Mode is a mode.
+type Mode int
+`,
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out strings.Builder
+			opts := tt.opts
+			opts.Check = true
+			opts.Output = &out
+			err := SplitPublicFunctions(tmpDir, opts)
+			if tt.wantErr != errors.Is(err, ErrNotSplit) {
+				t.Errorf("SplitPublicFunctions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.Contains(out.String(), "Would") {
+				t.Errorf("Files left as they are should not be reported, got:\n%s", out.String())
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.files) {
+				t.Errorf("Check should not create or delete files, got %d entries", len(entries))
+			}
+			for name, content := range tt.files {
+				got, err := os.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil || string(got) != content {
+					t.Errorf("Check should leave %s untouched", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunReportsOriginalPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "funcs.go")
+	if err := os.WriteFile(testFile, []byte("package funcs\n\nfunc Parse() {}\n\nfunc Format() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	result := &SplitResult{}
+	if err := SplitPublicFunctions(tmpDir, Options{DryRun: true, Output: &out, Result: result}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	if _, err := os.Stat(testFile); err != nil {
+		t.Errorf("Dry run should keep %s: %v", testFile, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "parse.go")); !os.IsNotExist(err) {
+		t.Error("Dry run should not create parse.go")
+	}
+
+	want := []string{filepath.Join(tmpDir, "parse.go"), filepath.Join(tmpDir, "format.go")}
+	if strings.Join(result.CreatedFiles, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected created files %v, got %v", want, result.CreatedFiles)
+	}
+	if len(result.DeletedFiles) != 1 || result.DeletedFiles[0] != testFile {
+		t.Errorf("Expected %s to be reported as deleted, got %v", testFile, result.DeletedFiles)
+	}
+	if !strings.Contains(out.String(), "Would create: "+want[0]) {
+		t.Errorf("Expected output to name the original paths, got:\n%s", out.String())
+	}
+}
@@ -1,40 +1,75 @@
 package splitter
 
 import (
+	"cmp"
+	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
+	"slices"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-func extractPublicFunctions(node *ast.File) []PublicFunction {
+// ExtractPublicFunctions returns the exported top-level functions of node in
+// source order, with the comments that splitting would move along with each:
+// its doc comment, the standalone comments above it and those inside its
+// body. node must have been parsed by fset with parser.ParseComments, or
+// comments are missing from the result. The results share their AST nodes
+// with node; they are not copies, so changes to one show in the other.
+func ExtractPublicFunctions(node *ast.File, fset *token.FileSet) []PublicFunction {
+	return extractPublicFunctions(node, Options{}, fset)
+}
+
+// ExtractTestFunctions returns the Test functions of node, like TestParse or
+// Test_parse, in source order, under the same contract as
+// ExtractPublicFunctions. Benchmarks, examples, fuzz tests and functions like
+// Testify, whose name doesn't continue with an uppercase letter after "Test",
+// are left out.
+func ExtractTestFunctions(node *ast.File, fset *token.FileSet) []TestFunction {
+	return extractTestFunctions(node, fset)
+}
+
+// ExtractPublicMethods returns the exported methods of node in source order,
+// under the same contract as ExtractPublicFunctions. ReceiverType holds the
+// name of the receiver's type, without pointer or type parameters.
+func ExtractPublicMethods(node *ast.File, fset *token.FileSet) []PublicMethod {
+	return extractPublicMethods(node, fset)
+}
+
+// extractPublicFunctions collects the top-level functions to split out. With
+// opts.IncludePrivate, unexported functions are collected too, except init and
+// blank functions, which may appear more than once per package. Functions not
+// selected by opts.IncludePattern and opts.ExcludePattern are left out.
+func extractPublicFunctions(node *ast.File, opts Options, fset *token.FileSet) []PublicFunction {
 	publicFuncs := make([]PublicFunction, 0, len(node.Decls))
 
 	for _, decl := range node.Decls {
 		fn, ok := decl.(*ast.FuncDecl)
-		if !ok || fn.Recv != nil {
+		if !ok || fn.Recv != nil || !isNamed(fn.Name) {
+			continue
+		}
+
+		if fn.Name.Name == "init" || fn.Name.Name == "_" {
 			continue
 		}
 
 		// Check if function is public (starts with uppercase)
-		if !unicode.IsUpper(rune(fn.Name.Name[0])) {
+		if !opts.IncludePrivate && !isPublicName(fn.Name.Name) {
 			continue
 		}
 
-		var standaloneComments []*ast.CommentGroup
-		var inlineComments []*ast.CommentGroup
-		for _, cg := range node.Comments {
-			if cg == fn.Doc {
-				continue
-			}
-			// Check if comment is inside the function body
-			if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
-				inlineComments = append(inlineComments, cg)
-			} else if isFunctionSpecificComment(cg, fn, node.Decls) {
-				standaloneComments = append(standaloneComments, cg)
-			}
+		if opts.IncludePattern != nil && !opts.IncludePattern.MatchString(fn.Name.Name) {
+			continue
+		}
+		if opts.ExcludePattern != nil && opts.ExcludePattern.MatchString(fn.Name.Name) {
+			continue
 		}
 
+		standaloneComments, inlineComments := collectFunctionComments(node, fn, fset)
+
 		publicFunc := PublicFunction{
 			Name:               fn.Name.Name,
 			FuncDecl:           fn,
@@ -50,6 +85,11 @@ func extractPublicFunctions(node *ast.File) []PublicFunction {
 	return publicFuncs
 }
 
+// extractPublicDeclarations collects the const, var and type declarations
+// with public members. Blocks mixing public and private specs are split: only
+// the public specs move, and the private ones stay behind. Const blocks relying
+// on iota or implicit repetition can't be split without changing their values,
+// so they move whole.
 func extractPublicDeclarations(node *ast.File) []PublicDeclaration {
 	var publicDecls []PublicDeclaration
 
@@ -59,49 +99,93 @@ func extractPublicDeclarations(node *ast.File) []PublicDeclaration {
 			continue
 		}
 
-		// Check if this declaration contains any public const/var/type
-		hasPublic := false
+		var publicSpecs []ast.Spec
 		for _, spec := range genDecl.Specs {
-			switch s := spec.(type) {
-			case *ast.ValueSpec:
-				for _, name := range s.Names {
-					if unicode.IsUpper(rune(name.Name[0])) {
-						hasPublic = true
-
-						break
-					}
-				}
-			case *ast.TypeSpec:
-				// Check if the type is public
-				if unicode.IsUpper(rune(s.Name.Name[0])) {
-					hasPublic = true
-				}
-			}
-			if hasPublic {
-				break
+			if isPublicSpec(spec) {
+				publicSpecs = append(publicSpecs, spec)
 			}
 		}
+		if len(publicSpecs) == 0 {
+			continue
+		}
 
-		if hasPublic {
-			publicDecl := PublicDeclaration{
-				GenDecl:  genDecl,
-				Comments: genDecl.Doc,
-				Package:  node.Name.Name,
-				Imports:  node.Imports,
-			}
-			publicDecls = append(publicDecls, publicDecl)
+		publicDecl := PublicDeclaration{
+			GenDecl:        genDecl,
+			Comments:       genDecl.Doc,
+			InlineComments: commentsInside(node, genDecl),
+			Package:        node.Name.Name,
+			Imports:        node.Imports,
+		}
+		if len(publicSpecs) < len(genDecl.Specs) && !usesIota(genDecl) {
+			split := *genDecl
+			split.Specs = publicSpecs
+			publicDecl.GenDecl = &split
+			publicDecl.InlineComments = slices.DeleteFunc(publicDecl.InlineComments, func(cg *ast.CommentGroup) bool {
+				return !slices.ContainsFunc(publicSpecs, func(spec ast.Spec) bool {
+					return len(commentsWithin([]*ast.CommentGroup{cg}, spec)) > 0
+				})
+			})
 		}
+		publicDecls = append(publicDecls, publicDecl)
 	}
 
 	return publicDecls
 }
 
-func extractTestFunctions(node *ast.File) []TestFunction {
+// isPublicSpec reports whether a const, var or type spec declares a public
+// name. A value spec declaring several names counts as public if any is.
+func isPublicSpec(spec ast.Spec) bool {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		return slices.ContainsFunc(s.Names, func(name *ast.Ident) bool {
+			return isNamed(name) && isPublicName(name.Name)
+		})
+	case *ast.TypeSpec:
+		return isNamed(s.Name) && isPublicName(s.Name.Name)
+	default:
+		return false
+	}
+}
+
+// usesIota reports whether d is a const block whose values depend on the
+// position of its specs: through iota, or specs repeating the previous value.
+func usesIota(d *ast.GenDecl) bool {
+	if d.Tok != token.CONST {
+		return false
+	}
+
+	for _, spec := range d.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		if len(vs.Values) == 0 {
+			return true
+		}
+		for _, value := range vs.Values {
+			found := false
+			ast.Inspect(value, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && ident.Name == "iota" {
+					found = true
+				}
+
+				return !found
+			})
+			if found {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func extractTestFunctions(node *ast.File, fset *token.FileSet) []TestFunction {
 	tests := make([]TestFunction, 0, len(node.Decls))
 
 	for _, decl := range node.Decls {
 		fn, ok := decl.(*ast.FuncDecl)
-		if !ok || fn.Recv != nil {
+		if !ok || fn.Recv != nil || !isNamed(fn.Name) {
 			continue
 		}
 
@@ -114,23 +198,12 @@ func extractTestFunctions(node *ast.File) []TestFunction {
 		nameAfterTest = strings.TrimLeft(nameAfterTest, "_")
 
 		// Skip if empty or starts with lowercase
-		if len(nameAfterTest) == 0 || unicode.IsLower(rune(nameAfterTest[0])) {
+		first, _ := utf8.DecodeRuneInString(nameAfterTest)
+		if len(nameAfterTest) == 0 || unicode.IsLower(first) {
 			continue
 		}
 
-		var standaloneComments []*ast.CommentGroup
-		var inlineComments []*ast.CommentGroup
-		for _, cg := range node.Comments {
-			if cg == fn.Doc {
-				continue
-			}
-			// Check if comment is inside the function body
-			if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
-				inlineComments = append(inlineComments, cg)
-			} else if isFunctionSpecificComment(cg, fn, node.Decls) {
-				standaloneComments = append(standaloneComments, cg)
-			}
-		}
+		standaloneComments, inlineComments := collectFunctionComments(node, fn, fset)
 
 		test := TestFunction{
 			Name:               fn.Name.Name,
@@ -147,17 +220,29 @@ func extractTestFunctions(node *ast.File) []TestFunction {
 	return tests
 }
 
-func extractPublicMethods(node *ast.File) []PublicMethod {
+func extractPublicMethods(node *ast.File, fset *token.FileSet) []PublicMethod {
+	return extractMethods(node, fset, isPublicName)
+}
+
+// extractPrivateMethods returns the unexported methods of node, which
+// MethodStrategyMixed moves as well.
+func extractPrivateMethods(node *ast.File, fset *token.FileSet) []PublicMethod {
+	return extractMethods(node, fset, func(name string) bool {
+		return !isPublicName(name)
+	})
+}
+
+// extractMethods returns the methods of node whose name keep accepts.
+func extractMethods(node *ast.File, fset *token.FileSet, keep func(string) bool) []PublicMethod {
 	publicMethods := make([]PublicMethod, 0, len(node.Decls))
 
 	for _, decl := range node.Decls {
 		fn, ok := decl.(*ast.FuncDecl)
-		if !ok || fn.Recv == nil {
+		if !ok || fn.Recv == nil || !isNamed(fn.Name) {
 			continue
 		}
 
-		// Check if method is public (starts with uppercase)
-		if !unicode.IsUpper(rune(fn.Name.Name[0])) {
+		if !keep(fn.Name.Name) {
 			continue
 		}
 
@@ -167,19 +252,7 @@ func extractPublicMethods(node *ast.File) []PublicMethod {
 			continue
 		}
 
-		var standaloneComments []*ast.CommentGroup
-		var inlineComments []*ast.CommentGroup
-		for _, cg := range node.Comments {
-			if cg == fn.Doc {
-				continue
-			}
-			// Check if comment is inside the function body
-			if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
-				inlineComments = append(inlineComments, cg)
-			} else if isFunctionSpecificComment(cg, fn, node.Decls) {
-				standaloneComments = append(standaloneComments, cg)
-			}
-		}
+		standaloneComments, inlineComments := collectFunctionComments(node, fn, fset)
 
 		publicMethod := PublicMethod{
 			Name:               fn.Name.Name,
@@ -207,16 +280,382 @@ func getReceiverTypeName(recv *ast.FieldList) string {
 		return ""
 	}
 
-	switch t := field.Type.(type) {
-	case *ast.Ident:
-		// Simple type: func (r Receiver)
-		return t.Name
-	case *ast.StarExpr:
-		// Pointer type: func (r *Receiver)
-		if ident, ok := t.X.(*ast.Ident); ok {
-			return ident.Name
+	// Unwrap pointers and type arguments: func (r *Receiver[K, V])
+	expr := field.Type
+	for {
+		switch t := expr.(type) {
+		case *ast.Ident:
+			return t.Name
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		default:
+			return ""
+		}
+	}
+}
+
+// findConstructors groups constructor-style functions by the public type they
+// build: functions named New<Type>, or whose single result is Type or *Type.
+func findConstructors(publicFuncs []PublicFunction, publicDecls []PublicDeclaration) map[string][]PublicFunction {
+	typeNames := make(map[string]bool)
+	for _, decl := range publicDecls {
+		for _, spec := range decl.GenDecl.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && isPublicName(ts.Name.Name) {
+				typeNames[ts.Name.Name] = true
+			}
+		}
+	}
+
+	constructors := make(map[string][]PublicFunction)
+	for _, fn := range publicFuncs {
+		if typeName := constructedTypeName(fn.FuncDecl, typeNames); typeName != "" {
+			constructors[typeName] = append(constructors[typeName], fn)
 		}
 	}
 
+	return constructors
+}
+
+func constructedTypeName(fn *ast.FuncDecl, typeNames map[string]bool) string {
+	if name := strings.TrimPrefix(fn.Name.Name, "New"); name != fn.Name.Name && typeNames[name] {
+		return name
+	}
+
+	results := fn.Type.Results
+	if results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 {
+		return ""
+	}
+
+	resultType := results.List[0].Type
+	if star, ok := resultType.(*ast.StarExpr); ok {
+		resultType = star.X
+	}
+	if ident, ok := resultType.(*ast.Ident); ok && typeNames[ident.Name] {
+		return ident.Name
+	}
+
 	return ""
 }
+
+// groupByFirstParam groups functions by the local type name of their first
+// parameter. Functions without parameters, or whose first parameter has a
+// predeclared or package-qualified type, are left out.
+func groupByFirstParam(publicFuncs []PublicFunction) map[string][]PublicFunction {
+	groups := make(map[string][]PublicFunction)
+	for _, fn := range publicFuncs {
+		if typeName := firstParamTypeName(fn.FuncDecl); typeName != "" {
+			groups[typeName] = append(groups[typeName], fn)
+		}
+	}
+
+	return groups
+}
+
+// groupTestsByPrefix buckets tests by the first snake_case segment of their
+// name, so TestUserCreate and TestUserDelete share the "user" bucket.
+func groupTestsByPrefix(tests []TestFunction, extraAbbreviations ...string) map[string][]TestFunction {
+	groups := make(map[string][]TestFunction)
+	for _, test := range tests {
+		prefix := testNamePrefix(test.Name, extraAbbreviations...)
+		groups[prefix] = append(groups[prefix], test)
+	}
+
+	return groups
+}
+
+func firstParamTypeName(fn *ast.FuncDecl) string {
+	params := fn.Type.Params
+	if params == nil || len(params.List) == 0 {
+		return ""
+	}
+
+	paramType := params.List[0].Type
+	if star, ok := paramType.(*ast.StarExpr); ok {
+		paramType = star.X
+	}
+
+	ident, ok := paramType.(*ast.Ident)
+	if !ok || types.Universe.Lookup(ident.Name) != nil {
+		return ""
+	}
+
+	return ident.Name
+}
+
+// aliasedTypeName returns the name of the package-local type ts aliases, like
+// impl for "type Counter = impl", or "" when ts is no alias of one. Methods
+// declared on impl are Counter's too.
+func aliasedTypeName(ts *ast.TypeSpec) string {
+	if !ts.Assign.IsValid() {
+		return ""
+	}
+	ident, ok := ts.Type.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+
+	return ident.Name
+}
+
+// aliasOwners returns, for each unexported type one of decls gives a public
+// alias to, the name of that alias; the first in name order when there are
+// several.
+func aliasOwners(decls []PublicDeclaration) map[string]string {
+	owners := make(map[string]string)
+	for _, decl := range decls {
+		for _, spec := range decl.GenDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || !isPublicName(ts.Name.Name) {
+				continue
+			}
+			target := aliasedTypeName(ts)
+			if target == "" || isPublicName(target) {
+				continue
+			}
+			if owner, found := owners[target]; !found || ts.Name.Name < owner {
+				owners[target] = ts.Name.Name
+			}
+		}
+	}
+
+	return owners
+}
+
+// associatedTypeName returns the type a const/var block belongs to: a type it
+// references (e.g. "var DefaultServer = &Server{}" or "ModeA Mode = iota"), or
+// failing that the longest type name prefixing its first public name
+// (e.g. "const ServerTimeout = ...").
+func associatedTypeName(d *ast.GenDecl, typeNames map[string]bool) string {
+	for _, spec := range d.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for _, expr := range append([]ast.Expr{vs.Type}, vs.Values...) {
+			if expr == nil {
+				continue
+			}
+			if typeName := referencedTypeName(expr, typeNames); typeName != "" {
+				return typeName
+			}
+		}
+	}
+
+	name := firstPublicName(d)
+	best := ""
+	for typeName := range typeNames {
+		rest := strings.TrimPrefix(name, typeName)
+		if rest == name || len(typeName) <= len(best) {
+			continue
+		}
+		if first, _ := utf8.DecodeRuneInString(rest); rest == "" || unicode.IsUpper(first) || unicode.IsDigit(first) {
+			best = typeName
+		}
+	}
+
+	return best
+}
+
+func referencedTypeName(expr ast.Expr, typeNames map[string]bool) string {
+	found := ""
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if found != "" {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			// pkg.Server is another package's type, only the qualifier can be local
+			found = referencedTypeName(x.X, typeNames)
+
+			return false
+		case *ast.Ident:
+			if typeNames[x.Name] {
+				found = x.Name
+			}
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// findExclusiveHelpers maps each extracted public function to the private
+// functions referenced by it and by nothing else, neither elsewhere in the
+// file nor in any other file of the package.
+func findExclusiveHelpers(node *ast.File, extracted []PublicFunction, siblings []*ast.File, fset *token.FileSet) map[string][]PublicFunction {
+	owners := make(map[string]bool)
+	for _, fn := range extracted {
+		if isPublicName(fn.Name) {
+			owners[fn.Name] = true
+		}
+	}
+
+	var candidates []PublicFunction
+	candidateNames := make(map[string]bool)
+	for _, fn := range extractPublicFunctions(node, Options{IncludePrivate: true}, fset) {
+		if !isPublicName(fn.Name) {
+			candidates = append(candidates, fn)
+			candidateNames[fn.Name] = true
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// referrers records, per helper, which top-level declarations mention it
+	referrers := make(map[string]map[string]bool)
+	addReferences := func(root ast.Node, referrer string, self *ast.Ident) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok || ident == self || !candidateNames[ident.Name] || ident.Name == referrer {
+				return true
+			}
+			if referrers[ident.Name] == nil {
+				referrers[ident.Name] = make(map[string]bool)
+			}
+			referrers[ident.Name][referrer] = true
+
+			return true
+		})
+	}
+	for i, decl := range node.Decls {
+		referrer := fmt.Sprintf("decl %d", i)
+		var self *ast.Ident
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			self = fn.Name
+			if fn.Recv == nil {
+				referrer = fn.Name.Name
+			}
+		}
+		addReferences(decl, referrer, self)
+	}
+	for _, sibling := range siblings {
+		addReferences(sibling, sibling.Name.Name+" sibling", nil)
+	}
+
+	helpers := make(map[string][]PublicFunction)
+	for _, fn := range candidates {
+		if len(referrers[fn.Name]) != 1 {
+			continue
+		}
+		for referrer := range referrers[fn.Name] {
+			if owners[referrer] {
+				helpers[referrer] = append(helpers[referrer], fn)
+			}
+		}
+	}
+
+	return helpers
+}
+
+// withHelpers returns fns with each function's exclusive helpers next to it.
+// They keep their order in the source, since comments are printed by position
+// and a helper declared above its function has to come first; the first of
+// each group takes over the function's file header.
+func withHelpers(fns []PublicFunction, helpers map[string][]PublicFunction) []PublicFunction {
+	if len(helpers) == 0 {
+		return fns
+	}
+
+	result := make([]PublicFunction, 0, len(fns))
+	for _, fn := range fns {
+		group := append([]PublicFunction{fn}, helpers[fn.Name]...)
+		slices.SortStableFunc(group, func(a, b PublicFunction) int {
+			return cmp.Compare(a.FuncDecl.Pos(), b.FuncDecl.Pos())
+		})
+		group[0].Provenance = fn.Provenance
+		result = append(result, group...)
+	}
+
+	return result
+}
+
+// withTestHelpers is withHelpers for tests.
+func withTestHelpers(tests []TestFunction, helpers map[string][]TestFunction) []TestFunction {
+	if len(helpers) == 0 {
+		return tests
+	}
+
+	result := make([]TestFunction, 0, len(tests))
+	for _, test := range tests {
+		group := append([]TestFunction{test}, helpers[test.Name]...)
+		slices.SortStableFunc(group, func(a, b TestFunction) int {
+			return cmp.Compare(a.FuncDecl.Pos(), b.FuncDecl.Pos())
+		})
+		group[0].Provenance = test.Provenance
+		result = append(result, group...)
+	}
+
+	return result
+}
+
+// findStayingDependencies maps each moved function or method, by name (Type.Method
+// for methods), to the unexported top-level identifiers of node it refers to
+// that stay behind: private functions, types, vars and consts that aren't
+// moved themselves. Locals shadowing them are told apart through the
+// parser's object resolution.
+func findStayingDependencies(node *ast.File, moved []*ast.FuncDecl) map[string][]string {
+	movedDecls := make(map[*ast.FuncDecl]bool, len(moved))
+	for _, fn := range moved {
+		movedDecls[fn] = true
+	}
+
+	staying := make(map[*ast.Object]bool)
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && movedDecls[fn] {
+			continue
+		}
+		var names []*ast.Ident
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names = append(names, d.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					names = append(names, s.Names...)
+				case *ast.TypeSpec:
+					names = append(names, s.Name)
+				}
+			}
+		}
+		for _, name := range names {
+			if obj := node.Scope.Lookup(name.Name); obj != nil && !isPublicName(name.Name) && name.Name != "_" {
+				staying[obj] = true
+			}
+		}
+	}
+	if len(staying) == 0 {
+		return nil
+	}
+
+	dependencies := make(map[string][]string)
+	for _, fn := range moved {
+		name := fn.Name.Name
+		if fn.Recv != nil {
+			name = getReceiverTypeName(fn.Recv) + "." + name
+		}
+
+		seen := make(map[string]bool)
+		ast.Inspect(fn, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Obj != nil && staying[ident.Obj] && !seen[ident.Name] {
+				seen[ident.Name] = true
+				dependencies[name] = append(dependencies[name], ident.Name)
+			}
+
+			return true
+		})
+		sort.Strings(dependencies[name])
+	}
+
+	return dependencies
+}
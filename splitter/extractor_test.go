@@ -4,6 +4,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"slices"
 	"testing"
 )
 
@@ -32,7 +33,7 @@ func (r *Receiver) PublicMethod() {}
 		t.Fatalf("Failed to parse: %v", err)
 	}
 
-	funcs := extractPublicFunctions(node)
+	funcs := extractPublicFunctions(node, Options{}, fset)
 
 	if len(funcs) != 1 {
 		t.Errorf("Expected 1 public function, got %d", len(funcs))
@@ -103,7 +104,7 @@ func RegularFunc() {}
 		t.Fatalf("Failed to parse: %v", err)
 	}
 
-	methods := extractPublicMethods(node)
+	methods := extractPublicMethods(node, fset)
 
 	// PublicOnPrivate is also extracted since the method itself is public
 	if len(methods) != 3 {
@@ -129,6 +130,11 @@ func TestGetReceiverTypeName(t *testing.T) {
 func (m MyStruct) Method1() {}
 func (m *MyStruct) Method2() {}
 func (m AnotherStruct) Method3() {}
+func (s Stack[T]) Peek() T {}
+func (s *Stack[T]) Push(v T) {}
+func (m Map[K, V]) Get(k K) V {}
+func (m *Map[K, V]) Set(k K, v V) {}
+func (m *(MyStruct)) Method4() {}
 `
 
 	fset := token.NewFileSet()
@@ -144,6 +150,11 @@ func (m AnotherStruct) Method3() {}
 		{0, "MyStruct"},
 		{1, "MyStruct"},
 		{2, "AnotherStruct"},
+		{3, "Stack"},
+		{4, "Stack"},
+		{5, "Map"},
+		{6, "Map"},
+		{7, "MyStruct"},
 	}
 
 	for i, test := range tests {
@@ -177,7 +188,7 @@ func helperFunc() {} // Should be ignored
 		t.Fatalf("Failed to parse: %v", err)
 	}
 
-	tests := extractTestFunctions(node)
+	tests := extractTestFunctions(node, fset)
 
 	if len(tests) != 3 {
 		t.Errorf("Expected 3 test functions, got %d", len(tests))
@@ -195,3 +206,208 @@ func helperFunc() {} // Should be ignored
 		}
 	}
 }
+
+func TestExtractWithUnicodeIdentifiers(t *testing.T) {
+	src := `package test
+
+const Größe = 1
+const äpfel = 2
+
+type Ärger struct{}
+
+func Δelta() {}
+func δelta() {}
+func ärger() {}
+
+func (a Ärger) Öffnen() {}
+func (a Ärger) öffnen() {}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	funcs := extractPublicFunctions(node, Options{}, fset)
+	if len(funcs) != 1 || funcs[0].Name != "Δelta" {
+		t.Errorf("Expected only Δelta to be extracted, got %v", funcs)
+	}
+
+	decls := extractPublicDeclarations(node)
+	if len(decls) != 2 {
+		t.Errorf("Expected 2 public declarations, got %d", len(decls))
+	}
+
+	methods := extractPublicMethods(node, fset)
+	if len(methods) != 1 || methods[0].Name != "Öffnen" || methods[0].ReceiverType != "Ärger" {
+		t.Errorf("Expected only Ärger.Öffnen to be extracted, got %v", methods)
+	}
+}
+
+func TestExtractSkipsUnnamedDeclarations(t *testing.T) {
+	src := `package test
+
+import "testing"
+
+const Limit = 1
+
+type Config struct{}
+
+func Parse() {}
+
+func Format() {}
+
+func (c Config) Load() {}
+
+func (c Config) save() {}
+
+func TestParse(t *testing.T) {}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test_test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// Blank every name, as a partial AST may have them
+	for _, decl := range node.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			d.Name.Name = ""
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					s.Names[0].Name = ""
+				case *ast.TypeSpec:
+					s.Name.Name = ""
+				}
+			}
+		}
+	}
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			fn.Name = nil
+
+			break
+		}
+	}
+
+	if funcs := extractPublicFunctions(node, Options{IncludePrivate: true}, fset); len(funcs) != 0 {
+		t.Errorf("Expected no functions, got %v", funcs)
+	}
+	if decls := extractPublicDeclarations(node); len(decls) != 0 {
+		t.Errorf("Expected no declarations, got %v", decls)
+	}
+	if tests := extractTestFunctions(node, fset); len(tests) != 0 {
+		t.Errorf("Expected no tests, got %v", tests)
+	}
+	if methods := extractPublicMethods(node, fset); len(methods) != 0 {
+		t.Errorf("Expected no public methods, got %v", methods)
+	}
+	if methods := extractPrivateMethods(node, fset); len(methods) != 0 {
+		t.Errorf("Expected no private methods, got %v", methods)
+	}
+}
+
+func TestAssociatedTypeName(t *testing.T) {
+	src := `package test
+
+import "net/http"
+
+var DefaultServer = &Server{}
+
+const ServerTimeout = 30
+
+const (
+	ModeRead Mode = iota
+	ModeWrite
+)
+
+var DefaultHandler http.Handler
+
+var Version = "v1"
+
+var ServerlessEnabled = true
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	typeNames := map[string]bool{"Server": true, "Mode": true, "Handler": true}
+	expected := []string{"Server", "Server", "Mode", "", "", ""}
+
+	decls := extractPublicDeclarations(node)
+	if len(decls) != len(expected) {
+		t.Fatalf("Expected %d declarations, got %d", len(expected), len(decls))
+	}
+	for i, decl := range decls {
+		if got := associatedTypeName(decl.GenDecl, typeNames); got != expected[i] {
+			t.Errorf("associatedTypeName(%s) = %q, want %q", firstPublicName(decl.GenDecl), got, expected[i])
+		}
+	}
+}
+
+func TestExtractAPI(t *testing.T) {
+	src := `package shop
+
+// Cart holds items.
+type Cart struct{ items []string }
+
+// Add adds an item.
+func (c *Cart) Add(item string) {
+	// Duplicates are allowed
+	c.items = append(c.items, item)
+}
+
+func (c *Cart) reset() {}
+
+// Checkout settles the cart.
+func Checkout(c *Cart) error { return nil }
+
+func total() int { return 0 }
+
+func TestCheckout(t *testing.T) {}
+
+func BenchmarkCheckout(b *testing.B) {}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "shop.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	funcs := ExtractPublicFunctions(node, fset)
+	var names []string
+	for _, fn := range funcs {
+		names = append(names, fn.Name)
+	}
+	if want := []string{"Checkout", "TestCheckout", "BenchmarkCheckout"}; !slices.Equal(names, want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+	if funcs[0].Comments == nil || funcs[0].Comments.Text() != "Checkout settles the cart.\n" {
+		t.Errorf("Expected the doc comment of Checkout, got %v", funcs[0].Comments)
+	}
+	if funcs[0].Package != "shop" || funcs[0].FuncDecl != node.Decls[3] {
+		t.Errorf("Expected Checkout to share its declaration with node, got %+v", funcs[0])
+	}
+
+	methods := ExtractPublicMethods(node, fset)
+	if len(methods) != 1 || methods[0].Name != "Add" || methods[0].ReceiverType != "Cart" {
+		t.Fatalf("Expected Cart.Add, got %+v", methods)
+	}
+	if len(methods[0].InlineComments) != 1 {
+		t.Errorf("Expected the comment inside Add, got %d", len(methods[0].InlineComments))
+	}
+
+	tests := ExtractTestFunctions(node, fset)
+	if len(tests) != 1 || tests[0].Name != "TestCheckout" {
+		t.Errorf("Expected TestCheckout, got %+v", tests)
+	}
+}
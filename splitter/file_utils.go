@@ -1,14 +1,34 @@
 package splitter
 
 import (
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
-func findGoFiles(directory string) ([]string, error) {
+// checkDirectory returns ErrDirectoryNotFound when directory doesn't exist,
+// rather than the error walking it would fail with.
+func checkDirectory(directory string) error {
+	if _, err := os.Stat(directory); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrDirectoryNotFound, directory)
+		}
+
+		return fmt.Errorf("failed to access directory: %w", err)
+	}
+
+	return nil
+}
+
+func findGoFiles(directory string, opts Options) ([]string, error) {
 	var goFiles []string
 
 	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
@@ -17,12 +37,20 @@ func findGoFiles(directory string) ([]string, error) {
 		}
 
 		if d.IsDir() {
+			if (path != directory && isIgnoredDir(d.Name(), opts)) || exceedsMaxDepth(directory, path, opts.MaxDepth) {
+				return fs.SkipDir
+			}
+
 			return nil
 		}
 
-		if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			goFiles = append(goFiles, path)
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		if skip, err := skipsFile(path, opts); err != nil || skip {
+			return err
 		}
+		goFiles = append(goFiles, path)
 
 		return nil
 	})
@@ -33,7 +61,7 @@ func findGoFiles(directory string) ([]string, error) {
 	return goFiles, nil
 }
 
-func findTestFiles(directory string) ([]string, error) {
+func findTestFiles(directory string, opts Options) ([]string, error) {
 	var testFiles []string
 
 	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
@@ -42,12 +70,20 @@ func findTestFiles(directory string) ([]string, error) {
 		}
 
 		if d.IsDir() {
+			if (path != directory && isIgnoredDir(d.Name(), opts)) || exceedsMaxDepth(directory, path, opts.MaxDepth) {
+				return fs.SkipDir
+			}
+
 			return nil
 		}
 
-		if strings.HasSuffix(path, "_test.go") {
-			testFiles = append(testFiles, path)
+		if !strings.HasSuffix(path, "_test.go") {
+			return nil
 		}
+		if skip, err := skipsFile(path, opts); err != nil || skip {
+			return err
+		}
+		testFiles = append(testFiles, path)
 
 		return nil
 	})
@@ -58,11 +94,334 @@ func findTestFiles(directory string) ([]string, error) {
 	return testFiles, nil
 }
 
-func findCorrespondingTestFile(filename string, _ string) string {
+// skipsFile reports whether a Go file found while walking is left out: its
+// base name doesn't match opts.IncludeGlob or matches opts.ExcludeGlob, or it
+// is generated code, unless opts.ProcessGenerated.
+func skipsFile(path string, opts Options) (bool, error) {
+	name := filepath.Base(path)
+	if opts.IncludeGlob != "" {
+		match, err := filepath.Match(opts.IncludeGlob, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to match include glob: %w", err)
+		}
+		if !match {
+			return true, nil
+		}
+	}
+	if opts.ExcludeGlob != "" {
+		match, err := filepath.Match(opts.ExcludeGlob, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to match exclude glob: %w", err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+
+	if opts.ProcessGenerated {
+		return false, nil
+	}
+	generated, err := isGeneratedFile(path)
+	if err != nil {
+		return false, err
+	}
+	if generated {
+		opts.logf("Skipping %s: generated file\n", path)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// generatedPattern matches the comment marking generated code, as described
+// at https://go.dev/s/generatedcode.
+var generatedPattern = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`) //nolint:gochecknoglobals
+
+// isGeneratedFile reports whether the file at path has a line marking it as
+// generated code before its package clause. Files that don't parse are left
+// for the split to report.
+func isGeneratedFile(path string) (bool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// A file that doesn't parse is left for the split to report
+	node, _ := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+	for _, group := range node.Comments {
+		if group.Pos() > node.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if generatedPattern.MatchString(comment.Text) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isIgnoredDir reports whether a directory is skipped while walking: vendor
+// (unless opts.IncludeVendor), testdata, and directories starting with "." or
+// "_", which the go tool ignores as well.
+func isIgnoredDir(name string, opts Options) bool {
+	switch {
+	case name == "vendor":
+		return !opts.IncludeVendor
+	case name == "testdata":
+		return true
+	default:
+		return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+	}
+}
+
+// exceedsMaxDepth reports whether dir lies deeper below root than maxDepth allows.
+// A nil maxDepth means there is no limit.
+func exceedsMaxDepth(root, dir string, maxDepth *int) bool {
+	if maxDepth == nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	depth := len(strings.Split(rel, string(filepath.Separator)))
+
+	return depth > *maxDepth
+}
+
+// testFileSuffix returns the suffix of generated test file names: _test.go
+// unless TestFileSuffix says otherwise. A suffix not ending in _test.go, like
+// "_internal", gets it appended.
+func (opts Options) testFileSuffix() string {
+	suffix := opts.TestFileSuffix
+	if !strings.HasSuffix(suffix, "_test.go") {
+		suffix = strings.TrimSuffix(suffix, ".go") + "_test.go"
+	}
+
+	return suffix
+}
+
+// goFileName returns the name of the file generated for the snake_case name
+// (e.g. "parse_config.go"). Files split from a platform-specific file, like
+// server_linux.go, keep its suffix: run_linux.go.
+func (opts Options) goFileName(name string) string {
+	return opts.withBuildSuffix(avoidReservedFileName(name) + ".go")
+}
+
+// functionFileName returns the name of the file function fn of node, parsed
+// from filename, is written to. Under NumberPrefix, the name starts with the
+// position of fn among the declarations of node, zero-padded like 02_parse.go.
+// A function already alone in a numbered file keeps its number, so splitting
+// again doesn't renumber files.
+func (opts Options) functionFileName(filename string, node *ast.File, fn *ast.FuncDecl) string {
+	if opts.buckets > 0 {
+		return opts.bucketFileName(opts.fileBaseName(fn.Name.Name))
+	}
+
+	name := opts.goFileName(opts.fileBaseName(fn.Name.Name))
+	if !opts.NumberPrefix {
+		return name
+	}
+	if base := filepath.Base(filename); trimNumberPrefix(base) == name {
+		return base
+	}
+
+	count, position := 0, 0
+	for _, decl := range node.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			continue
+		}
+		count++
+		if decl == fn {
+			position = count
+		}
+	}
+
+	return fmt.Sprintf("%0*d_%s", max(2, len(strconv.Itoa(count))), position, name)
+}
+
+// alphabetSize is the number of letters bucket files divide names among.
+const alphabetSize = 26
+
+// bucketCount returns how many files the functions of filename are gathered
+// in when splitting it with a file per function would leave more than
+// opts.MaxFilesPerDirectory Go files in its directory, counting the method,
+// type and common files it writes too, or 0 when they fit. The count only
+// depends on the limit, and a bucket file keeps being split into buckets, so
+// splitting again leaves functions where they are.
+func (opts Options) bucketCount(filename string, functions int) (int, error) {
+	if opts.MaxFilesPerDirectory <= 0 || functions == 0 {
+		return 0, nil
+	}
+
+	buckets := min(opts.MaxFilesPerDirectory, alphabetSize)
+	if isBucketFileName(filepath.Base(filename)) {
+		return buckets, nil
+	}
+
+	// Plan the split without buckets to learn every file it writes
+	unbucketed := opts
+	unbucketed.MaxFilesPerDirectory = 0
+	plan, err := analyzeFile(filename, unbucketed)
+	if err != nil {
+		return 0, err
+	}
+	targets := make(map[string]bool)
+	for _, symbol := range plan.Symbols {
+		if symbol.Target != filename {
+			targets[filepath.Base(symbol.Target)] = true
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read directory: %w", err)
+	}
+	files := len(targets)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") && name != filepath.Base(filename) && !targets[name] {
+			files++
+		}
+	}
+	if files <= opts.MaxFilesPerDirectory {
+		return 0, nil
+	}
+
+	return buckets, nil
+}
+
+// bucketFileName returns the name of the bucket file holding the function
+// whose file base name is base: the one whose range of the alphabet covers
+// its first letter. Names starting with anything else than a-z go to the
+// first or the last bucket.
+func (opts Options) bucketFileName(base string) string {
+	letter := 0
+	if base != "" {
+		letter = min(max(int(base[0])-'a', 0), alphabetSize-1)
+	}
+
+	bucket := letter * opts.buckets / alphabetSize
+	from := (bucket*alphabetSize + opts.buckets - 1) / opts.buckets
+	to := ((bucket+1)*alphabetSize+opts.buckets-1)/opts.buckets - 1
+
+	return opts.goFileName(fmt.Sprintf("%c_%c", 'a'+from, 'a'+to))
+}
+
+// isBucketFileName reports whether name looks like a bucket file, such as
+// a_i.go or a_i_linux.go.
+func isBucketFileName(name string) bool {
+	stem := strings.TrimSuffix(name, ".go")
+	if len(stem) < 3 || (len(stem) > 3 && stem[3] != '_') {
+		return false
+	}
+
+	return stem[0] >= 'a' && stem[0] <= 'z' && stem[1] == '_' && stem[2] >= 'a' && stem[2] <= 'z' && stem[0] <= stem[2]
+}
+
+// trimNumberPrefix removes a NumberPrefix prefix, like "02_", from the file
+// name base.
+func trimNumberPrefix(base string) string {
+	rest := strings.TrimLeft(base, "0123456789")
+	if len(rest) == len(base) {
+		return base
+	}
+	if rest, ok := strings.CutPrefix(rest, "_"); ok {
+		return rest
+	}
+
+	return base
+}
+
+// withBuildSuffix inserts the GOOS/GOARCH suffix of the file being split
+// into filename, before its _test.go or .go extension.
+func (opts Options) withBuildSuffix(filename string) string {
+	if opts.buildSuffix == "" {
+		return filename
+	}
+	for _, ext := range []string{"_test.go", ".go"} {
+		if base, ok := strings.CutSuffix(filename, ext); ok {
+			return base + opts.buildSuffix + ext
+		}
+	}
+
+	return filename
+}
+
+// splitFileSuffix returns the suffix of the files split from filename, whose
+// //go:build lines are constraint. A file restricted by its name keeps its
+// GOOS/GOARCH suffix; one restricted by //go:build lines alone passes on the
+// last segment of its name (_unix for server_unix.go), which keeps the files
+// split from it apart from unrestricted ones like common.go.
+func splitFileSuffix(filename, constraint string) string {
+	if suffix := buildSuffix(filename); suffix != "" || constraint == "" {
+		return suffix
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), ".go"), "_test")
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return "_" + name
+}
+
+// buildSuffix returns the _GOOS, _GOARCH or _GOOS_GOARCH suffix of a Go file
+// name, like "_linux" for server_linux.go or server_linux_test.go, which
+// restricts the file to those platforms. It returns "" for other names.
+func buildSuffix(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), ".go")
+	name = strings.TrimSuffix(name, "_test")
+	parts := strings.Split(name, "_")
+
+	n := len(parts)
+	if n >= 3 && isKnownOS(parts[n-2]) && isKnownArch(parts[n-1]) {
+		return "_" + parts[n-2] + "_" + parts[n-1]
+	}
+	if n >= 2 && (isKnownOS(parts[n-1]) || isKnownArch(parts[n-1])) {
+		return "_" + parts[n-1]
+	}
+
+	return ""
+}
+
+// isKnownOS reports whether name is a GOOS the go tool recognizes in file
+// names.
+func isKnownOS(name string) bool {
+	switch name {
+	case "aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+		"linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos":
+		return true
+	default:
+		return false
+	}
+}
+
+// isKnownArch reports whether name is a GOARCH the go tool recognizes in file
+// names.
+func isKnownArch(name string) bool {
+	switch name {
+	case "386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64",
+		"mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le",
+		"ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm":
+		return true
+	default:
+		return false
+	}
+}
+
+// findCorrespondingTestFile returns the test file of filename named with
+// suffix, like user_test.go for user.go, if it exists.
+func findCorrespondingTestFile(filename, suffix string) string {
 	dir := filepath.Dir(filename)
 	base := filepath.Base(filename)
 	base = strings.TrimSuffix(base, ".go")
-	testFile := filepath.Join(dir, base+"_test.go")
+	testFile := filepath.Join(dir, base+suffix)
 
 	if _, err := os.Stat(testFile); err == nil {
 		return testFile
@@ -70,3 +429,217 @@ func findCorrespondingTestFile(filename string, _ string) string {
 
 	return ""
 }
+
+// testFileFor returns the file the tests of package pkg named after base are
+// written to: base with the test file suffix, unless that file exists and
+// belongs to another package. Black-box (package foo_test) and white-box
+// tests are never mixed in one file, so base_external_test.go or
+// base_internal_test.go is used instead.
+func (opts Options) testFileFor(outputDir, base, pkg string) (string, error) {
+	candidates := []string{opts.withBuildSuffix(base + opts.testFileSuffix())}
+	if strings.HasSuffix(pkg, "_test") {
+		candidates = append(candidates, opts.withBuildSuffix(base+"_external"+opts.testFileSuffix()))
+	} else {
+		candidates = append(candidates, opts.withBuildSuffix(base+"_internal"+opts.testFileSuffix()))
+	}
+
+	for _, candidate := range candidates {
+		filename := filepath.Join(outputDir, candidate)
+		node, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly)
+		if os.IsNotExist(err) || (err == nil && node.Name.Name == pkg) {
+			return filename, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse existing file %s: %w", filename, err)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrTestFileTaken, filepath.Join(outputDir, candidates[0]))
+}
+
+// commonFileFor returns the file the public declarations of package pkg are
+// gathered in: common.go, unless it exists and belongs to another package,
+// like a tool in package main next to a library. Then common_<pkg>.go is
+// used, so packages sharing a directory never share a common file.
+func (opts Options) commonFileFor(outputDir, pkg string) (string, error) {
+	candidates := []string{
+		opts.withBuildSuffix(commonFileName),
+		opts.goFileName("common_" + pkg),
+	}
+
+	for _, candidate := range candidates {
+		filename := filepath.Join(outputDir, candidate)
+		node, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly)
+		if os.IsNotExist(err) || (err == nil && node.Name.Name == pkg) {
+			return filename, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse existing file %s: %w", filename, err)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrCommonFileTaken, filepath.Join(outputDir, candidates[0]))
+}
+
+// parseSiblingFiles parses the other Go files, tests included, that live in
+// the same directory as filename.
+func parseSiblingFiles(filename string) ([]*ast.File, error) {
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(path, ".go") || path == filename {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// siblingTypeNames returns, for each receiver type whose methods belong to an
+// exported type declared by the other files of package packageName in the
+// directory of filename, the name of that type: the exported type itself, or
+// the public alias of an unexported one.
+func siblingTypeNames(filename, packageName string) (map[string]string, error) {
+	siblings, err := parseSiblingFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	var decls []PublicDeclaration
+	for _, file := range siblings {
+		if file.Name.Name != packageName {
+			continue
+		}
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.IsExported() {
+					names[ts.Name.Name] = ts.Name.Name
+				}
+			}
+			decls = append(decls, PublicDeclaration{GenDecl: genDecl})
+		}
+	}
+	for target, alias := range aliasOwners(decls) {
+		names[target] = alias
+	}
+
+	return names, nil
+}
+
+// packageTypeNames returns the names of the types declared in node and the
+// other files of its package in the directory of filename, along with the
+// receiver types of their methods. Files that don't parse are left out, and
+// the directory is only read when node has types or methods to name files
+// after.
+func packageTypeNames(filename string, node *ast.File) ([]string, error) {
+	names := declaredTypeNames(node)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(path, ".go") || path == filename {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil || file.Name.Name != node.Name.Name {
+			continue
+		}
+		names = append(names, declaredTypeNames(file)...)
+	}
+
+	return names, nil
+}
+
+// declaredTypeNames returns the names of the types file declares and of the
+// receiver types of its methods.
+func declaredTypeNames(file *ast.File) []string {
+	var names []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					names = append(names, ts.Name.Name)
+				}
+			}
+		case *ast.FuncDecl:
+			if name := getReceiverTypeName(d.Recv); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// declaringFiles returns, for each function and method declared in the other
+// non-test Go files of filename's directory, the file declaring it. Methods
+// are keyed "Type.Method".
+func declaringFiles(filename string) (map[string]string, error) {
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	files := make(map[string]string)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") || path == filename {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				files[funcDeclKey(fn)] = path
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// funcDeclKey returns the name of fn, prefixed with its receiver type for a
+// method: "Parse" or "Server.Start".
+func funcDeclKey(fn *ast.FuncDecl) string {
+	if fn.Recv != nil {
+		return getReceiverTypeName(fn.Recv) + "." + fn.Name.Name
+	}
+
+	return fn.Name.Name
+}
@@ -1,8 +1,10 @@
 package splitter
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -22,15 +24,235 @@ func TestFindCorrespondingTestFile(t *testing.T) {
 	}
 
 	// Test finding corresponding test file
-	found := findCorrespondingTestFile(mainFile, "Example")
+	found := findCorrespondingTestFile(mainFile, "_test.go")
 	if found != testFile {
 		t.Errorf("Expected to find %s, got %s", testFile, found)
 	}
 
 	// Test when test file doesn't exist
 	nonExistent := filepath.Join(tmpDir, "nonexistent.go")
-	found = findCorrespondingTestFile(nonExistent, "NonExistent")
+	found = findCorrespondingTestFile(nonExistent, "_test.go")
 	if found != "" {
 		t.Errorf("Expected empty string for non-existent test file, got %s", found)
 	}
 }
+
+func TestFindGoFilesMaxDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []string{
+		"root.go",
+		filepath.Join("a", "level1.go"),
+		filepath.Join("a", "level1_test.go"),
+		filepath.Join("a", "b", "level2.go"),
+		filepath.Join("a", "b", "level2_test.go"),
+	}
+	for _, file := range files {
+		path := filepath.Join(tmpDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package test"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		maxDepth      *int
+		expectedGo    int
+		expectedTests int
+	}{
+		{"unlimited", nil, 3, 2},
+		{"root only", intPtr(0), 1, 0},
+		{"one level", intPtr(1), 2, 1},
+		{"deeper than tree", intPtr(5), 3, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			goFiles, err := findGoFiles(tmpDir, Options{MaxDepth: tc.maxDepth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(goFiles) != tc.expectedGo {
+				t.Errorf("findGoFiles returned %d files, want %d: %v", len(goFiles), tc.expectedGo, goFiles)
+			}
+
+			testFiles, err := findTestFiles(tmpDir, Options{MaxDepth: tc.maxDepth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(testFiles) != tc.expectedTests {
+				t.Errorf("findTestFiles returned %d files, want %d: %v", len(testFiles), tc.expectedTests, testFiles)
+			}
+		})
+	}
+}
+
+func TestFindGoFilesSkipsIgnoredDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []string{
+		"root.go",
+		filepath.Join("vendor", "example.com", "dep", "dep.go"),
+		filepath.Join("testdata", "input.go"),
+		filepath.Join(".git", "hooks.go"),
+		filepath.Join("_build", "gen.go"),
+		filepath.Join("pkg", "pkg.go"),
+		filepath.Join("pkg", "pkg_test.go"),
+		filepath.Join("pkg", "testdata", "golden_test.go"),
+	}
+	for _, file := range files {
+		path := filepath.Join(tmpDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package test"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          Options
+		expectedGo    int
+		expectedTests int
+	}{
+		{"defaults", Options{}, 2, 1},
+		{"include vendor", Options{IncludeVendor: true}, 3, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			goFiles, err := findGoFiles(tmpDir, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(goFiles) != tc.expectedGo {
+				t.Errorf("findGoFiles returned %d files, want %d: %v", len(goFiles), tc.expectedGo, goFiles)
+			}
+
+			testFiles, err := findTestFiles(tmpDir, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(testFiles) != tc.expectedTests {
+				t.Errorf("findTestFiles returned %d files, want %d: %v", len(testFiles), tc.expectedTests, testFiles)
+			}
+		})
+	}
+}
+
+func TestFindFiles_GlobsAndGenerated(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"user_handler.go":      "package api\n",
+		"user_handler_test.go": "package api\n",
+		"order_handler.go":     "package api\n",
+		"store.go":             "package api\n",
+		"store_test.go":        "package api\n",
+		"zz_deepcopy.go":       "// Code generated by deepcopy-gen. DO NOT EDIT.\n\npackage api\n",
+		"mock_store_test.go":   "// Copyright 2024 Example.\n\n// Code generated by MockGen. DO NOT EDIT.\n// Source: store.go\n\npackage api\n",
+		"not_generated.go":     "package api\n\n// Code generated by hand. DO NOT EDIT.\nvar X = 1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantGo    []string
+		wantTests []string
+	}{
+		{
+			name:      "generated files are skipped",
+			opts:      Options{},
+			wantGo:    []string{"not_generated.go", "order_handler.go", "store.go", "user_handler.go"},
+			wantTests: []string{"store_test.go", "user_handler_test.go"},
+		},
+		{
+			name:      "include glob",
+			opts:      Options{IncludeGlob: "*_handler*.go"},
+			wantGo:    []string{"order_handler.go", "user_handler.go"},
+			wantTests: []string{"user_handler_test.go"},
+		},
+		{
+			name:      "exclude glob",
+			opts:      Options{ExcludeGlob: "user_*"},
+			wantGo:    []string{"not_generated.go", "order_handler.go", "store.go"},
+			wantTests: []string{"store_test.go"},
+		},
+		{
+			name:      "include and exclude globs",
+			opts:      Options{IncludeGlob: "*_handler.go", ExcludeGlob: "order_*"},
+			wantGo:    []string{"user_handler.go"},
+			wantTests: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Output = io.Discard
+			goFiles, err := findGoFiles(tmpDir, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := baseNames(goFiles); !slices.Equal(got, tc.wantGo) {
+				t.Errorf("findGoFiles returned %v, want %v", got, tc.wantGo)
+			}
+
+			testFiles, err := findTestFiles(tmpDir, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := baseNames(testFiles); !slices.Equal(got, tc.wantTests) {
+				t.Errorf("findTestFiles returned %v, want %v", got, tc.wantTests)
+			}
+		})
+	}
+
+	if _, err := findGoFiles(tmpDir, Options{IncludeGlob: "[", Output: io.Discard}); err == nil {
+		t.Error("Expected an error for a malformed glob")
+	}
+}
+
+func baseNames(paths []string) []string {
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+
+	return names
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestSplitFileSuffix(t *testing.T) {
+	tests := []struct {
+		filename   string
+		constraint string
+		want       string
+	}{
+		{filename: "server.go", want: ""},
+		{filename: "server_linux.go", want: "_linux"},
+		{filename: "server_arm64.go", want: "_arm64"},
+		{filename: "server_linux_amd64_test.go", want: "_linux_amd64"},
+		{filename: "linux.go", want: ""},
+		{filename: "server_unix.go", want: ""},
+		{filename: "server_unix.go", constraint: "//go:build unix", want: "_unix"},
+		{filename: "integration_test.go", constraint: "//go:build integration", want: "_integration"},
+		{filename: "server_windows.go", constraint: "//go:build windows", want: "_windows"},
+	}
+
+	for _, tt := range tests {
+		if got := splitFileSuffix(tt.filename, tt.constraint); got != tt.want {
+			t.Errorf("splitFileSuffix(%q, %q) = %q, want %q", tt.filename, tt.constraint, got, tt.want)
+		}
+	}
+}
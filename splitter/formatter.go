@@ -0,0 +1,72 @@
+package splitter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// CommandFormatter returns a formatter for Options.Formatter that pipes the
+// source through command and takes what it writes to stdout, like gofumpt or
+// goimports do when given no file. The command is split at spaces, without
+// shell quoting: "goimports -local example.com". An empty command returns
+// nil, leaving files as gofmt writes them.
+func CommandFormatter(command string) func([]byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil
+	}
+
+	return func(src []byte) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(src)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("failed to run %s: %w: %s", args[0], err, msg)
+			}
+
+			return nil, fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+
+		return stdout.Bytes(), nil
+	}
+}
+
+// formatWritten runs opts.Formatter over each file the run created or
+// updated, once all of them are written, so files several source files add
+// to are formatted as a whole. Files it leaves as they are aren't rewritten.
+func formatWritten(opts Options) error {
+	if opts.Formatter == nil || opts.Result == nil {
+		return nil
+	}
+
+	for _, path := range slices.Concat(opts.Result.CreatedFiles, opts.Result.UpdatedFiles) {
+		src, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// Written and then deleted again, like a test file whose helpers moved
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		formatted, err := opts.Formatter(src)
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", path, err)
+		}
+		if bytes.Equal(formatted, src) {
+			continue
+		}
+		if err := writeSource(path, formatted); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
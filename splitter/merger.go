@@ -0,0 +1,238 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var ErrNothingToMerge = errors.New("no go files to merge")
+
+// MergePackage recombines the non-test Go files of a directory into targetFile,
+// the inverse of splitting. Files are concatenated in file name order, imports
+// are de-duplicated and pruned, and the merged files are removed. Files of
+// another package, with build constraints or using cgo are left alone.
+// Progress lines and warnings go to opts.Output.
+func MergePackage(directory, targetFile string, opts Options) error {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var filenames []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filenames = append(filenames, filepath.Join(directory, name))
+	}
+	sort.Strings(filenames)
+
+	fset := token.NewFileSet()
+	var (
+		source sourceMerger
+		merged []string
+	)
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+
+		if (source.packageName != "" && node.Name.Name != source.packageName) || hasBuildConstraint(node) || findCgoImportDecl(node.Decls) != nil {
+			opts.logf("Warning: not merging %s: different package, build constraints or cgo\n", filename)
+
+			continue
+		}
+
+		if err := source.add(fset, src, node); err != nil {
+			return err
+		}
+		merged = append(merged, filename)
+	}
+
+	if len(merged) == 0 {
+		return ErrNothingToMerge
+	}
+
+	if err := writeMergedFile(targetFile, source.String()); err != nil {
+		return err
+	}
+	opts.logf("Created: %s (merged %d files)\n", targetFile, len(merged))
+
+	for _, filename := range merged {
+		if filepath.Clean(filename) == filepath.Clean(targetFile) {
+			continue
+		}
+		if err := os.Remove(filename); err != nil {
+			return fmt.Errorf("failed to delete merged file: %w", err)
+		}
+		opts.logf("Deleted merged: %s\n", filename)
+	}
+
+	return nil
+}
+
+// sourceMerger concatenates the sources of one package's files: the comments
+// above the package clause of the first, like a license and the build
+// constraint but not the provenance of a split file, which names only one of
+// the files, the first package doc, every distinct import and everything after
+// the imports.
+type sourceMerger struct {
+	packageName string
+	constraint  string
+	header      []string
+	packageDoc  string
+	importTexts []string
+	bodies      []string
+}
+
+// add adds the source of node. Files built under different constraints can't
+// be merged, since the code of one would be built under the other's.
+func (m *sourceMerger) add(fset *token.FileSet, src []byte, node *ast.File) error {
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	constraint := buildConstraintLines(node)
+	if m.packageName == "" {
+		m.packageName = node.Name.Name
+		m.constraint = constraint
+		for _, cg := range node.Comments {
+			if cg.Pos() >= node.Package {
+				break
+			}
+			if cg != node.Doc && !strings.HasPrefix(cg.List[0].Text, provenancePrefix) {
+				m.header = append(m.header, string(src[offset(cg.Pos()):offset(cg.End())]))
+			}
+		}
+	} else if constraint != m.constraint {
+		return fmt.Errorf("%w: %q and %q", ErrConstraintMismatch, m.constraint, constraint)
+	}
+	if m.packageDoc == "" && node.Doc != nil {
+		m.packageDoc = string(src[offset(node.Doc.Pos()):offset(node.Doc.End())])
+	}
+
+	// Everything after the package clause and imports is copied verbatim
+	bodyStart := node.Name.End()
+	for _, imp := range node.Imports {
+		importText := string(src[offset(imp.Pos()):offset(imp.End())])
+		if !slices.Contains(m.importTexts, importText) {
+			m.importTexts = append(m.importTexts, importText)
+		}
+	}
+	for _, decl := range node.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			bodyStart = genDecl.End()
+		}
+	}
+	m.bodies = append(m.bodies, strings.TrimSpace(string(src[offset(bodyStart):])))
+
+	return nil
+}
+
+func (m *sourceMerger) String() string {
+	var buf strings.Builder
+	for _, header := range m.header {
+		buf.WriteString(header + "\n\n")
+	}
+	if m.packageDoc != "" {
+		buf.WriteString(m.packageDoc + "\n")
+	}
+	buf.WriteString("package " + m.packageName + "\n\n")
+	if len(m.importTexts) > 0 {
+		buf.WriteString("import (\n\t" + strings.Join(m.importTexts, "\n\t") + "\n)\n\n")
+	}
+	buf.WriteString(strings.Join(m.bodies, "\n\n") + "\n")
+
+	return buf.String()
+}
+
+// mergeSources writes the sources of one package's files, in order, merged
+// into filename.
+func mergeSources(filename string, sources ...[]byte) error {
+	var merged sourceMerger
+	for _, src := range sources {
+		fset := token.NewFileSet()
+		node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		if err := merged.add(fset, src, node); err != nil {
+			return fmt.Errorf("failed to merge into %s: %w", filename, err)
+		}
+	}
+
+	return writeMergedFile(filename, merged.String())
+}
+
+// writeMergedFile parses the concatenated source, drops the imports nothing
+// uses any more and writes it formatted to filename. Nothing is written when
+// two of the imports left bind the same name to different packages.
+func writeMergedFile(filename, src string) error {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse merged source: %w", err)
+	}
+
+	if len(node.Imports) > 0 {
+		importDecl, ok := node.Decls[0].(*ast.GenDecl)
+		if !ok {
+			return ErrTypeCast
+		}
+
+		used := make(map[*ast.ImportSpec]bool)
+		for _, imp := range findUsedImportsInDecls(node.Decls[1:], node.Imports) {
+			used[imp] = true
+		}
+
+		var specs []ast.Spec
+		for _, imp := range node.Imports {
+			// Blank and dot imports are kept for their side effects
+			if used[imp] || (imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".")) {
+				specs = append(specs, imp)
+			}
+		}
+
+		paths := make(map[string]string)
+		for _, spec := range specs {
+			imp, ok := spec.(*ast.ImportSpec)
+			if !ok {
+				return ErrTypeCast
+			}
+			name := importName(imp)
+			if name == "_" || name == "." {
+				continue
+			}
+			if path, ok := paths[name]; ok && path != imp.Path.Value {
+				return fmt.Errorf("%w: %s is %s and %s", ErrImportConflict, name, path, imp.Path.Value)
+			}
+			paths[name] = imp.Path.Value
+		}
+
+		importDecl.Specs = specs
+		switch len(specs) {
+		case 0:
+			node.Decls = node.Decls[1:]
+		case 1:
+			// A single import needs no parentheses
+			importDecl.Lparen, importDecl.Rparen = token.NoPos, token.NoPos
+		}
+	}
+
+	return formatAndWriteFile(filename, "", node, fset)
+}
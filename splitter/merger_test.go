@@ -0,0 +1,219 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergePackage_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "config.go")
+	testContent := `// Package cfg loads configuration.
+package cfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version is the config format version.
+const Version = "v1"
+
+// Config holds settings.
+type Config struct {
+	Values []string
+}
+
+// ParseConfig parses the config.
+func ParseConfig(s string) Config {
+	return Config{Values: strings.Split(s, ",")}
+}
+
+// String formats the config.
+func (c Config) String() string {
+	return fmt.Sprint(c.Values)
+}
+
+func helper() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	originalNames := declaredTopLevelNames(t, testContent)
+
+	if err := SplitPublicFunctions(tmpDir, Options{AddProvenance: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+	if err := MergePackage(tmpDir, testFile, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("MergePackage failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "config.go" {
+		t.Errorf("Expected only config.go to remain, got %d entries", len(entries))
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected config.go to be created: %v", err)
+	}
+	mergedNames := declaredTopLevelNames(t, string(content))
+	if strings.Join(mergedNames, ",") != strings.Join(originalNames, ",") {
+		t.Errorf("Expected declarations %v, got %v", originalNames, mergedNames)
+	}
+
+	for _, want := range []string{"// Package cfg loads configuration.", `"fmt"`, `"strings"`, "// ParseConfig parses the config."} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Merged file should contain %q", want)
+		}
+	}
+	if strings.Contains(string(content), "Code split from") {
+		t.Error("Merged file should not keep provenance headers")
+	}
+}
+
+func TestMergePackage_SkipsOtherFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"a.go": `package cfg
+
+import "fmt"
+
+func A() { fmt.Println("a") }
+`,
+		"b.go": `package cfg
+
+import "fmt"
+
+func B() { fmt.Println("b") }
+`,
+		"linux.go": `//go:build linux
+
+package cfg
+
+func Linux() {}
+`,
+		"a_test.go": `package cfg
+
+import "testing"
+
+func TestA(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(tmpDir, "cfg.go")
+	var output bytes.Buffer
+	if err := MergePackage(tmpDir, target, Options{Output: &output}); err != nil {
+		t.Fatalf("MergePackage failed: %v", err)
+	}
+	for _, want := range []string{"Warning: not merging " + filepath.Join(tmpDir, "linux.go"), "Created: " + target + " (merged 2 files)"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Output should contain %q, got:\n%s", want, output.String())
+		}
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Expected cfg.go to be created: %v", err)
+	}
+	if strings.Count(string(content), `"fmt"`) != 1 {
+		t.Errorf("fmt should be imported once, got:\n%s", content)
+	}
+	if strings.Contains(string(content), "Linux") {
+		t.Error("Build-tagged file should not be merged")
+	}
+
+	for _, name := range []string{"linux.go", "a_test.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Errorf("%s should be left alone: %v", name, err)
+		}
+	}
+	for _, name := range []string{"a.go", "b.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed after merging", name)
+		}
+	}
+}
+
+func TestMergePackage_ImportConflict(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"a.go": `package p
+
+import "math/rand"
+
+func A() int { return rand.Intn(2) }
+`,
+		"b.go": `package p
+
+import "crypto/rand"
+
+var Reader = rand.Reader
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(tmpDir, "p.go")
+	if err := MergePackage(tmpDir, target, Options{Output: io.Discard}); !errors.Is(err, ErrImportConflict) {
+		t.Fatalf("MergePackage error = %v, want %v", err, ErrImportConflict)
+	}
+
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("p.go should not be written")
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil || string(got) != content {
+			t.Errorf("%s should be left alone: %v", name, err)
+		}
+	}
+}
+
+func declaredTopLevelNames(t *testing.T, src string) []string {
+	t.Helper()
+
+	node, err := parser.ParseFile(token.NewFileSet(), "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v\n%s", err, src)
+	}
+
+	var names []string
+	for _, decl := range node.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil {
+				name = getReceiverTypeName(d.Recv) + "." + name
+			}
+			names = append(names, name)
+		case *ast.GenDecl:
+			names = append(names, declaredNames(d)...)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
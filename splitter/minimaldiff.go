@@ -0,0 +1,226 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+)
+
+var (
+	// errNotWholeLines is returned when a cut would leave part of a line
+	// behind.
+	errNotWholeLines = errors.New("moved code shares lines with code that stays")
+	// errSourceMismatch is returned for a node not parsed from the source.
+	errSourceMismatch = errors.New("source doesn't match its syntax tree")
+)
+
+// sourceCut replaces src[start:end] with text.
+type sourceCut struct {
+	start, end int
+	text       string
+}
+
+// minimalDiffSource returns src, the source node was parsed from, with only
+// what left node cut out: the declarations of decls that aren't in node.Decls
+// any more, the specs of the blocks kept in part and the comments of
+// comments missing from node.Comments. Everything else stays byte for byte as
+// it was written. Each of markers takes the place of the declaration it
+// marks.
+func minimalDiffSource(src []byte, node *ast.File, decls []ast.Decl, comments, markers []*ast.CommentGroup, fset *token.FileSet) ([]byte, error) {
+	file := fset.File(node.Pos())
+	if file == nil || file.Size() != len(src) {
+		return nil, errSourceMismatch
+	}
+
+	keptDecls := make(map[ast.Decl]bool)
+	keptSpecs := make(map[ast.Spec]bool)
+	for _, decl := range node.Decls {
+		keptDecls[decl] = true
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range genDecl.Specs {
+				keptSpecs[spec] = true
+			}
+		}
+	}
+
+	var cuts []sourceCut
+	cut := func(start, end token.Pos) {
+		cuts = append(cuts, sourceCut{start: file.Offset(start), end: file.Offset(end)})
+	}
+	for _, decl := range decls {
+		if keptDecls[decl] {
+			continue
+		}
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			cut(decl.Pos(), decl.End())
+
+			continue
+		}
+		// A block is cut out whole, or spec by spec when part of it stays
+		removed := slices.DeleteFunc(slices.Clone(genDecl.Specs), func(spec ast.Spec) bool {
+			return keptSpecs[spec]
+		})
+		if len(removed) == len(genDecl.Specs) {
+			cut(genDecl.Pos(), genDecl.End())
+
+			continue
+		}
+		for _, spec := range removed {
+			cut(specRange(spec))
+		}
+	}
+
+	keptComments := make(map[*ast.CommentGroup]bool)
+	for _, cg := range node.Comments {
+		keptComments[cg] = true
+	}
+	for _, cg := range comments {
+		if !keptComments[cg] {
+			cut(cg.Pos(), cg.End())
+		}
+	}
+
+	cuts, err := wholeLineCuts(src, cuts)
+	if err != nil {
+		return nil, err
+	}
+
+	ending := lineEnding(src)
+	for _, marker := range markers {
+		offset := file.Offset(marker.Pos())
+		i := slices.IndexFunc(cuts, func(c sourceCut) bool {
+			return c.start <= offset && offset < c.end
+		})
+		if i < 0 {
+			continue
+		}
+		for _, c := range marker.List {
+			cuts[i].text += c.Text + ending
+		}
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, c := range cuts {
+		if c.text == "" {
+			c.start, c.end = withoutBlankLines(src, c.start, c.end)
+		}
+		if c.start < last {
+			c.start = last
+		}
+		buf.Write(src[last:c.start])
+		buf.WriteString(c.text)
+		last = c.end
+	}
+	buf.Write(src[last:])
+
+	// A cut that went wrong shows up as a syntax error
+	if _, err := parser.ParseFile(token.NewFileSet(), "", buf.Bytes(), 0); err != nil {
+		return nil, fmt.Errorf("failed to parse minimal-diff source: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// specRange returns the range of spec along with its doc and line comments.
+func specRange(spec ast.Spec) (token.Pos, token.Pos) {
+	if imp, ok := spec.(*ast.ImportSpec); ok {
+		start, end := imp.Pos(), imp.End()
+		if imp.Doc != nil {
+			start = imp.Doc.Pos()
+		}
+		if imp.Comment != nil {
+			end = imp.Comment.End()
+		}
+
+		return start, end
+	}
+
+	return commentRange(spec)
+}
+
+// wholeLineCuts merges the cuts that overlap, like a spec and its line
+// comment, widens each to the lines it spans, including their line breaks,
+// and merges those only blank lines apart, in source order. A cut sharing a
+// line with code that stays fails.
+func wholeLineCuts(src []byte, cuts []sourceCut) ([]sourceCut, error) {
+	slices.SortFunc(cuts, func(a, b sourceCut) int {
+		return a.start - b.start
+	})
+	var merged []sourceCut
+	for _, c := range cuts {
+		n := len(merged)
+		if n > 0 && c.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, c.end)
+
+			continue
+		}
+
+		start := bytes.LastIndexByte(src[:c.start], '\n') + 1
+		if !isBlank(src[start:c.start]) {
+			return nil, errNotWholeLines
+		}
+		// Blank lines between two cuts go with them
+		if n > 0 && len(bytes.TrimSpace(src[merged[n-1].end:start])) == 0 {
+			merged[n-1].end = max(merged[n-1].end, c.end)
+
+			continue
+		}
+		merged = append(merged, sourceCut{start: start, end: c.end})
+	}
+
+	for i, c := range merged {
+		end := len(src)
+		if nl := bytes.IndexByte(src[c.end:], '\n'); nl >= 0 {
+			end = c.end + nl + 1
+		}
+		// A comment trailing the last line goes along, like one on an import
+		if rest := bytes.TrimSpace(src[c.end:end]); len(rest) > 0 && !bytes.HasPrefix(rest, []byte("//")) {
+			return nil, errNotWholeLines
+		}
+		merged[i].end = end
+	}
+
+	return merged, nil
+}
+
+// withoutBlankLines widens the whole-line cut src[start:end] so it doesn't
+// leave two blank lines in a row, or blank lines at the end of the file.
+func withoutBlankLines(src []byte, start, end int) (int, int) {
+	if end == len(src) {
+		for start > 0 && isBlankLine(src, start-1) {
+			start = bytes.LastIndexByte(src[:start-1], '\n') + 1
+		}
+
+		return start, end
+	}
+	if start > 0 && isBlankLine(src, start-1) {
+		for end < len(src) && isBlankLine(src, end) {
+			end += bytes.IndexByte(src[end:], '\n') + 1
+		}
+	}
+
+	return start, end
+}
+
+// isBlankLine reports whether the line holding src[i] has only white space.
+func isBlankLine(src []byte, i int) bool {
+	start := bytes.LastIndexByte(src[:i], '\n') + 1
+	end := bytes.IndexByte(src[i:], '\n')
+	if end < 0 {
+		return false
+	}
+
+	return isBlank(src[start : i+end])
+}
+
+// isBlank reports whether b holds nothing but spaces, tabs and carriage
+// returns.
+func isBlank(b []byte) bool {
+	return len(bytes.Trim(b, " \t\r")) == 0
+}
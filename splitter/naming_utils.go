@@ -1,47 +1,93 @@
 package splitter
 
 import (
+	"slices"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
-func functionNameToSnakeCase(name string) string {
-	// Handle common abbreviations
-	commonAbbreviations := getCommonAbbreviations()
-	for _, abbr := range commonAbbreviations {
-		if strings.ToUpper(name) == abbr {
-			return strings.ToLower(name)
+// fileBaseName returns the file name, without extension, for a function or
+// type name. Names NameFunc leaves empty get the default.
+func (opts Options) fileBaseName(name string) string {
+	if opts.NameFunc != nil {
+		if base := opts.NameFunc(name); base != "" {
+			return base
 		}
 	}
 
-	result := make([]rune, 0, len(name)*2)
-	runes := []rune(name)
+	return functionNameToSnakeCase(name, opts.Abbreviations...)
+}
 
-	for i := 0; i < len(runes); i++ {
-		// Check if current position starts with a known abbreviation
-		if abbr, length := matchesAbbreviation(runes, i); abbr != "" {
-			// Add underscore before abbreviation if needed
-			if i > 0 && len(result) > 0 && result[len(result)-1] != '_' {
-				result = append(result, '_')
-			}
-			// Add the abbreviation in lowercase
-			for _, r := range strings.ToLower(abbr) {
-				result = append(result, r)
+// typeBaseName returns the file name, without extension, for a type. Types
+// whose names convert to the same one, like HTTPServer and HttpServer, are
+// told apart by a number, in name order: http_server, http_server_2.
+func (opts Options) typeBaseName(typeName string) string {
+	if base, ok := opts.typeBaseNames[typeName]; ok {
+		return base
+	}
+
+	return opts.fileBaseName(typeName)
+}
+
+// methodBaseName returns the file name, without extension, for a method.
+// Pointer and value receivers of one type share a name.
+func (opts Options) methodBaseName(receiverType, methodName string) string {
+	if _, ok := opts.typeBaseNames[receiverType]; ok || opts.NameFunc != nil {
+		return opts.typeBaseName(receiverType) + "_" + opts.fileBaseName(methodName)
+	}
+
+	return methodNameToSnakeCase(receiverType, methodName, opts.Abbreviations...)
+}
+
+// testBaseName returns the file name, without extension and test suffix, for
+// a test name.
+func (opts Options) testBaseName(name string) string {
+	if opts.NameFunc != nil {
+		if rest := strings.TrimLeft(strings.TrimPrefix(name, "Test"), "_"); rest != "" {
+			if base := opts.NameFunc(rest); base != "" {
+				return base
 			}
-			i += length - 1
+		}
+	}
 
-			continue
+	return testNameToSnakeCase(name, opts.Abbreviations...)
+}
+
+// collidingTypeBaseNames returns the file base names of the types among
+// typeNames whose names convert to the same default one; the first in name
+// order keeps it, and the others get "_2", "_3", ... appended.
+func (opts Options) collidingTypeBaseNames(typeNames []string) map[string]string {
+	byBase := make(map[string][]string)
+	for _, name := range typeNames {
+		base := opts.fileBaseName(name)
+		if !slices.Contains(byBase[base], name) {
+			byBase[base] = append(byBase[base], name)
 		}
+	}
 
-		// Handle regular character
-		r := runes[i]
-		if shouldAddUnderscore(runes, i, result) {
-			result = append(result, '_')
+	var names map[string]string
+	for base, group := range byBase {
+		if len(group) < 2 {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]string)
+		}
+		slices.Sort(group)
+		for i, name := range group {
+			names[name] = base
+			if i > 0 {
+				names[name] = base + "_" + strconv.Itoa(i+1)
+			}
 		}
-		result = append(result, unicode.ToLower(r))
 	}
 
-	resultStr := string(result)
+	return names
+}
+
+func functionNameToSnakeCase(name string, extraAbbreviations ...string) string {
+	resultStr := toSnakeCase(name, mergeAbbreviations(extraAbbreviations))
 	if resultStr == "" {
 		return "func"
 	}
@@ -50,7 +96,7 @@ func functionNameToSnakeCase(name string) string {
 	return strings.TrimLeft(resultStr, "_")
 }
 
-func testNameToSnakeCase(name string) string {
+func testNameToSnakeCase(name string, extraAbbreviations ...string) string {
 	if !strings.HasPrefix(name, "Test") {
 		return strings.ToLower(name)
 	}
@@ -62,12 +108,29 @@ func testNameToSnakeCase(name string) string {
 		return "test"
 	}
 
-	// Check if the entire name is a common abbreviation
-	commonAbbreviations := getCommonAbbreviations()
-	for _, abbr := range commonAbbreviations {
-		if strings.ToUpper(name) == abbr {
-			return strings.ToLower(name)
-		}
+	resultStr := toSnakeCase(name, mergeAbbreviations(extraAbbreviations))
+	if resultStr == "" {
+		return "test"
+	}
+
+	return resultStr
+}
+
+// testNamePrefix returns the first snake_case segment of a test name, e.g.
+// "user" for TestUserCreate.
+func testNamePrefix(name string, extraAbbreviations ...string) string {
+	prefix, _, _ := strings.Cut(testNameToSnakeCase(name, extraAbbreviations...), "_")
+
+	return prefix
+}
+
+// toSnakeCase converts name to snake_case, keeping the given abbreviations together.
+// Digits stick to the word before them, so "HTTP2Client" becomes "http2_client"
+// and "OAuth2Token" becomes "o_auth2_token".
+func toSnakeCase(name string, abbreviations abbreviationSet) string {
+	// Check if the entire name is a known abbreviation
+	if abbreviations.words[strings.ToUpper(name)] {
+		return strings.ToLower(name)
 	}
 
 	result := make([]rune, 0, len(name)*2)
@@ -75,7 +138,7 @@ func testNameToSnakeCase(name string) string {
 
 	for i := 0; i < len(runes); i++ {
 		// Check if current position starts with a known abbreviation
-		if abbr, length := matchesAbbreviation(runes, i); abbr != "" {
+		if abbr, length := matchAbbreviation(runes, i, abbreviations); abbr != "" {
 			// Add underscore before abbreviation if needed
 			if i > 0 && len(result) > 0 && result[len(result)-1] != '_' {
 				result = append(result, '_')
@@ -97,12 +160,7 @@ func testNameToSnakeCase(name string) string {
 		result = append(result, unicode.ToLower(r))
 	}
 
-	resultStr := string(result)
-	if resultStr == "" {
-		return "test"
-	}
-
-	return resultStr
+	return string(result)
 }
 
 func getCommonAbbreviations() []string {
@@ -113,41 +171,138 @@ func getCommonAbbreviations() []string {
 		"HTML", "CSS", "JS", "MD5", "SHA", "RSA", "AES", "UTF", "ASCII",
 		"CRUD", "REST", "RPC", "GRPC", "MQTT", "AMQP", "SMTP", "IMAP", "POP",
 		"SDK", "CLI", "GUI", "UI", "UX", "OS", "VM", "PDF", "PNG", "JPG", "GIF",
+		"IPV4", "IPV6",
 	}
 }
 
-func matchesAbbreviation(runes []rune, i int) (string, int) {
-	commonAbbreviations := getCommonAbbreviations()
-	for _, abbr := range commonAbbreviations {
-		if i+len(abbr) > len(runes) {
+// abbreviationSet holds upper-cased abbreviations, both by name and as a
+// prefix trie, so matching at a position walks at most as many runes as the
+// longest abbreviation has, without building substrings.
+type abbreviationSet struct {
+	words map[string]bool
+	trie  *abbreviationNode
+}
+
+// abbreviationNode is a trie node keyed by upper-case runes. Terminal nodes
+// end an abbreviation.
+type abbreviationNode struct {
+	children map[rune]*abbreviationNode
+	terminal bool
+}
+
+// commonAbbreviations is the set of the built-in abbreviations, built once.
+var commonAbbreviations = newAbbreviationSet(getCommonAbbreviations()) //nolint:gochecknoglobals
+
+func newAbbreviationSet(abbreviations []string) abbreviationSet {
+	set := abbreviationSet{
+		words: make(map[string]bool, len(abbreviations)),
+		trie:  &abbreviationNode{},
+	}
+	set.add(abbreviations)
+
+	return set
+}
+
+// add upper-cases abbreviations and adds them, skipping empty entries.
+func (s abbreviationSet) add(abbreviations []string) {
+	for _, abbr := range abbreviations {
+		abbr = strings.ToUpper(strings.TrimSpace(abbr))
+		if abbr == "" {
 			continue
 		}
+		s.words[abbr] = true
 
-		substr := string(runes[i : i+len(abbr)])
-		if strings.ToUpper(substr) != abbr {
+		node := s.trie
+		for _, r := range abbr {
+			child := node.children[r]
+			if child == nil {
+				child = &abbreviationNode{}
+				if node.children == nil {
+					node.children = make(map[rune]*abbreviationNode)
+				}
+				node.children[r] = child
+			}
+			node = child
+		}
+		node.terminal = true
+	}
+}
+
+// mergeAbbreviations returns the built-in abbreviations together with the
+// extra ones. Without extra ones the shared set is returned as is.
+func mergeAbbreviations(extraAbbreviations []string) abbreviationSet {
+	if len(extraAbbreviations) == 0 {
+		return commonAbbreviations
+	}
+
+	merged := newAbbreviationSet(getCommonAbbreviations())
+	merged.add(extraAbbreviations)
+
+	return merged
+}
+
+func matchesAbbreviation(runes []rune, i int, extraAbbreviations ...string) (string, int) {
+	return matchAbbreviation(runes, i, mergeAbbreviations(extraAbbreviations))
+}
+
+// matchAbbreviation reports the longest abbreviation starting at runes[i] that
+// ends on a word boundary, including any digits directly following it
+// (e.g. "HTTP2", "UTF8"), and its length in runes. Preferring the longest match
+// lets adjacent abbreviations chain correctly, so "HTTPSURL" is read as
+// "HTTPS" + "URL" rather than "HTTP" + "SURL". Matching is case-insensitive
+// (so "IPv4" and "httpHandler" work) but only at the start of a word, so the
+// "id" in "valid" is left alone.
+func matchAbbreviation(runes []rune, i int, abbreviations abbreviationSet) (string, int) {
+	if !isWordStart(runes, i) {
+		return "", 0
+	}
+
+	bestAbbr, bestLength := "", 0
+	node := abbreviations.trie
+	for j := i; j < len(runes); j++ {
+		if node = node.children[unicode.ToUpper(runes[j])]; node == nil {
+			break
+		}
+		if !node.terminal {
 			continue
 		}
 
+		// Digits belong to the abbreviation they follow
+		end := j + 1
+		for end < len(runes) && unicode.IsDigit(runes[end]) {
+			end++
+		}
+
 		// Check if it's a word boundary
-		atWordBoundary := i+len(abbr) == len(runes) ||
-			(i+len(abbr) < len(runes) && unicode.IsUpper(runes[i+len(abbr)]))
+		atWordBoundary := end == len(runes) || unicode.IsUpper(runes[end])
 
-		if atWordBoundary {
-			return abbr, len(abbr)
+		if atWordBoundary && end-i > bestLength {
+			bestAbbr, bestLength = strings.ToUpper(string(runes[i:end])), end-i
 		}
 	}
 
-	return "", 0
+	return bestAbbr, bestLength
 }
 
-func methodNameToSnakeCase(receiverType, methodName string) string {
+// isWordStart reports whether runes[i] can begin a word: the first rune, an
+// uppercase letter, or a letter following a non-letter such as '_' or a digit.
+func isWordStart(runes []rune, i int) bool {
+	return i == 0 || unicode.IsUpper(runes[i]) || !unicode.IsLetter(runes[i-1])
+}
+
+func methodNameToSnakeCase(receiverType, methodName string, extraAbbreviations ...string) string {
 	// Convert both receiver type and method name to snake case and combine
-	receiverSnake := functionNameToSnakeCase(receiverType)
-	methodSnake := functionNameToSnakeCase(methodName)
+	receiverSnake := functionNameToSnakeCase(receiverType, extraAbbreviations...)
+	methodSnake := functionNameToSnakeCase(methodName, extraAbbreviations...)
 
 	return receiverSnake + "_" + methodSnake
 }
 
+// shouldAddUnderscore reports whether a word starts at the uppercase rune
+// runes[i]. That is the case after a lowercase letter ("getURL" → "get_url")
+// and for the last letter of an uppercase run followed by a lowercase one,
+// which starts the next word ("HTTPHandler" → "http_handler", "ABCdef" →
+// "ab_cdef"). Names without uppercase letters are never split.
 func shouldAddUnderscore(runes []rune, i int, result []rune) bool {
 	if i == 0 || !unicode.IsUpper(runes[i]) {
 		return false
@@ -169,3 +324,17 @@ func shouldAddUnderscore(runes []rune, i int, result []rune) bool {
 
 	return false
 }
+
+// avoidReservedFileName appends an underscore to names that Windows reserves
+// for devices (e.g. "con", "nul", "com1"), which can't be used as file names
+// regardless of their extension.
+func avoidReservedFileName(name string) string {
+	switch strings.ToLower(name) {
+	case "con", "prn", "aux", "nul",
+		"com1", "com2", "com3", "com4", "com5", "com6", "com7", "com8", "com9",
+		"lpt1", "lpt2", "lpt3", "lpt4", "lpt5", "lpt6", "lpt7", "lpt8", "lpt9":
+		return name + "_"
+	default:
+		return name
+	}
+}
@@ -1,6 +1,7 @@
 package splitter
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -19,6 +20,18 @@ func TestFunctionNameToSnakeCase(t *testing.T) {
 		{"A", "a"},
 		{"ABC", "abc"},
 		{"XMLParser", "xml_parser"},
+		{"ABCdef", "ab_cdef"}, // the last letter of an uppercase run starts the next word
+		{"HTTPHandler", "http_handler"},
+		{"XMLHTTPRequest", "xml_http_request"},
+		{"ParseYAMLFile", "parse_yaml_file"},
+		{"XYZServer", "xyz_server"},
+		{"NewABCClient", "new_abc_client"},
+		{"IOReader", "io_reader"},
+		{"httpHandler", "http_handler"},
+		{"valid", "valid"},
+		{"isValid", "is_valid"},
+		{"already_snake", "already_snake"},
+		{"lower", "lower"},
 	}
 
 	for _, tc := range tests {
@@ -65,6 +78,13 @@ func TestMatchesAbbreviation(t *testing.T) {
 		{"APIKEY", 0, "API", 3},
 		{"NotAbbr", 0, "", 0},
 		{"URLParser", 0, "URL", 3},
+		{"HTTP2Client", 0, "HTTP2", 5},
+		{"ParseUTF8", 5, "UTF8", 4},
+		{"HTTPSURL", 0, "HTTPS", 5},
+		{"APIID", 0, "API", 3},
+		{"APIID", 3, "ID", 2},
+		{"valid", 3, "", 0}, // not at the start of a word
+		{"get_id", 4, "ID", 2},
 	}
 
 	for _, tc := range tests {
@@ -77,6 +97,79 @@ func TestMatchesAbbreviation(t *testing.T) {
 	}
 }
 
+func TestFunctionNameToSnakeCaseWithDigits(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Base64", "base64"},
+		{"EncodeBase64URL", "encode_base64_url"},
+		{"SHA256", "sha256"},
+		{"SHA256Sum", "sha256_sum"},
+		{"IPv4", "ipv4"},
+		{"ParseIPv4Addr", "parse_ipv4_addr"},
+		{"HTTP2", "http2"},
+		{"HTTP2Client", "http2_client"},
+		{"IDHTTP2Client", "id_http2_client"},
+		{"OAuth2Token", "o_auth2_token"},
+		{"ParseUTF8", "parse_utf8"},
+		{"Int64ToString", "int64_to_string"},
+	}
+
+	for _, tc := range tests {
+		result := functionNameToSnakeCase(tc.input)
+		if result != tc.expected {
+			t.Errorf("functionNameToSnakeCase(%q) = %q, want %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+func TestFunctionNameToSnakeCaseConsecutiveAbbreviations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"APIID", "api_id"},
+		{"IDAPI", "id_api"},
+		{"URLID", "url_id"},
+		{"HTTPSURL", "https_url"},
+		{"GetHTTPSURLID", "get_https_url_id"},
+		{"JSONAPIURL", "json_api_url"},
+		{"TCPIPConnection", "tcp_ip_connection"},
+	}
+
+	for _, tc := range tests {
+		result := functionNameToSnakeCase(tc.input)
+		if result != tc.expected {
+			t.Errorf("functionNameToSnakeCase(%q) = %q, want %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+func TestFunctionNameToSnakeCaseUnicode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Größe", "größe"},
+		{"Δelta", "δelta"},
+		{"ÄpfelBirnen", "äpfel_birnen"},
+		{"GetÜberSicht", "get_über_sicht"},
+		{"ParseΔX", "parse_δx"},
+	}
+
+	for _, tc := range tests {
+		result := functionNameToSnakeCase(tc.input)
+		if result != tc.expected {
+			t.Errorf("functionNameToSnakeCase(%q) = %q, want %q", tc.input, result, tc.expected)
+		}
+	}
+
+	if got := functionNameToSnakeCase("GetÆØÅID", "ÆØÅ"); got != "get_æøå_id" {
+		t.Errorf("functionNameToSnakeCase with non-ASCII abbreviation = %q, want %q", got, "get_æøå_id")
+	}
+}
+
 func TestMethodNameToSnakeCase(t *testing.T) {
 	tests := []struct {
 		receiverType string
@@ -129,3 +222,118 @@ func TestShouldAddUnderscore(t *testing.T) {
 		}
 	}
 }
+
+func TestSnakeCaseWithExtraAbbreviations(t *testing.T) {
+	extra := []string{"acl", "SKU", " CIDR "}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"ParseACLID", "parse_acl_id"},
+		{"SKUAPI", "sku_api"},
+		{"GetCIDRSKU", "get_cidr_sku"},
+		{"ACL", "acl"},
+		{"ACLine", "ac_line"}, // not at a word boundary
+	}
+
+	for _, tc := range tests {
+		if got := functionNameToSnakeCase(tc.input, extra...); got != tc.expected {
+			t.Errorf("functionNameToSnakeCase(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+		if got := testNameToSnakeCase("Test"+tc.input, extra...); got != tc.expected {
+			t.Errorf("testNameToSnakeCase(%q) = %q, want %q", "Test"+tc.input, got, tc.expected)
+		}
+	}
+
+	if got := methodNameToSnakeCase("ACLStore", "GetSKUID", extra...); got != "acl_store_get_sku_id" {
+		t.Errorf("methodNameToSnakeCase() = %q, want %q", got, "acl_store_get_sku_id")
+	}
+}
+
+func TestAvoidReservedFileName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"con", "con_"},
+		{"nul", "nul_"},
+		{"aux", "aux_"},
+		{"com1", "com1_"},
+		{"lpt9", "lpt9_"},
+		{"console", "console"},
+		{"type", "type"},
+		{"con_get", "con_get"},
+	}
+
+	for _, tc := range tests {
+		if got := avoidReservedFileName(tc.input); got != tc.expected {
+			t.Errorf("avoidReservedFileName(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// TestFunctionNameToSnakeCaseLongNames pins the output of the set-based
+// abbreviation matching to that of the former scan over the whole list.
+func TestFunctionNameToSnakeCaseLongNames(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		withExtra string // with ACL, SKU and CIDR as extra abbreviations
+	}{
+		{"GetHTTPSURLForUserIDAndUUIDv4", "get_https_url_for_user_id_and_u_ui_dv4", "get_https_url_for_user_id_and_u_ui_dv4"},
+		{"XMLHTTPRequestJSONAPIHandler", "xml_http_request_json_api_handler", "xml_http_request_json_api_handler"},
+		{"IPv4AndIPv6CIDRParser", "ipv4_and_ipv6c_idr_parser", "ipv4_and_ipv6_cidr_parser"},
+		{"HTTP2ServerTLSConfig", "http2_server_tls_config", "http2_server_tls_config"},
+		{"parseURLFromJSONToCSV", "parse_url_from_json_to_csv", "parse_url_from_json_to_csv"},
+		{"OAuth2TokenForAWSAndGCP", "o_auth2_token_for_aws_and_gcp", "o_auth2_token_for_aws_and_gcp"},
+		{"UTF8ToASCII", "utf8_to_ascii", "utf8_to_ascii"},
+		{"MD5SumOfSHA256", "md5_sum_of_sha256", "md5_sum_of_sha256"},
+		{"newGRPCClientWithTTL", "new_grpc_client_with_ttl", "new_grpc_client_with_ttl"},
+		{"APIKeyIDs", "api_key_i_ds", "api_key_i_ds"},
+		{"ioReaderEOF", "io_reader_eof", "io_reader_eof"},
+		{"DBSQLTxIDValid", "db_sql_tx_id_valid", "db_sql_tx_id_valid"},
+		{"CPUGPURAMUsage", "cpu_gpu_ram_usage", "cpu_gpu_ram_usage"},
+		{"getACLForSKU", "get_acl_for_sku", "get_acl_for_sku"},
+		{"Über_Größe", "über_größe", "über_größe"},
+		{"URLs", "ur_ls", "ur_ls"},
+		{"SQLDBId", "sql_db_id", "sql_db_id"},
+		{"validIdentifier", "valid_identifier", "valid_identifier"},
+		{"CRUDRESTRPCAPIs", "crud_rest_rpcap_is", "crud_rest_rpcap_is"},
+	}
+
+	for _, tc := range tests {
+		if result := functionNameToSnakeCase(tc.input); result != tc.expected {
+			t.Errorf("functionNameToSnakeCase(%q) = %q, want %q", tc.input, result, tc.expected)
+		}
+		if result := functionNameToSnakeCase(tc.input, "ACL", "SKU", "CIDR"); result != tc.withExtra {
+			t.Errorf("functionNameToSnakeCase(%q, extras) = %q, want %q", tc.input, result, tc.withExtra)
+		}
+	}
+}
+
+func BenchmarkFunctionNameToSnakeCase(b *testing.B) {
+	name := strings.Repeat("GetHTTPSURLForUserIDAndXMLHTTPRequestJSONAPIHandler", 20)
+
+	b.Run("builtin", func(b *testing.B) {
+		for b.Loop() {
+			functionNameToSnakeCase(name)
+		}
+	})
+	b.Run("extra", func(b *testing.B) {
+		for b.Loop() {
+			functionNameToSnakeCase(name, "ACL", "SKU", "CIDR")
+		}
+	})
+}
+
+func BenchmarkMatchAbbreviation(b *testing.B) {
+	runes := []rune(strings.Repeat("HTTPSURLForUserIDAndIPv4JSON", 20))
+	abbreviations := mergeAbbreviations(nil)
+
+	for b.Loop() {
+		for i := range runes {
+			matchAbbreviation(runes, i, abbreviations)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package splitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// SplitResult records what a run did to the files on disk. The counts are kept
+// alongside the file lists so JSON consumers don't have to derive them.
+type SplitResult struct {
+	// Files is the number of source files that were split.
+	Files        int      `json:"files"`
+	Created      int      `json:"created"`
+	Updated      int      `json:"updated"`
+	Deleted      int      `json:"deleted"`
+	CreatedFiles []string `json:"createdFiles"`
+	UpdatedFiles []string `json:"updatedFiles"`
+	DeletedFiles []string `json:"deletedFiles"`
+}
+
+// Summary returns a one-line description of the run, e.g.
+// "Split 12 files: created 47, updated 9, deleted 3".
+func (r *SplitResult) Summary() string {
+	return fmt.Sprintf("Split %d files: created %d, updated %d, deleted %d", r.Files, r.Created, r.Updated, r.Deleted)
+}
+
+// MarshalJSON writes empty file lists as [] rather than null.
+func (r *SplitResult) MarshalJSON() ([]byte, error) {
+	type plain SplitResult
+	p := plain(*r)
+	for _, list := range []*[]string{&p.CreatedFiles, &p.UpdatedFiles, &p.DeletedFiles} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return data, nil
+}
+
+// changes returns how many file changes have been recorded so far.
+func (r *SplitResult) changes() int {
+	return r.Created + r.Updated + r.Deleted
+}
+
+// output returns where progress lines and warnings are written.
+func (opts Options) output() io.Writer {
+	if opts.Output == nil {
+		return os.Stdout
+	}
+
+	return opts.Output
+}
+
+func (opts Options) logf(format string, args ...any) {
+	fmt.Fprintf(opts.output(), format, args...)
+}
+
+// recordCreated adds filename to the result. A file written more than once
+// in a run, such as common.go, is counted once.
+func (opts Options) recordCreated(filename string) {
+	r := opts.Result
+	if r == nil || slices.Contains(r.CreatedFiles, filename) {
+		return
+	}
+	r.Created++
+	r.CreatedFiles = append(r.CreatedFiles, filename)
+}
+
+// mayAppendTo reports whether split declarations may be added to filename.
+// Under KeepOriginal, only files this run created qualify, since any other
+// existing file may be one the split leaves untouched.
+func (opts Options) mayAppendTo(filename string) bool {
+	if !opts.KeepOriginal {
+		return true
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return os.IsNotExist(err)
+	}
+
+	return opts.Result != nil && slices.Contains(opts.Result.CreatedFiles, filename)
+}
+
+// forgetCreated removes filename from the created files of the result, for a
+// file whose content was put back after being written.
+func (opts Options) forgetCreated(filename string) {
+	r := opts.Result
+	if r == nil {
+		return
+	}
+	if i := slices.Index(r.CreatedFiles, filename); i >= 0 {
+		r.Created--
+		r.CreatedFiles = slices.Delete(r.CreatedFiles, i, i+1)
+	}
+}
+
+func (opts Options) recordUpdated(filename string) {
+	r := opts.Result
+	if r == nil || slices.Contains(r.UpdatedFiles, filename) || slices.Contains(r.CreatedFiles, filename) {
+		return
+	}
+	r.Updated++
+	r.UpdatedFiles = append(r.UpdatedFiles, filename)
+}
+
+func (opts Options) recordDeleted(filename string) {
+	r := opts.Result
+	if r == nil {
+		return
+	}
+	r.Deleted++
+	r.DeletedFiles = append(r.DeletedFiles, filename)
+}
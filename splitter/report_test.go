@@ -0,0 +1,82 @@
+package splitter
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitResultJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "funcs.go")
+	testContent := `package funcs
+
+const Version = "v1"
+
+func Alpha() {}
+
+func Beta() {}
+
+func helper() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &SplitResult{}
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard, Result: result}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	want := "Split 1 files: created 3, updated 1, deleted 0"
+	if got := result.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal result: %v", err)
+	}
+
+	counts := map[string]float64{"files": 1, "created": 3, "updated": 1, "deleted": 0}
+	for key, count := range counts {
+		if decoded[key] != count {
+			t.Errorf("Expected %q to be %v, got %v", key, count, decoded[key])
+		}
+	}
+
+	lists := map[string][]string{
+		"createdFiles": {filepath.Join(tmpDir, "alpha.go"), filepath.Join(tmpDir, "beta.go"), filepath.Join(tmpDir, "common.go")},
+		"updatedFiles": {testFile},
+		"deletedFiles": {},
+	}
+	for key, files := range lists {
+		list, ok := decoded[key].([]any)
+		if !ok {
+			t.Errorf("Expected %q to be a list, got %T", key, decoded[key])
+
+			continue
+		}
+		if len(list) != len(files) {
+			t.Errorf("Expected %q to have %d entries, got %v", key, len(files), list)
+
+			continue
+		}
+		for i, file := range files {
+			if list[i] != file {
+				t.Errorf("Expected %q[%d] to be %s, got %v", key, i, file, list[i])
+			}
+		}
+	}
+
+	if len(decoded) != len(counts)+len(lists) {
+		t.Errorf("Unexpected keys in %s", data)
+	}
+}
@@ -0,0 +1,146 @@
+package splitter
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+)
+
+// sortFile reorders the declarations of the generated file filename with
+// sortDeclarations when SortDeclarations is set.
+func (opts Options) sortFile(filename string) error {
+	if !opts.SortDeclarations {
+		return nil
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	sorted, err := sortDeclarations(filename, src)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(sorted, src) {
+		return nil
+	}
+
+	return writeSource(filename, sorted)
+}
+
+// sortDeclarations reorders the top-level declarations of src: types, then
+// consts, then vars, then functions and methods. Types, functions and methods
+// are sorted by name; const and var blocks keep their order, and their specs
+// are never reordered, so iota values don't change.
+func sortDeclarations(filename string, src []byte) ([]byte, error) {
+	return reorderDeclarations(filename, src, declarationOrder)
+}
+
+// reorderDeclarations stably sorts the top-level declarations of src by the
+// rank and then the name order returns for them. Each declaration moves as
+// text along with the comments above it and the rest of its last line;
+// everything up to the imports stays in place.
+func reorderDeclarations(filename string, src []byte, order func(ast.Decl) (int, string)) ([]byte, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	type chunk struct {
+		rank int
+		name string
+		text []byte
+	}
+
+	start, prefixEnd := offset(node.Name.End()), -1
+	var chunks []chunk
+	for _, decl := range node.Decls {
+		if isImportDecl(decl) {
+			start = offset(decl.End())
+
+			continue
+		}
+
+		// A declaration takes the comments above it and the rest of its line
+		end := offset(decl.End())
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			end += i
+		} else {
+			end = len(src)
+		}
+		if prefixEnd < 0 {
+			prefixEnd = start
+		}
+		rank, name := order(decl)
+		chunks = append(chunks, chunk{rank: rank, name: name, text: bytes.TrimSpace(src[start:end])})
+		start = end
+	}
+	if len(chunks) < 2 {
+		return src, nil
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].rank != chunks[j].rank {
+			return chunks[i].rank < chunks[j].rank
+		}
+
+		return chunks[i].name < chunks[j].name
+	})
+
+	var buf bytes.Buffer
+	buf.Write(src[:prefixEnd])
+	for _, c := range chunks {
+		buf.WriteString("\n\n")
+		buf.Write(c.text)
+	}
+	if tail := bytes.TrimSpace(src[start:]); len(tail) > 0 {
+		buf.WriteString("\n\n")
+		buf.Write(tail)
+	}
+	buf.WriteString("\n")
+
+	sorted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format sorted declarations: %w", err)
+	}
+
+	return sorted, nil
+}
+
+// declarationOrder returns the rank of decl's kind and the name it is sorted
+// by within that kind. Const and var blocks have no name, so they keep their
+// order.
+func declarationOrder(decl ast.Decl) (int, string) {
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		switch d.Tok {
+		case token.TYPE:
+			if names := declaredNames(d); len(names) > 0 {
+				return 0, names[0]
+			}
+
+			return 0, ""
+		case token.CONST:
+			return 1, ""
+		default:
+			return 2, ""
+		}
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return 4, getReceiverTypeName(d.Recv) + "." + d.Name.Name
+		}
+
+		return 3, d.Name.Name
+	default:
+		return 5, ""
+	}
+}
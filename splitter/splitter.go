@@ -1,50 +1,197 @@
 package splitter
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-func SplitPublicFunctions(directory string, strategy MethodStrategy) error {
-	goFiles, err := findGoFiles(directory)
+func SplitPublicFunctions(directory string, opts Options) error {
+	if err := checkDirectory(directory); err != nil {
+		return err
+	}
+	if opts.DryRun || opts.Check {
+		return dryRun(directory, opts, SplitPublicFunctions)
+	}
+
+	goFiles, err := findGoFiles(directory, opts)
 	if err != nil {
 		return fmt.Errorf("failed to find go files: %w", err)
 	}
+	if len(goFiles) == 0 {
+		opts.logf("No Go files found in %s\n", directory)
+	}
+
+	// Files of one package add to each other's split files, like methods
+	// to the file of their type, so they share what has been created; Verify
+	// checks the directories the run changed files in
+	if opts.Result == nil {
+		opts.Result = &SplitResult{}
+	}
 
+	var errs []error
 	for _, file := range goFiles {
 		if strings.HasSuffix(file, "_test.go") {
 			continue
 		}
-		if err := processGoFile(file, strategy); err != nil {
-			return fmt.Errorf("failed to process %s: %w", file, err)
+		if err := processFile(file, opts, processGoFile); err != nil {
+			err = fmt.Errorf("failed to process %s: %w", file, err)
+			if !opts.ContinueOnError {
+				return err
+			}
+			opts.logf("Warning: %v\n", err)
+			errs = append(errs, err)
 		}
 	}
+	if err := formatWritten(opts); err != nil {
+		errs = append(errs, err)
+	}
+	if err := verifySplit(opts); err != nil {
+		errs = append(errs, err)
+	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+func SplitTestFunctions(directory string, opts Options) error {
+	if err := checkDirectory(directory); err != nil {
+		return err
+	}
+	if opts.DryRun || opts.Check {
+		return dryRun(directory, opts, SplitTestFunctions)
+	}
+
+	return splitTestFiles(directory, opts, nil)
+}
+
+// SplitAll splits public functions and then test functions in one pass. The
+// test files the first step wrote hold the tests of one split function, and
+// are left as they are instead of being split again by test name.
+func SplitAll(directory string, opts Options) error {
+	if err := checkDirectory(directory); err != nil {
+		return err
+	}
+	if opts.DryRun || opts.Check {
+		return dryRun(directory, opts, SplitAll)
+	}
+
+	if opts.Result == nil {
+		opts.Result = &SplitResult{}
+	}
+
+	// Format and verify once, after both steps
+	publicOpts := opts
+	publicOpts.Verify = false
+	publicOpts.Formatter = nil
+
+	var errs []error
+	if err := SplitPublicFunctions(directory, publicOpts); err != nil {
+		if !opts.ContinueOnError {
+			return err
+		}
+		errs = append(errs, err)
+	}
+
+	var written []string
+	for _, file := range opts.Result.CreatedFiles {
+		if strings.HasSuffix(file, "_test.go") {
+			written = append(written, filepath.Clean(file))
+		}
+	}
+	if err := splitTestFiles(directory, opts, written); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
-func SplitTestFunctions(directory string) error {
-	testFiles, err := findTestFiles(directory)
+// splitTestFiles splits the test files in directory, except those in skip.
+func splitTestFiles(directory string, opts Options, skip []string) error {
+	testFiles, err := findTestFiles(directory, opts)
 	if err != nil {
 		return fmt.Errorf("failed to find test files: %w", err)
 	}
+	if len(testFiles) == 0 {
+		opts.logf("No test files found in %s\n", directory)
+	}
 
+	// Verify checks the directories the run changed files in
+	if opts.Result == nil {
+		opts.Result = &SplitResult{}
+	}
+
+	var errs []error
 	for _, file := range testFiles {
-		if err := processTestFile(file); err != nil {
-			return fmt.Errorf("failed to process %s: %w", file, err)
+		if !strings.HasSuffix(file, opts.testFileSuffix()) || slices.Contains(skip, filepath.Clean(file)) {
+			continue
+		}
+		if err := processFile(file, opts, processTestFile); err != nil {
+			err = fmt.Errorf("failed to process %s: %w", file, err)
+			if !opts.ContinueOnError {
+				return err
+			}
+			opts.logf("Warning: %v\n", err)
+			errs = append(errs, err)
+		}
+	}
+	if err := formatWritten(opts); err != nil {
+		errs = append(errs, err)
+	}
+	if err := verifySplit(opts); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// processFile runs process on filename and counts it as split in
+// opts.Result when it changed any file. Files it created get the line endings
+// of filename, so CRLF sources are split into CRLF files.
+func processFile(filename string, opts Options, process func(string, Options) error) error {
+	if opts.Result == nil {
+		return process(filename, opts)
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	before := opts.Result.changes()
+	created := slices.Clone(opts.Result.CreatedFiles)
+	if err := process(filename, opts); err != nil {
+		return err
+	}
+	if opts.Result.changes() > before {
+		opts.Result.Files++
+	}
+
+	if ending := lineEnding(src); ending != "\n" {
+		for _, path := range opts.Result.CreatedFiles {
+			if slices.Contains(created, path) {
+				continue
+			}
+			if err := convertLineEndings(path, ending); err != nil {
+				return fmt.Errorf("failed to convert line endings of %s: %w", path, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func processGoFile(filename string, strategy MethodStrategy) error {
+func processGoFile(filename string, opts Options) error {
 	fset := token.NewFileSet()
 	src, err := os.ReadFile(filename)
 	if err != nil {
@@ -56,125 +203,559 @@ func processGoFile(filename string, strategy MethodStrategy) error {
 		return fmt.Errorf("failed to parse file: %w", err)
 	}
 
-	publicFuncs := extractPublicFunctions(node)
+	if opts.KeepLineDirectives && hasLineDirective(node) {
+		opts.logf("Warning: skipping %s: contains //line directives that splitting would invalidate\n", filename)
+
+		return nil
+	}
+
+	// Code moved away from its cgo preamble would no longer compile
+	if findCgoImportDecl(node.Decls) != nil {
+		opts.logf("Warning: skipping %s: cgo files cannot be split safely\n", filename)
+
+		return nil
+	}
+
+	// The files split from a platform-specific file are restricted to the
+	// same platforms
+	opts.buildConstraint = buildConstraintLines(node)
+	opts.buildSuffix = splitFileSuffix(filename, opts.buildConstraint)
+
+	// Types sharing a file name with another type of the package are told
+	// apart, so their files don't overwrite each other
+	packageTypes, err := packageTypeNames(filename, node)
+	if err != nil {
+		return err
+	}
+	opts.typeBaseNames = opts.collidingTypeBaseNames(packageTypes)
+
+	publicFuncs := extractPublicFunctions(node, opts, fset)
+	for i := range publicFuncs {
+		publicFuncs[i].source = functionSource(src, node, publicFuncs[i], fset)
+	}
 	publicDecls := extractPublicDeclarations(node)
-	publicMethods := extractPublicMethods(node)
+	publicMethods := extractPublicMethods(node, fset)
+
+	// Under the mixed strategy unexported methods move too, unless they are
+	// already in their type's private file
+	if opts.MethodStrategy == MethodStrategyMixed {
+		for _, method := range extractPrivateMethods(node, fset) {
+			if opts.privateMethodsFileName(method.ReceiverType) != filepath.Base(filename) {
+				publicMethods = append(publicMethods, method)
+			}
+		}
+	}
+
+	// Past MaxFilesPerDirectory, functions are gathered in bucket files
+	if !opts.SingleFile {
+		if opts.buckets, err = opts.bucketCount(filename, len(publicFuncs)); err != nil {
+			return err
+		}
+	}
+
+	// A function already in the file it would be written to stays put
+	if opts.SingleFile {
+		if filepath.Base(filename) == opts.withBuildSuffix(singleFileName) {
+			publicFuncs = nil
+		}
+	} else {
+		publicFuncs = slices.DeleteFunc(publicFuncs, func(fn PublicFunction) bool {
+			return opts.functionFileName(filename, node, fn.FuncDecl) == filepath.Base(filename)
+		})
+	}
+
+	// When every type gets a file of its own, comments standing above a
+	// type, like //go:generate directives, can move with it
+	if opts.groupsByType() || opts.SplitTypes || opts.NoCommonFile {
+		for i, decl := range publicDecls {
+			if decl.GenDecl.Tok == token.TYPE && len(decl.GenDecl.Specs) == 1 {
+				publicDecls[i].StandaloneComments = collectDeclarationComments(node, decl.GenDecl, fset)
+			}
+		}
+	}
+	// A comment trailing the closing paren of a block moving whole leaves
+	// with it; a split block keeps its paren, and the comment, behind
+	for i, decl := range publicDecls {
+		if cg := parenComment(node, decl.GenDecl, fset); cg != nil && slices.Contains(node.Decls, ast.Decl(decl.GenDecl)) {
+			publicDecls[i].StandaloneComments = append(publicDecls[i].StandaloneComments, cg)
+		}
+	}
+
+	// Declarations in common.go are already where they belong
+	commonFile, err := opts.commonFileFor(filepath.Dir(filename), node.Name.Name)
+	if err != nil {
+		return err
+	}
+	if filepath.Base(filename) == filepath.Base(commonFile) {
+		publicDecls = nil
+	}
+
+	if opts.AddProvenance {
+		addProvenance(filename, node, publicFuncs, publicDecls, publicMethods)
+	}
+	if header := opts.fileHeader(node, opts.buildConstraint); header != "" {
+		addHeader(header, publicFuncs, publicDecls, publicMethods)
+	}
 
 	if len(publicFuncs) == 0 && len(publicDecls) == 0 && len(publicMethods) == 0 {
 		return nil
 	}
 
+	// Small files aren't worth the churn unless they have declarations to move
+	if len(publicFuncs) < opts.MinFunctionsToSplit && len(publicDecls) == 0 && len(publicMethods) == 0 {
+		return nil
+	}
+
 	outputDir := filepath.Dir(filename)
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// A split file may take the name of this one; updateOriginalFile tells
+	// from the created files whether it has to merge into it
+	if opts.Result == nil {
+		opts.Result = &SplitResult{}
+	}
+
+	// Private helpers used only by one extracted function move along with it
+	extractedFuncs := publicFuncs
+	var helpers map[string][]PublicFunction
+	if opts.MoveExclusiveHelpers {
+		siblings, err := parseSiblingFiles(filename)
+		if err != nil {
+			return fmt.Errorf("failed to parse package files: %w", err)
+		}
+		helpers = findExclusiveHelpers(node, publicFuncs, siblings, fset)
+
+		helperNames := make(map[string]bool)
+		for _, fns := range helpers {
+			for _, fn := range fns {
+				helperNames[fn.Name] = true
+			}
+		}
+		publicFuncs = nil
+		for _, fn := range extractedFuncs {
+			if !helperNames[fn.Name] {
+				publicFuncs = append(publicFuncs, fn)
+			}
+		}
+		extractedFuncs = withHelpers(publicFuncs, helpers)
+	}
+
+	// Under SingleFile, every function goes to public.go
+	if opts.SingleFile && len(extractedFuncs) > 0 {
+		outputFile := filepath.Join(outputDir, opts.withBuildSuffix(singleFileName))
+		if err := appendFunctionsToFile(outputFile, extractedFuncs, node.Name.Name, node.Imports, fset); err != nil {
+			return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (with %d functions)\n", outputFile, len(extractedFuncs))
+		publicFuncs = nil
+	}
+
+	// Bucket files gather the functions of their range of the alphabet
+	if opts.buckets > 0 && len(publicFuncs) > 0 {
+		buckets := make(map[string][]PublicFunction)
+		for _, fn := range publicFuncs {
+			outputFile := filepath.Join(outputDir, opts.functionFileName(filename, node, fn.FuncDecl))
+			buckets[outputFile] = append(buckets[outputFile], withHelpers([]PublicFunction{fn}, helpers)...)
+		}
+		for _, outputFile := range slices.Sorted(maps.Keys(buckets)) {
+			if err := appendFunctionsToFile(outputFile, buckets[outputFile], node.Name.Name, node.Imports, fset); err != nil {
+				return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+			}
+			opts.recordCreated(outputFile)
+			opts.logf("Created: %s (with %d functions)\n", outputFile, len(buckets[outputFile]))
+		}
+		publicFuncs = nil
+	}
+
+	// Under with-struct, constructors are written together with their type
+	var constructors map[string][]PublicFunction
+	if opts.groupsByType() {
+		constructors = findConstructors(publicFuncs, publicDecls)
+		for typeName, fns := range constructors {
+			constructors[typeName] = withHelpers(fns, helpers)
+		}
+	}
+	groupedFuncs := make(map[string]bool)
+	for _, fns := range constructors {
+		for _, fn := range fns {
+			groupedFuncs[fn.Name] = true
+		}
+	}
+
+	// Group the remaining functions by their first parameter type when requested
+	var paramGroups map[string][]PublicFunction
+	if opts.GroupByFirstParam {
+		var ungrouped []PublicFunction
+		for _, fn := range publicFuncs {
+			if !groupedFuncs[fn.Name] {
+				ungrouped = append(ungrouped, fn)
+			}
+		}
+		paramGroups = groupByFirstParam(ungrouped)
+		for _, fns := range paramGroups {
+			for _, fn := range fns {
+				groupedFuncs[fn.Name] = true
+			}
+		}
+	}
+
 	// Write public functions to individual files
 	for _, fn := range publicFuncs {
-		snakeCaseName := functionNameToSnakeCase(fn.Name)
-		outputFileName := snakeCaseName + ".go"
-		outputFile := filepath.Join(outputDir, outputFileName)
+		if !groupedFuncs[fn.Name] {
+			outputFile := filepath.Join(outputDir, opts.functionFileName(filename, node, fn.FuncDecl))
 
-		if err := writePublicFunction(outputFile, fn, fset); err != nil {
-			return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+			if len(helpers[fn.Name]) > 0 {
+				if err := writeFunctionsToFile(outputFile, withHelpers([]PublicFunction{fn}, helpers), node.Name.Name, node.Imports, fset); err != nil {
+					return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+				}
+			} else if err := writePublicFunction(outputFile, fn, fset); err != nil {
+				return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+			}
+			opts.recordCreated(outputFile)
+			opts.logf("Created: %s\n", outputFile)
 		}
-		fmt.Printf("Created: %s\n", outputFile)
 
 		// Find and split corresponding test file
-		testFile := findCorrespondingTestFile(filename, fn.Name)
-		if testFile != "" {
-			if err := splitTestForFunction(testFile, fn.Name, outputDir); err != nil {
-				fmt.Printf("Warning: failed to split test for %s: %v\n", fn.Name, err)
+		if !opts.SkipCorrespondingTests {
+			testFile := findCorrespondingTestFile(filename, opts.testFileSuffix())
+			if testFile != "" {
+				if err := splitTestForFunction(testFile, fn.Name, outputDir, opts); err != nil {
+					opts.logf("Warning: failed to split test for %s: %v\n", fn.Name, err)
+				}
+			}
+		}
+
+		if opts.ScaffoldMissingTests && isPublicName(fn.Name) {
+			if err := scaffoldTest(opts, outputDir, fn, opts.fileHeader(node, opts.buildConstraint)); err != nil {
+				return err
 			}
 		}
 	}
 
+	// Write functions grouped by their first parameter type
+	typeNames := make([]string, 0, len(paramGroups))
+	for typeName := range paramGroups {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	for _, typeName := range typeNames {
+		snakeCaseName := opts.typeBaseName(typeName)
+		outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
+
+		if err := writeFunctionsToFile(outputFile, withHelpers(paramGroups[typeName], helpers), node.Name.Name, node.Imports, fset); err != nil {
+			return fmt.Errorf("failed to write function file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (with %d functions)\n", outputFile, len(paramGroups[typeName]))
+	}
+
 	// Handle methods based on strategy
-	if err := writeMethodsAndDeclarations(strategy, outputDir, publicDecls, publicMethods, node.Name.Name, node.Imports, fset); err != nil {
+	if err := writeMethodsAndDeclarations(opts, outputDir, publicDecls, constructors, publicMethods, node.Name.Name, node.Imports, fset); err != nil {
 		return err
 	}
 
+	if opts.ConsolidateTestHelpers && !opts.SkipCorrespondingTests {
+		if testFile := findCorrespondingTestFile(filename, opts.testFileSuffix()); testFile != "" {
+			if err := consolidateTestHelpers(opts, testFile); err != nil {
+				return fmt.Errorf("failed to consolidate test helpers: %w", err)
+			}
+		}
+	}
+
+	reportStayingDependencies(opts, filename, node, extractedFuncs, publicMethods)
+	reportCommentAttribution(opts, node, movedFuncDecls(extractedFuncs, publicMethods), fset)
+
 	// Update original file to keep only private content
-	if err := updateOriginalFile(filename, publicFuncs, publicDecls, publicMethods, fset); err != nil {
+	if err := updateOriginalFile(opts, filename, src, extractedFuncs, publicDecls, publicMethods, fset); err != nil {
 		return fmt.Errorf("failed to update original file: %w", err)
 	}
 
 	return nil
 }
 
-func processTestFile(filename string) error {
+func processTestFile(filename string, opts Options) error {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
 	if err != nil {
 		return fmt.Errorf("failed to parse file: %w", err)
 	}
 
-	tests := extractTestFunctions(node)
+	if opts.KeepLineDirectives && hasLineDirective(node) {
+		opts.logf("Warning: skipping %s: contains //line directives that splitting would invalidate\n", filename)
+
+		return nil
+	}
+
+	tests := extractTestFunctions(node, fset)
 	if len(tests) == 0 {
 		return nil
 	}
 
+	opts.buildConstraint = buildConstraintLines(node)
+	opts.buildSuffix = splitFileSuffix(filename, opts.buildConstraint)
+	header := opts.fileHeader(node, opts.buildConstraint)
+	for i := range tests {
+		if opts.AddProvenance {
+			tests[i].Provenance = provenanceHeader(filename, node, tests[i].FuncDecl)
+		}
+		tests[i].Provenance = joinHeaders(header, tests[i].Provenance)
+	}
+
+	helpers, err := exclusiveTestHelpers(opts, filename, node, tests, fset)
+	if err != nil {
+		return err
+	}
+
 	outputDir := filepath.Dir(filename)
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Tests sharing a name prefix are written together when requested
+	var groups map[string][]TestFunction
+	if opts.GroupTestsByPrefix {
+		groups = groupTestsByPrefix(tests, opts.Abbreviations...)
+	}
+	prefixes := make([]string, 0, len(groups))
+	for prefix := range groups {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if len(groups[prefix]) < 2 {
+			continue
+		}
+
+		base := prefix
+		if opts.withBuildSuffix(base+opts.testFileSuffix()) == filepath.Base(filename) {
+			base = "splitted_" + base
+		}
+
+		outputFile, err := opts.testFileFor(outputDir, base, node.Name.Name)
+		if err != nil {
+			return err
+		}
+		if err := appendTestsToFile(outputFile, withTestHelpers(groups[prefix], helpers), fset); err != nil {
+			return fmt.Errorf("failed to write test file %s: %w", outputFile, err)
+		}
+		if err := opts.sortFile(outputFile); err != nil {
+			return err
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (with %d tests)\n", outputFile, len(groups[prefix]))
+	}
+
 	for _, test := range tests {
-		snakeCaseName := testNameToSnakeCase(test.Name)
-		outputFileName := snakeCaseName + "_test.go"
+		if len(groups[testNamePrefix(test.Name, opts.Abbreviations...)]) > 1 {
+			continue
+		}
+
+		base := opts.testBaseName(test.Name)
 
 		// Check if the generated filename would conflict with the original
-		if outputFileName == filepath.Base(filename) {
-			outputFileName = "splitted_" + outputFileName
+		if opts.withBuildSuffix(base+opts.testFileSuffix()) == filepath.Base(filename) {
+			base = "splitted_" + base
 		}
 
-		outputFile := filepath.Join(outputDir, outputFileName)
-		if err := writeTestFunction(outputFile, test, fset); err != nil {
+		outputFile, err := opts.testFileFor(outputDir, base, node.Name.Name)
+		if err != nil {
+			return err
+		}
+		write := writeTestFunction
+		if _, statErr := os.Stat(outputFile); statErr == nil || len(helpers[test.Name]) > 0 {
+			write = func(filename string, test TestFunction, fset *token.FileSet) error {
+				return appendTestsToFile(filename, withTestHelpers([]TestFunction{test}, helpers), fset)
+			}
+		}
+		if err := write(outputFile, test, fset); err != nil {
 			return fmt.Errorf("failed to write test file %s: %w", outputFile, err)
 		}
-		fmt.Printf("Created: %s\n", outputFile)
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s\n", outputFile)
 	}
 
+	testDecls := make([]*ast.FuncDecl, 0, len(tests))
+	for _, test := range tests {
+		testDecls = append(testDecls, test.FuncDecl)
+	}
+	reportCommentAttribution(opts, node, testDecls, fset)
+
 	// Remove extracted tests from original file
-	if err := removeExtractedTests(filename, tests, fset); err != nil {
+	if err := removeExtractedTests(opts, filename, withTestHelpers(tests, helpers), fset); err != nil {
 		return fmt.Errorf("failed to update original file %s: %w", filename, err)
 	}
 
 	return nil
 }
 
-func updateOriginalFile(filename string, extractedFuncs []PublicFunction, extractedDecls []PublicDeclaration, extractedMethods []PublicMethod, fset *token.FileSet) error {
-	src, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// reportStayingDependencies notes, for each moved function and method, the
+// unexported identifiers of filename it uses that stay there. The split still
+// compiles within the package, but reviewers may want to move them as well.
+func reportStayingDependencies(opts Options, filename string, node *ast.File, funcs []PublicFunction, methods []PublicMethod) {
+	moved := movedFuncDecls(funcs, methods)
+	dependencies := findStayingDependencies(node, moved)
+	for _, fn := range moved {
+		name := funcDeclKey(fn)
+		switch names := dependencies[name]; len(names) {
+		case 0:
+		case 1:
+			opts.logf("Note: %s uses %s, which stays in %s\n", name, names[0], filename)
+		default:
+			opts.logf("Note: %s uses %s, which stay in %s\n", name, strings.Join(names, ", "), filename)
+		}
 	}
+}
+
+// movedFuncDecls returns the declarations of the moved functions and methods.
+func movedFuncDecls(funcs []PublicFunction, methods []PublicMethod) []*ast.FuncDecl {
+	moved := make([]*ast.FuncDecl, 0, len(funcs)+len(methods))
+	for _, fn := range funcs {
+		moved = append(moved, fn.FuncDecl)
+	}
+	for _, method := range methods {
+		moved = append(moved, method.FuncDecl)
+	}
+
+	return moved
+}
+
+// reportCommentAttribution explains, under Verbose, for each standalone
+// comment above or trailing one of the moved functions whether it moves
+// along, and the distances that decided it.
+func reportCommentAttribution(opts Options, node *ast.File, moved []*ast.FuncDecl, fset *token.FileSet) {
+	if !opts.Verbose {
+		return
+	}
+
+	movedFuncs := make(map[ast.Decl]bool, len(moved))
+	for _, fn := range moved {
+		movedFuncs[fn] = true
+	}
+	for _, cg := range node.Comments {
+		if cg.End() <= node.Name.End() {
+			continue
+		}
+
+		// Doc comments and comments inside a declaration always stay with it
+		var prev, next ast.Decl
+		inside := false
+		for _, decl := range node.Decls {
+			start, end := commentRange(decl)
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Doc != nil {
+				start = fn.Doc.Pos()
+			}
+			if cg.Pos() >= start && cg.End() <= end {
+				inside = true
+
+				break
+			}
+			if start > cg.End() {
+				next = decl
+
+				break
+			}
+			prev = decl
+		}
+		if inside {
+			continue
+		}
+
+		// A comment trailing a declaration's last line goes wherever it goes
+		line := fset.Position(cg.Pos()).Line
+		if prev != nil && line == fset.Position(prev.End()).Line {
+			if fn, ok := prev.(*ast.FuncDecl); ok && movedFuncs[fn] {
+				opts.logf("Verbose: comment %q on line %d moves with %s: trails its closing brace\n", cg.List[0].Text, line, funcDeclKey(fn))
+			}
+
+			continue
+		}
+		fn, ok := next.(*ast.FuncDecl)
+		if !ok || !movedFuncs[fn] {
+			continue
+		}
+		if leading, reason := leadingCommentDecision(cg, fn, fn.Doc, node.Decls, fset); leading {
+			opts.logf("Verbose: comment %q on line %d moves with %s: %s\n", cg.List[0].Text, line, funcDeclKey(fn), reason)
+		} else {
+			opts.logf("Verbose: comment %q on line %d stays, although %s follows it: %s\n", cg.List[0].Text, line, funcDeclKey(fn), reason)
+		}
+	}
+}
+
+// updateOriginalFile rewrites filename, whose content before splitting was
+// src, without the extracted declarations, or deletes it when nothing is left.
+// When a split file was written to filename itself, like runner.go for type
+// Runner, what is left is merged into that file instead.
+func updateOriginalFile(opts Options, filename string, src []byte, extractedFuncs []PublicFunction, extractedDecls []PublicDeclaration, extractedMethods []PublicMethod, fset *token.FileSet) error {
+	if opts.KeepOriginal {
+		return restoreOriginalFile(opts, filename, src)
+	}
+
+	overwritten := opts.Result != nil && slices.Contains(opts.Result.CreatedFiles, filename)
 
 	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
 	if err != nil {
 		return fmt.Errorf("failed to parse file: %w", err)
 	}
+	decls, comments := node.Decls, node.Comments
 
 	// Create extraction maps
-	extractedFuncNames, extractedDeclPtrs, extractedMethodKeys := buildExtractionMaps(extractedFuncs, extractedDecls, extractedMethods)
+	extractedFuncNames, extractedSpecNames, extractedMethodKeys := buildExtractionMaps(extractedFuncs, extractedDecls, extractedMethods)
 
 	// Filter declarations
-	newDecls, hasRemainingContent := filterDeclarations(node.Decls, extractedFuncNames, extractedDeclPtrs, extractedMethodKeys)
+	newDecls, hasRemainingContent := filterDeclarations(node.Decls, extractedFuncNames, extractedSpecNames, extractedMethodKeys)
+
+	// Under DocFile, the package doc moves to doc.go
+	if opts.DocFile && node.Doc != nil {
+		moved, err := writeDocFile(opts, filename, src, node)
+		if err != nil {
+			return err
+		}
+		if moved {
+			node.Comments = slices.DeleteFunc(node.Comments, func(cg *ast.CommentGroup) bool {
+				return cg == node.Doc
+			})
+			node.Doc = nil
+		}
+	}
+
+	// A cgo preamble or a package doc are content too; build constraints
+	// went along to the split files
+	cgoDecl := findCgoImportDecl(node.Decls)
+	if cgoDecl != nil || node.Doc != nil {
+		hasRemainingContent = true
+	}
 
 	// If no remaining content, delete the file
-	if !hasRemainingContent || len(newDecls) == 0 {
+	if !hasRemainingContent {
+		if overwritten {
+			// The file now holds only its split content
+			return nil
+		}
 		if err := os.Remove(filename); err != nil {
 			return fmt.Errorf("failed to delete empty file: %w", err)
 		}
-		fmt.Printf("Deleted original (now empty): %s\n", filename)
+		opts.recordDeleted(filename)
+		opts.logf("Deleted original (now empty): %s\n", filename)
 
 		return nil
 	}
 
 	// Find used imports in remaining declarations
-	usedImports := findUsedImportsInDecls(newDecls, node.Imports)
+	var usedImports []*ast.ImportSpec
+	for _, imp := range findUsedImportsInDecls(newDecls, node.Imports) {
+		if imp.Path.Value != `"C"` {
+			usedImports = append(usedImports, imp)
+		}
+	}
 
-	// Re-add only used imports
+	// Re-add only used imports, keeping import "C" on its own below its preamble
 	var finalDecls []ast.Decl
+	if cgoDecl != nil {
+		finalDecls = append(finalDecls, cgoDecl)
+	}
 	if len(usedImports) > 0 {
 		importDecl := &ast.GenDecl{
 			Tok:   token.IMPORT,
@@ -215,17 +796,119 @@ func updateOriginalFile(filename string, extractedFuncs []PublicFunction, extrac
 	}
 	node.Comments = remainingComments
 
+	// Leave a note where each moved function used to be
+	var markers []*ast.CommentGroup
+	if opts.LeaveMoveMarker {
+		markers, err = moveMarkers(filename, node, extractedFuncs, extractedMethods, fset)
+		if err != nil {
+			return err
+		}
+		node.Comments = append(node.Comments, markers...)
+		sort.Slice(node.Comments, func(i, j int) bool {
+			return node.Comments[i].Pos() < node.Comments[j].Pos()
+		})
+	}
+
+	if overwritten {
+		remaining, err := formatFile("", node, fset)
+		if err != nil {
+			return err
+		}
+		split, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		if err := mergeSources(filename, split, remaining); err != nil {
+			return err
+		}
+		opts.logf("Updated original: %s (merged private content into the split file of the same name)\n", filename)
+
+		return nil
+	}
+
+	// Under MinimalDiff the moved code is cut out of the source, leaving the
+	// rest as it was written
+	if opts.MinimalDiff {
+		trimmed, err := minimalDiffSource(src, node, decls, comments, markers, fset)
+		if err == nil {
+			if err := writeSource(filename, trimmed); err != nil {
+				return err
+			}
+			opts.recordUpdated(filename)
+			opts.logf("Updated original: %s (removed the moved code only)\n", filename)
+
+			return nil
+		}
+		opts.logf("Warning: reformatting %s: %v\n", filename, err)
+	}
+
 	// Format and write back
-	if err := formatAndWriteFile(filename, node, fset); err != nil {
+	if err := formatAndWriteFile(filename, "", node, fset); err != nil {
 		return err
 	}
 
-	fmt.Printf("Updated original: %s (preserved private content)\n", filename)
+	opts.recordUpdated(filename)
+	opts.logf("Updated original: %s (preserved private content)\n", filename)
 
 	return nil
 }
 
-func removeExtractedTests(filename string, extractedTests []TestFunction, fset *token.FileSet) error {
+// moveMarkers returns a "// Moved to <file>" comment for each moved function
+// and method, placed where its declaration started in node, a fresh parse of
+// filename. Functions split into filename itself get none.
+func moveMarkers(filename string, node *ast.File, funcs []PublicFunction, methods []PublicMethod, fset *token.FileSet) ([]*ast.CommentGroup, error) {
+	files, err := declaringFiles(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find moved functions: %w", err)
+	}
+
+	// The moved declarations come from an earlier parse of the same source
+	file := fset.File(node.Pos())
+	var markers []*ast.CommentGroup
+	for _, fn := range movedFuncDecls(funcs, methods) {
+		target, ok := files[funcDeclKey(fn)]
+		if !ok {
+			continue
+		}
+		start := fn.Pos()
+		if fn.Doc != nil {
+			start = fn.Doc.Pos()
+		}
+		markers = append(markers, &ast.CommentGroup{List: []*ast.Comment{{
+			Slash: file.Pos(fset.Position(start).Offset),
+			Text:  "// Moved to " + filepath.Base(target),
+		}}})
+	}
+
+	return markers, nil
+}
+
+// restoreOriginalFile puts back the content filename had before splitting
+// when a split file was written over it, like runner.go for type Runner, so
+// KeepOriginal leaves the original untouched.
+func restoreOriginalFile(opts Options, filename string, src []byte) error {
+	current, err := os.ReadFile(filename)
+	if err == nil && bytes.Equal(current, src) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := writeSource(filename, src); err != nil {
+		return err
+	}
+	opts.forgetCreated(filename)
+	opts.logf("Warning: kept %s; the split file written over it was discarded\n", filename)
+
+	return nil
+}
+
+func removeExtractedTests(opts Options, filename string, extractedTests []TestFunction, fset *token.FileSet) error {
+	if opts.KeepOriginal {
+		return nil
+	}
+
 	src, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
@@ -242,6 +925,9 @@ func removeExtractedTests(filename string, extractedTests []TestFunction, fset *
 		extractedNames[test.Name] = true
 	}
 
+	// Drop the comments of the extracted tests while their positions are known
+	node.Comments = filterOrphanedComments(node, extractedNames, fset)
+
 	// Filter out the extracted tests
 	var newDecls []ast.Decl
 	hasRemainingContent := false
@@ -266,7 +952,8 @@ func removeExtractedTests(filename string, extractedTests []TestFunction, fset *
 		if err := os.Remove(filename); err != nil {
 			return fmt.Errorf("failed to delete empty file: %w", err)
 		}
-		fmt.Printf("Deleted original (now empty): %s\n", filename)
+		opts.recordDeleted(filename)
+		opts.logf("Deleted original (now empty): %s\n", filename)
 
 		return nil
 	}
@@ -290,111 +977,243 @@ func removeExtractedTests(filename string, extractedTests []TestFunction, fset *
 
 	node.Decls = finalDecls
 
-	// Filter comments to remove those belonging to extracted tests
-	removedCommentTexts := make(map[string]bool)
-
-	// Collect comment texts from extracted tests
-	for _, test := range extractedTests {
-		// Use the original FuncDecl from the extracted test
-		if test.FuncDecl != nil {
-			// Remove doc comments
-			if test.FuncDecl.Doc != nil {
-				for _, c := range test.FuncDecl.Doc.List {
-					removedCommentTexts[c.Text] = true
-				}
-			}
-			// Remove inline comments collected during extraction
-			for _, cg := range test.InlineComments {
-				for _, c := range cg.List {
-					removedCommentTexts[c.Text] = true
-				}
-			}
-			// Remove standalone comments
-			for _, cg := range test.StandaloneComments {
-				for _, c := range cg.List {
-					removedCommentTexts[c.Text] = true
-				}
-			}
-		}
-	}
-
-	// Keep only comment groups that don't contain removed comment texts
-	var remainingComments []*ast.CommentGroup
-	for _, cg := range node.Comments {
-		shouldKeep := true
-		for _, c := range cg.List {
-			if removedCommentTexts[c.Text] {
-				shouldKeep = false
-
-				break
-			}
-		}
-		if shouldKeep {
-			remainingComments = append(remainingComments, cg)
-		}
-	}
-	node.Comments = remainingComments
-
 	// Format and write back
-	if err := formatAndWriteFile(filename, node, fset); err != nil {
+	if err := formatAndWriteFile(filename, "", node, fset); err != nil {
 		return err
 	}
 
-	fmt.Printf("Preserved original: %s (contains non-split tests or helper functions)\n", filename)
+	opts.recordUpdated(filename)
+	opts.logf("Preserved original: %s (contains non-split tests or helper functions)\n", filename)
 
 	return nil
 }
 
 // writeMethodsAndDeclarations handles writing methods and declarations based on strategy.
-func writeMethodsAndDeclarations(strategy MethodStrategy, outputDir string, publicDecls []PublicDeclaration, publicMethods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
-	if strategy == MethodStrategyWithStruct {
-		return writeMethodsWithStructs(outputDir, publicDecls, publicMethods, packageName, imports, fset)
+func writeMethodsAndDeclarations(opts Options, outputDir string, publicDecls []PublicDeclaration, constructors map[string][]PublicFunction, publicMethods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	// Write each public interface to its own file when requested
+	if opts.SplitInterfaces {
+		var err error
+		publicDecls, err = writeInterfaces(opts, outputDir, publicDecls, packageName, imports, fset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.groupsByType() {
+		return writeMethodsWithStructs(opts, outputDir, publicDecls, constructors, publicMethods, packageName, imports, fset)
+	}
+
+	// Strategy: mixed - Gather the unexported methods of each type
+	if opts.MethodStrategy == MethodStrategyMixed {
+		var privateMethods []PublicMethod
+		publicMethods, privateMethods = partitionMethods(publicMethods)
+		if err := writePrivateMethods(opts, outputDir, privateMethods, packageName, imports, fset); err != nil {
+			return err
+		}
 	}
 
 	// Strategy: separate - Write methods to individual files
-	if err := writeSeparateMethods(outputDir, publicMethods, fset); err != nil {
+	if err := writeSeparateMethods(opts, outputDir, publicMethods, fset); err != nil {
 		return err
 	}
 
-	// Write public const/var/type declarations to common.go
-	if len(publicDecls) > 0 {
-		commonFile := filepath.Join(outputDir, "common.go")
+	// Write each public type to its own file when requested
+	if opts.SplitTypes || opts.NoCommonFile {
+		prefix := ""
+		if opts.SplitTypes {
+			prefix = "type_"
+		}
+		var err error
+		publicDecls, err = writeTypes(opts, outputDir, prefix, publicDecls, packageName, imports, fset)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write each public var/const block to its own file when requested
+	if opts.GroupVarsByBlock || opts.NoCommonFile {
+		var err error
+		publicDecls, err = writeDeclarationBlocks(opts, outputDir, publicDecls, packageName, imports, fset)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write public const/var/type declarations to common.go. Under
+	// NoCommonFile, only the private parts of mixed type blocks are left,
+	// and those stay in the original file.
+	if len(publicDecls) > 0 && !opts.NoCommonFile {
+		commonFile, err := opts.commonFileFor(outputDir, packageName)
+		if err != nil {
+			return err
+		}
 		if err := writeCommonFile(commonFile, publicDecls, packageName, imports, fset); err != nil {
 			return fmt.Errorf("failed to write common.go: %w", err)
 		}
-		fmt.Printf("Created: %s\n", commonFile)
+		if err := opts.sortFile(commonFile); err != nil {
+			return err
+		}
+		opts.recordCreated(commonFile)
+		opts.logf("Created: %s\n", commonFile)
 	}
 
 	return nil
 }
 
 // writeSeparateMethods writes each method to its own file.
-func writeSeparateMethods(outputDir string, publicMethods []PublicMethod, fset *token.FileSet) error {
+func writeSeparateMethods(opts Options, outputDir string, publicMethods []PublicMethod, fset *token.FileSet) error {
 	for _, method := range publicMethods {
-		snakeCaseName := methodNameToSnakeCase(method.ReceiverType, method.Name)
-		outputFileName := snakeCaseName + ".go"
-		outputFile := filepath.Join(outputDir, outputFileName)
+		snakeCaseName := opts.methodBaseName(method.ReceiverType, method.Name)
+		outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
 
 		if err := writePublicMethod(outputFile, method, fset); err != nil {
 			return fmt.Errorf("failed to write method file %s: %w", outputFile, err)
 		}
-		fmt.Printf("Created: %s\n", outputFile)
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s\n", outputFile)
 	}
 
 	return nil
 }
 
+// partitionMethods splits methods into the exported and unexported ones.
+func partitionMethods(methods []PublicMethod) ([]PublicMethod, []PublicMethod) {
+	var exported, unexported []PublicMethod
+	for _, method := range methods {
+		if isPublicName(method.Name) {
+			exported = append(exported, method)
+		} else {
+			unexported = append(unexported, method)
+		}
+	}
+
+	return exported, unexported
+}
+
+// groupsByType reports whether types are written together with their
+// constructors and methods, as with-struct does.
+func (opts Options) groupsByType() bool {
+	return opts.MethodStrategy == MethodStrategyWithStruct || opts.GroupByType
+}
+
+// privateMethodsFileName returns the name of the file the mixed strategy
+// gathers the unexported methods of typeName in, e.g. server_private.go.
+func (opts Options) privateMethodsFileName(typeName string) string {
+	return opts.goFileName(opts.typeBaseName(typeName) + "_private")
+}
+
+// writePrivateMethods writes the unexported methods of each type to the
+// type's private file, after those other files already put there.
+func writePrivateMethods(opts Options, outputDir string, methods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	methodsByType := make(map[string][]PublicMethod)
+	for _, method := range methods {
+		methodsByType[method.ReceiverType] = append(methodsByType[method.ReceiverType], method)
+	}
+	typeNames := make([]string, 0, len(methodsByType))
+	for typeName := range methodsByType {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+
+	for _, typeName := range typeNames {
+		outputFile := filepath.Join(outputDir, opts.privateMethodsFileName(typeName))
+		if err := appendMethodsToFile(outputFile, methodsByType[typeName], packageName, imports, fset); err != nil {
+			return fmt.Errorf("failed to write method file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (with %d methods)\n", outputFile, len(methodsByType[typeName]))
+	}
+
+	return nil
+}
+
+// addProvenance records on each extracted item where it was split from.
+func addProvenance(filename string, node *ast.File, publicFuncs []PublicFunction, publicDecls []PublicDeclaration, publicMethods []PublicMethod) {
+	for i := range publicFuncs {
+		publicFuncs[i].Provenance = provenanceHeader(filename, node, publicFuncs[i].FuncDecl)
+	}
+	for i := range publicDecls {
+		publicDecls[i].Provenance = provenanceHeader(filename, node, publicDecls[i].GenDecl)
+	}
+	for i := range publicMethods {
+		publicMethods[i].Provenance = provenanceHeader(filename, node, publicMethods[i].FuncDecl)
+	}
+}
+
+// fileHeader returns what the files split from node start with: its license
+// header, under LicenseHeader, and the build constraint.
+func (opts Options) fileHeader(node *ast.File, constraint string) string {
+	license := ""
+	if opts.LicenseHeader {
+		license = findLicenseHeader(node)
+	}
+
+	return joinHeaders(license, constraint)
+}
+
+// addHeader puts header first in the header of each extracted item.
+func addHeader(header string, publicFuncs []PublicFunction, publicDecls []PublicDeclaration, publicMethods []PublicMethod) {
+	for i := range publicFuncs {
+		publicFuncs[i].Provenance = joinHeaders(header, publicFuncs[i].Provenance)
+	}
+	for i := range publicDecls {
+		publicDecls[i].Provenance = joinHeaders(header, publicDecls[i].Provenance)
+	}
+	for i := range publicMethods {
+		publicMethods[i].Provenance = joinHeaders(header, publicMethods[i].Provenance)
+	}
+}
+
+// joinHeaders joins the non-empty parts of a file header, keeping them apart
+// by blank lines.
+func joinHeaders(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// provenanceHeader returns the comment naming the file decl was split from and
+// its 1-based position among that file's non-import declarations.
+func provenanceHeader(filename string, node *ast.File, decl ast.Decl) string {
+	index := 0
+	for _, d := range node.Decls {
+		if genDecl, ok := d.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			continue
+		}
+		index++
+		if d == decl {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%s%s (declaration %d).", provenancePrefix, filepath.Base(filename), index)
+}
+
+// provenancePrefix starts the comments provenanceHeader returns.
+const provenancePrefix = "// Code split from "
+
 // Helper functions for updateOriginalFile to reduce complexity
 
-func buildExtractionMaps(extractedFuncs []PublicFunction, extractedDecls []PublicDeclaration, extractedMethods []PublicMethod) (map[string]bool, map[*ast.GenDecl]bool, map[string]bool) {
+func buildExtractionMaps(extractedFuncs []PublicFunction, extractedDecls []PublicDeclaration, extractedMethods []PublicMethod) (map[string]bool, map[string]bool, map[string]bool) {
 	extractedFuncNames := make(map[string]bool)
 	for _, fn := range extractedFuncs {
 		extractedFuncNames[fn.Name] = true
 	}
 
-	extractedDeclPtrs := make(map[*ast.GenDecl]bool)
+	// Specs are told apart by their names, as the original file is parsed
+	// again; split blocks only carry the specs that moved
+	extractedSpecNames := make(map[string]bool)
 	for _, decl := range extractedDecls {
-		extractedDeclPtrs[decl.GenDecl] = true
+		for _, name := range declaredNames(decl.GenDecl) {
+			if name != "_" {
+				extractedSpecNames[name] = true
+			}
+		}
 	}
 
 	extractedMethodKeys := make(map[string]bool)
@@ -403,15 +1222,22 @@ func buildExtractionMaps(extractedFuncs []PublicFunction, extractedDecls []Publi
 		extractedMethodKeys[key] = true
 	}
 
-	return extractedFuncNames, extractedDeclPtrs, extractedMethodKeys
+	return extractedFuncNames, extractedSpecNames, extractedMethodKeys
 }
 
-func filterDeclarations(decls []ast.Decl, extractedFuncNames map[string]bool, extractedDeclPtrs map[*ast.GenDecl]bool, extractedMethodKeys map[string]bool) ([]ast.Decl, bool) {
+func filterDeclarations(decls []ast.Decl, extractedFuncNames map[string]bool, extractedSpecNames map[string]bool, extractedMethodKeys map[string]bool) ([]ast.Decl, bool) {
 	var newDecls []ast.Decl
 	hasRemainingContent := false
 
 	for _, decl := range decls {
-		if shouldKeepDeclaration(decl, extractedFuncNames, extractedDeclPtrs, extractedMethodKeys) {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			genDecl = remainingGenDecl(genDecl, extractedSpecNames)
+			if genDecl == nil {
+				continue
+			}
+			decl = genDecl
+		}
+		if shouldKeepDeclaration(decl, extractedFuncNames, extractedMethodKeys) {
 			newDecls = append(newDecls, decl)
 			hasRemainingContent = true
 		}
@@ -420,12 +1246,12 @@ func filterDeclarations(decls []ast.Decl, extractedFuncNames map[string]bool, ex
 	return newDecls, hasRemainingContent
 }
 
-func shouldKeepDeclaration(decl ast.Decl, extractedFuncNames map[string]bool, extractedDeclPtrs map[*ast.GenDecl]bool, extractedMethodKeys map[string]bool) bool {
+func shouldKeepDeclaration(decl ast.Decl, extractedFuncNames map[string]bool, extractedMethodKeys map[string]bool) bool {
 	switch d := decl.(type) {
 	case *ast.FuncDecl:
 		return shouldKeepFunction(d, extractedFuncNames, extractedMethodKeys)
 	case *ast.GenDecl:
-		return shouldKeepGenDecl(d, extractedDeclPtrs)
+		return d.Tok != token.IMPORT // We'll re-add imports later if needed
 	default:
 		return false
 	}
@@ -447,37 +1273,31 @@ func shouldKeepFunction(d *ast.FuncDecl, extractedFuncNames map[string]bool, ext
 	return !extractedFuncNames[d.Name.Name]
 }
 
-func shouldKeepGenDecl(d *ast.GenDecl, extractedDeclPtrs map[*ast.GenDecl]bool) bool {
+// remainingGenDecl returns d without the specs that were extracted, or nil
+// when none is left. The doc comment of a block split in two went with its
+// public part.
+func remainingGenDecl(d *ast.GenDecl, extractedSpecNames map[string]bool) *ast.GenDecl {
 	if d.Tok == token.IMPORT {
-		return false // We'll re-add imports later if needed
-	}
-
-	// Keep private declarations
-	if extractedDeclPtrs[d] {
-		return false
+		return d
 	}
 
-	// Check if this declaration has any private members
-	return hasPrivateMembers(d)
-}
+	remaining := slices.DeleteFunc(slices.Clone(d.Specs), func(spec ast.Spec) bool {
+		return slices.ContainsFunc(specNames(spec), func(name string) bool {
+			return extractedSpecNames[name]
+		})
+	})
+	switch len(remaining) {
+	case 0:
+		return nil
+	case len(d.Specs):
+		return d
+	default:
+		genDecl := *d
+		genDecl.Doc = nil
+		genDecl.Specs = remaining
 
-func hasPrivateMembers(d *ast.GenDecl) bool {
-	for _, spec := range d.Specs {
-		switch s := spec.(type) {
-		case *ast.ValueSpec:
-			for _, name := range s.Names {
-				if !unicode.IsUpper(rune(name.Name[0])) {
-					return true
-				}
-			}
-		case *ast.TypeSpec:
-			if !unicode.IsUpper(rune(s.Name.Name[0])) {
-				return true
-			}
-		}
+		return &genDecl
 	}
-
-	return false
 }
 
 func addFunctionComments(removedCommentTexts *map[string]bool, extractedFuncs []PublicFunction) {
@@ -513,6 +1333,28 @@ func addDeclarationComments(removedCommentTexts *map[string]bool, extractedDecls
 				(*removedCommentTexts)[c.Text] = true
 			}
 		}
+
+		for _, cg := range decl.StandaloneComments {
+			for _, c := range cg.List {
+				(*removedCommentTexts)[c.Text] = true
+			}
+		}
+
+		// The comments inside a declaration, on its specs or struct fields,
+		// leave with it
+		inner := slices.Clone(decl.InlineComments)
+		ast.Inspect(decl.GenDecl, func(n ast.Node) bool {
+			if cg, ok := n.(*ast.CommentGroup); ok {
+				inner = append(inner, cg)
+			}
+
+			return true
+		})
+		for _, cg := range inner {
+			for _, c := range cg.List {
+				(*removedCommentTexts)[c.Text] = true
+			}
+		}
 	}
 }
 
@@ -542,13 +1384,21 @@ func addMethodComments(removedCommentTexts *map[string]bool, extractedMethods []
 	}
 }
 
-func splitTestForFunction(testFile string, functionName string, outputDir string) error {
+func splitTestForFunction(testFile string, functionName string, outputDir string, opts Options) error {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, testFile, nil, parser.ParseComments)
 	if err != nil {
 		return fmt.Errorf("failed to parse test file: %w", err)
 	}
 
+	// The tests keep the constraint of their file, or else the one of the
+	// function they test
+	constraint := buildConstraintLines(node)
+	if constraint == "" {
+		constraint = opts.buildConstraint
+	}
+	header := opts.fileHeader(node, constraint)
+
 	// Find test functions that match the public function name
 	var matchingTests []TestFunction
 	for _, decl := range node.Decls {
@@ -557,21 +1407,8 @@ func splitTestForFunction(testFile string, functionName string, outputDir string
 			continue
 		}
 
-		// Check if test name contains the function name
-		if strings.Contains(fn.Name.Name, functionName) {
-			var standaloneComments []*ast.CommentGroup
-			var inlineComments []*ast.CommentGroup
-			for _, cg := range node.Comments {
-				if cg == fn.Doc {
-					continue
-				}
-				// Check if comment is inside the function body
-				if fn.Body != nil && cg.Pos() >= fn.Body.Lbrace && cg.End() <= fn.Body.Rbrace {
-					inlineComments = append(inlineComments, cg)
-				} else if isFunctionSpecificComment(cg, fn, node.Decls) {
-					standaloneComments = append(standaloneComments, cg)
-				}
-			}
+		if isTestFor(fn.Name.Name, functionName) {
+			standaloneComments, inlineComments := collectFunctionComments(node, fn, fset)
 
 			test := TestFunction{
 				Name:               fn.Name.Name,
@@ -582,27 +1419,239 @@ func splitTestForFunction(testFile string, functionName string, outputDir string
 				Imports:            node.Imports,
 				Package:            node.Name.Name,
 			}
+			if opts.AddProvenance {
+				test.Provenance = provenanceHeader(testFile, node, fn)
+			}
+			test.Provenance = joinHeaders(header, test.Provenance)
 			matchingTests = append(matchingTests, test)
 		}
 	}
 
 	// Write matching tests to new file
 	if len(matchingTests) > 0 {
-		snakeCaseName := functionNameToSnakeCase(functionName)
-		outputFileName := snakeCaseName + "_test.go"
-		outputFile := filepath.Join(outputDir, outputFileName)
+		snakeCaseName := opts.fileBaseName(functionName)
+		outputFile, err := opts.testFileFor(outputDir, snakeCaseName, node.Name.Name)
+		if err != nil {
+			return err
+		}
+		if outputFile == testFile {
+			// The tests already live in their own file
+			return nil
+		}
 
-		// Write all matching tests to the same file
-		if err := writeTestsToFile(outputFile, matchingTests, fset); err != nil {
+		helpers, err := exclusiveTestHelpers(opts, testFile, node, matchingTests, fset)
+		if err != nil {
+			return err
+		}
+		matchingTests = withTestHelpers(matchingTests, helpers)
+
+		// Write all matching tests to the same file, after any tests of the
+		// same package already there
+		if err := appendTestsToFile(outputFile, matchingTests, fset); err != nil {
 			return fmt.Errorf("failed to write test file: %w", err)
 		}
-		fmt.Printf("Created test file: %s\n", outputFile)
+		if err := opts.sortFile(outputFile); err != nil {
+			return err
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created test file: %s\n", outputFile)
 
 		// Remove the extracted tests from the original test file
-		if err := removeExtractedTests(testFile, matchingTests, fset); err != nil {
+		if err := removeExtractedTests(opts, testFile, matchingTests, fset); err != nil {
 			return fmt.Errorf("failed to update original test file: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// consolidateTestHelpers moves the contents of testFile to the package's
+// testhelpers_test.go and deletes it, when all it has left are helpers and
+// declarations: no tests, benchmarks, examples or fuzz tests, and no build
+// constraint, in its name or otherwise, that the shared file would lose.
+func consolidateTestHelpers(opts Options, testFile string) error {
+	if opts.KeepOriginal {
+		return nil
+	}
+
+	src, err := os.ReadFile(testFile)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, testFile, src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+	if splitFileSuffix(testFile, buildConstraintLines(node)) != "" {
+		return nil
+	}
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && isTestEntry(fn.Name.Name) {
+			return nil
+		}
+	}
+
+	// The helpers are for every platform, whatever the split file was for
+	opts.buildSuffix = ""
+	outputFile, err := opts.testFileFor(filepath.Dir(testFile), testHelpersBaseName, node.Name.Name)
+	if err != nil {
+		return err
+	}
+	if outputFile == testFile {
+		return nil
+	}
+
+	existing, err := os.ReadFile(outputFile)
+	switch {
+	case os.IsNotExist(err):
+		err = os.WriteFile(outputFile, src, newFileMode)
+	case err == nil:
+		err = mergeSources(outputFile, existing, src)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	opts.recordCreated(outputFile)
+	opts.logf("Created test file: %s (with the helpers of %s)\n", outputFile, testFile)
+
+	if err := os.Remove(testFile); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	opts.recordDeleted(testFile)
+	opts.logf("Deleted original (helpers moved): %s\n", testFile)
+
+	return nil
+}
+
+// exclusiveTestHelpers returns, under MoveExclusiveHelpers, the functions of
+// the test file node that exactly one of tests and nothing else in the package
+// refers to, keyed by the name of that test, to move along with it. Helpers
+// shared with tests that stay keep the test file from being deleted.
+func exclusiveTestHelpers(opts Options, filename string, node *ast.File, tests []TestFunction, fset *token.FileSet) (map[string][]TestFunction, error) {
+	if !opts.MoveExclusiveHelpers {
+		return nil, nil
+	}
+
+	siblings, err := parseSiblingFiles(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package files: %w", err)
+	}
+
+	owners := make([]PublicFunction, 0, len(tests))
+	for _, test := range tests {
+		owners = append(owners, PublicFunction(test))
+	}
+
+	helpers := make(map[string][]TestFunction)
+	for owner, fns := range findExclusiveHelpers(node, owners, siblings, fset) {
+		for _, fn := range fns {
+			helpers[owner] = append(helpers[owner], TestFunction(fn))
+		}
+	}
+
+	return helpers, nil
+}
+
+// scaffoldTest writes a Test<Name> stub skipping itself to the test file of
+// the public function fn, unless a test file in outputDir already has a test
+// of it, such as TestParse or ExampleParse for Parse.
+func scaffoldTest(opts Options, outputDir string, fn PublicFunction, header string) error {
+	tested, err := hasTestFor(outputDir, fn.Name)
+	if err != nil || tested {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	src := fmt.Sprintf("package %s\n\nimport \"testing\"\n\nfunc Test%s(t *testing.T) {\n\tt.Skip(\"TODO\")\n}\n", fn.Package, fn.Name)
+	node, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse test stub: %w", err)
+	}
+	stub, ok := node.Decls[len(node.Decls)-1].(*ast.FuncDecl)
+	if !ok {
+		return fmt.Errorf("failed to parse test stub: %w", ErrTypeCast)
+	}
+
+	outputFile, err := opts.testFileFor(outputDir, opts.fileBaseName(fn.Name), fn.Package)
+	if err != nil {
+		return err
+	}
+	test := TestFunction{
+		Name:       stub.Name.Name,
+		FuncDecl:   stub,
+		Imports:    node.Imports,
+		Package:    fn.Package,
+		Provenance: header,
+	}
+	if err := appendTestsToFile(outputFile, []TestFunction{test}, fset); err != nil {
+		return fmt.Errorf("failed to write test stub %s: %w", outputFile, err)
+	}
+	opts.recordCreated(outputFile)
+	opts.logf("Created test stub: %s\n", outputFile)
+
+	return nil
+}
+
+// hasTestFor reports whether a test file in dir has a test, benchmark,
+// example or fuzz test of the function functionName.
+func hasTestFor(dir, functionName string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		node, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		for _, decl := range node.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && isTestFor(fn.Name.Name, functionName) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isTestEntry reports whether name is that of a test, benchmark, example or
+// fuzz test, which go test runs: TestParse or Example, but not Testify.
+func isTestEntry(name string) bool {
+	for _, kind := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		rest, ok := strings.CutPrefix(name, kind)
+		if !ok {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(rest)
+		if rest == "" || !unicode.IsLower(next) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTestFor reports whether testName is a test, benchmark, example or fuzz
+// test of functionName: TestParse, TestParse_Empty, TestParseConfig,
+// BenchmarkParse and ExampleParse belong to Parse, TestReparse and TestParsed
+// don't.
+func isTestFor(testName, functionName string) bool {
+	for _, kind := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		rest, ok := strings.CutPrefix(testName, kind+functionName)
+		if !ok {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(rest)
+		if rest == "" || !unicode.IsLower(next) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,8 +1,17 @@
 package splitter
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -58,7 +67,7 @@ func TestPrivateFunc(t *testing.T) {
 	}
 
 	// Run SplitPublicFunctions
-	if err := SplitPublicFunctions(tmpDir, MethodStrategySeparate); err != nil {
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategySeparate}); err != nil {
 		t.Fatalf("SplitPublicFunctions failed: %v", err)
 	}
 
@@ -119,7 +128,7 @@ func helperFunc() {
 	}
 
 	// Run SplitTestFunctions
-	if err := SplitTestFunctions(tmpDir); err != nil {
+	if err := SplitTestFunctions(tmpDir, Options{}); err != nil {
 		t.Fatalf("SplitTestFunctions failed: %v", err)
 	}
 
@@ -144,3 +153,5555 @@ func helperFunc() {
 		}
 	}
 }
+
+func TestSplitPublicFunctions_ReservedFileName(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "device.go")
+	testContent := `package device
+
+func Con() string {
+	return "console"
+}
+
+func helper() {}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "con_.go")); err != nil {
+		t.Errorf("Expected con_.go to be created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "con.go")); !os.IsNotExist(err) {
+		t.Error("Reserved file name con.go should not be created")
+	}
+}
+
+func TestSplitPublicFunctions_GroupVarsByBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "config.go")
+	testContent := `package config
+
+import (
+	"io"
+	"go/ast"
+	"errors"
+	"time"
+)
+
+var (
+	DefaultTimeout = 5 * time.Second
+	DefaultRetries = 3
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid")
+)
+
+type Config struct{}
+
+func helper() {}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{GroupVarsByBlock: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"default_timeout.go": {"DefaultTimeout", "DefaultRetries", `"time"`},
+		"err_not_found.go":   {"ErrNotFound", "ErrInvalid", `"errors"`},
+		"common.go":          {"type Config struct"},
+	}
+	unexpected := map[string][]string{
+		"default_timeout.go": {`"errors"`, "ErrNotFound"},
+		"err_not_found.go":   {`"time"`, "DefaultTimeout"},
+		"common.go":          {"DefaultTimeout", "ErrNotFound"},
+	}
+
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+		for _, want := range contents {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q", file, want)
+			}
+		}
+		for _, notWant := range unexpected[file] {
+			if strings.Contains(string(content), notWant) {
+				t.Errorf("%s should not contain %q", file, notWant)
+			}
+		}
+	}
+}
+
+func TestSplitTestFunctions_ExternalTestPackage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "parser_test.go")
+	testContent := `package parser_test
+
+import (
+	"io"
+	"go/ast"
+	"strings"
+	"testing"
+
+	"example.com/parser"
+)
+
+func TestParse(t *testing.T) {
+	if parser.Parse("a") != "a" {
+		t.Error("unexpected result")
+	}
+}
+
+func TestParseUpper(t *testing.T) {
+	if parser.Parse(strings.ToUpper("a")) != "A" {
+		t.Error("unexpected result")
+	}
+}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitTestFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitTestFunctions failed: %v", err)
+	}
+
+	for _, file := range []string{"parse_test.go", "parse_upper_test.go"} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+
+		node, err := parser.ParseFile(token.NewFileSet(), file, content, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("Generated file %s does not parse: %v", file, err)
+		}
+		if node.Name.Name != "parser_test" {
+			t.Errorf("%s declares package %q, want %q", file, node.Name.Name, "parser_test")
+		}
+
+		hasPackageImport := false
+		for _, imp := range node.Imports {
+			if imp.Path.Value == `"example.com/parser"` {
+				hasPackageImport = true
+			}
+		}
+		if !hasPackageImport {
+			t.Errorf("%s should import the package under test", file)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_ExternalTestPackage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"parser.go": `package parser
+
+func Parse(s string) string {
+	return s
+}
+
+func helper() {}
+`,
+		"parser_test.go": `package parser_test
+
+import (
+	"io"
+	"go/ast"
+	"testing"
+
+	"example.com/parser"
+)
+
+func TestParse(t *testing.T) {
+	if parser.Parse("a") != "a" {
+		t.Error("unexpected result")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "parse_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parse_test.go to be created: %v", err)
+	}
+	if !strings.Contains(string(content), "package parser_test") {
+		t.Error("parse_test.go should keep the external test package clause")
+	}
+	if !strings.Contains(string(content), `"example.com/parser"`) {
+		t.Error("parse_test.go should import the package under test")
+	}
+}
+
+func TestSplitPublicFunctions_KeepLineDirectives(t *testing.T) {
+	testContent := `package gen
+
+//line template.tmpl:10
+func Render() string {
+	return "rendered"
+}
+
+func helper() {}
+`
+
+	t.Run("skipped with keep-line-directives", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "gen.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SplitPublicFunctions(tmpDir, Options{KeepLineDirectives: true}); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		content, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != testContent {
+			t.Error("File with //line directive should be left untouched")
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "render.go")); !os.IsNotExist(err) {
+			t.Error("render.go should not be created")
+		}
+	})
+
+	t.Run("split by default", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "gen.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tmpDir, "render.go")); err != nil {
+			t.Errorf("render.go should be created: %v", err)
+		}
+	})
+}
+
+func TestSplitPublicFunctions_KeepsFunctionText(t *testing.T) {
+	function := `// Lookup returns the code of name.
+//
+// Codes are listed by hand:
+//
+//	one   → 1
+//	three → 3
+func Lookup( /* no context */ name string) int {
+	codes := map[string]int{
+		"one":   1, // first
+		"three": 3, /* third */
+		// spelled out
+		"twenty": 20,
+	}
+	fmt.Println(name /* asked */, len(codes)) // trace
+
+	return codes[name]
+} // Lookup`
+	testContent := "package sample\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\n" +
+		function + "\n\nfunc helper() { os.Exit(0) }\n"
+
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "sample.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "lookup.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package sample\n\nimport \"fmt\"\n\n" + function + "\n"
+	if string(content) != want {
+		t.Errorf("lookup.go should hold the function as written.\nGot:\n%s\nWant:\n%s", content, want)
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(original), "no context") {
+		t.Errorf("The comment in the signature should move with the function, got:\n%s", original)
+	}
+}
+
+func TestSplitPublicFunctions_MinimalDiff(t *testing.T) {
+	// helper and the var block aren't gofmt'ed, so reformatting would show
+	testContent := `package sample
+
+import (
+	"fmt"
+	"strings" // for Upper
+)
+
+var (
+	Version = "1" // exported
+	build   =   "dev"
+)
+
+// Upper uppercases s.
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
+
+func helper()  {
+	fmt.Println( build,Version )
+}
+
+// Lower lowercases s.
+func Lower(s string) string { return strings.ToLower(s) }
+`
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "unrelated code is kept byte for byte",
+			opts: Options{MinimalDiff: true, Output: io.Discard},
+			want: `package sample
+
+import (
+	"fmt"
+)
+
+var (
+	build   =   "dev"
+)
+
+func helper()  {
+	fmt.Println( build,Version )
+}
+`,
+		},
+		{
+			name: "move markers take the place of the functions",
+			opts: Options{MinimalDiff: true, LeaveMoveMarker: true, Output: io.Discard},
+			want: `package sample
+
+import (
+	"fmt"
+)
+
+var (
+	build   =   "dev"
+)
+
+// Moved to upper.go
+
+func helper()  {
+	fmt.Println( build,Version )
+}
+
+// Moved to lower.go
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "sample.go")
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("Unexpected original.\nGot:\n%s\nWant:\n%s", content, tt.want)
+			}
+			for _, name := range []string{"upper.go", "lower.go", "common.go"} {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+					t.Errorf("%s should be created: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_Formatter(t *testing.T) {
+	testContent := `package sample
+
+// marker: kept here
+var version = "1"
+
+// marker: moved along
+func Hello() string {
+	return version
+}
+`
+
+	t.Run("runs over written files", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "sample.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var calls int
+		opts := Options{
+			Output: io.Discard,
+			Formatter: func(src []byte) ([]byte, error) {
+				calls++
+
+				return bytes.ReplaceAll(src, []byte("// marker:"), []byte("// MARKER:")), nil
+			},
+		}
+		if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		for name, want := range map[string]string{
+			"hello.go":  "// MARKER: moved along\nfunc Hello() string {",
+			"sample.go": "// MARKER: kept here\nvar version = \"1\"",
+		} {
+			content, err := os.ReadFile(filepath.Join(tmpDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should have gone through the formatter, got:\n%s", name, content)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("Formatter should run once per written file, ran %d times", calls)
+		}
+	})
+
+	t.Run("errors are returned", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "sample.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		errFormat := errors.New("bad formatter")
+		opts := Options{
+			Output: io.Discard,
+			Formatter: func([]byte) ([]byte, error) {
+				return nil, errFormat
+			},
+		}
+		if err := SplitPublicFunctions(tmpDir, opts); !errors.Is(err, errFormat) {
+			t.Errorf("Expected the formatter's error, got %v", err)
+		}
+	})
+}
+
+func TestSplitPublicFunctions_Generated(t *testing.T) {
+	testContent := `// Code generated by stringer -type=Color. DO NOT EDIT.
+
+package color
+
+import "strconv"
+
+func Parse(s string) Color {
+	return Color(len(s))
+}
+
+func (c Color) String() string {
+	return strconv.Itoa(int(c))
+}
+
+type Color int
+`
+
+	t.Run("left untouched by default", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "color_string.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		result := &SplitResult{}
+		if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard, Result: result}); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		content, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != testContent {
+			t.Errorf("Generated file should be left untouched, got:\n%s", content)
+		}
+		if result.changes() != 0 {
+			t.Errorf("Expected no changes, got %+v", result)
+		}
+	})
+
+	t.Run("split with process-generated", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testFile := filepath.Join(tmpDir, "color_string.go")
+		if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SplitPublicFunctions(tmpDir, Options{ProcessGenerated: true, Output: io.Discard}); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tmpDir, "parse.go")); err != nil {
+			t.Errorf("parse.go should be created: %v", err)
+		}
+	})
+}
+
+func TestSplitPublicFunctions_SplitInterfaces(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "types.go")
+	testContent := `package store
+
+import (
+	"io"
+	"go/ast"
+	"context"
+	"io"
+)
+
+// Loader loads things.
+type Loader interface {
+	Load(ctx context.Context, key string) error
+}
+
+type (
+	// Decoder decodes a stream.
+	Decoder interface {
+		Decode(r io.Reader) error
+	}
+
+	Item struct {
+		Key string
+	}
+)
+
+func helper() {}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{SplitInterfaces: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"loader.go":  {"// Loader loads things.", "type Loader interface", `"context"`},
+		"decoder.go": {"// Decoder decodes a stream.", "type Decoder interface", `"io"`},
+		"common.go":  {"Item struct"},
+	}
+	unexpected := map[string][]string{
+		"loader.go":  {`"io"`, "Decoder"},
+		"decoder.go": {`"context"`, "Item"},
+		"common.go":  {"Loader", "Decoder"},
+	}
+
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), file, content, 0); err != nil {
+			t.Errorf("%s does not parse: %v", file, err)
+		}
+		for _, want := range contents {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+			}
+		}
+		for _, notWant := range unexpected[file] {
+			if strings.Contains(string(content), notWant) {
+				t.Errorf("%s should not contain %q", file, notWant)
+			}
+		}
+	}
+}
+
+func TestSplitPublicFunctions_WithStructConstructors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "srv.go")
+	testContent := `package srv
+
+import "net/http"
+
+// Server serves requests.
+type Server struct {
+	mux *http.ServeMux
+}
+
+// NewServer creates a Server.
+func NewServer() *Server {
+	// Use a fresh mux
+	return &Server{mux: http.NewServeMux()}
+}
+
+// Default returns a default Server.
+func Default() *Server {
+	return NewServer()
+}
+
+// Start starts the server.
+func (s *Server) Start() {}
+
+// Run is unrelated to Server.
+func Run() error {
+	return nil
+}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "server.go"))
+	if err != nil {
+		t.Fatalf("Expected server.go to be created: %v", err)
+	}
+
+	expectedContents := []string{
+		`"net/http"`,
+		"// Server serves requests.",
+		"type Server struct",
+		"// NewServer creates a Server.",
+		"func NewServer() *Server",
+		"// Use a fresh mux",
+		"func Default() *Server",
+		"// Start starts the server.",
+		"func (s *Server) Start()",
+	}
+	for _, expected := range expectedContents {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("server.go should contain %q, got:\n%s", expected, content)
+		}
+	}
+
+	for _, file := range []string{"new_server.go", "default.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, file)); !os.IsNotExist(err) {
+			t.Errorf("Constructor file %s should not be created", file)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "run.go")); err != nil {
+		t.Errorf("run.go should be created for a non-constructor function: %v", err)
+	}
+}
+
+func TestSplitPublicFunctions_GroupByFirstParam(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "handlers.go")
+	testContent := `package handlers
+
+import (
+	"io"
+	"go/ast"
+	"fmt"
+	"strings"
+)
+
+type Request struct {
+	Path string
+}
+
+type Response struct{}
+
+// HandleIndex handles the index page.
+func HandleIndex(r *Request) string {
+	return fmt.Sprint(r.Path)
+}
+
+func HandleUpper(r Request) string {
+	return strings.ToUpper(r.Path)
+}
+
+func Write(w *Response, body string) {}
+
+func Version() string {
+	return "v1"
+}
+
+func Join(parts []string) string {
+	return strings.Join(parts, ",")
+}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{GroupByFirstParam: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"request.go":  {"// HandleIndex handles the index page.", "func HandleIndex(r *Request)", "func HandleUpper(r Request)", `"fmt"`, `"strings"`},
+		"response.go": {"func Write(w *Response, body string)"},
+		"version.go":  {"func Version() string"},
+		"join.go":     {"func Join(parts []string) string"},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+		for _, want := range contents {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+			}
+		}
+	}
+
+	for _, file := range []string{"handle_index.go", "handle_upper.go", "write.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, file)); !os.IsNotExist(err) {
+			t.Errorf("Grouped function file %s should not be created", file)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_MergesExistingCommonFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	firstContent := `package example
+
+import "time"
+
+const Timeout = 5 * time.Second
+
+func helper() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "first.go"), []byte(firstContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("first SplitPublicFunctions failed: %v", err)
+	}
+
+	// A human adds a declaration to the generated common.go
+	commonFile := filepath.Join(tmpDir, "common.go")
+	content, err := os.ReadFile(commonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manual := string(content) + `
+// Manual was added by hand.
+var Manual = strings.ToUpper("manual")
+`
+	manual = strings.Replace(manual, `import "time"`, "import (\n\t\"strings\"\n\t\"time\"\n)", 1)
+	if err := os.WriteFile(commonFile, []byte(manual), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	secondContent := `package example
+
+var Retries = 3
+
+func other() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "second.go"), []byte(secondContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("second SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err = os.ReadFile(commonFile)
+	if err != nil {
+		t.Fatalf("common.go should still exist: %v", err)
+	}
+
+	expectedContents := []string{
+		"const Timeout = 5 * time.Second",
+		"// Manual was added by hand.",
+		`var Manual = strings.ToUpper("manual")`,
+		"var Retries = 3",
+		`"strings"`,
+		`"time"`,
+	}
+	for _, expected := range expectedContents {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("common.go should contain %q, got:\n%s", expected, content)
+		}
+	}
+	if strings.Count(string(content), "Timeout") != 1 {
+		t.Errorf("common.go should declare Timeout exactly once, got:\n%s", content)
+	}
+}
+
+func TestSplitPublicFunctions_ExistingCommonFileIotaBlock(t *testing.T) {
+	commonContent := `package example
+
+const (
+	A = iota
+	B
+	C
+)
+`
+	tests := []struct {
+		name    string
+		source  string
+		wantErr error
+	}{
+		{
+			name: "whole block redeclared",
+			source: `package example
+
+const (
+	A = iota
+	B
+	C
+)
+
+func helper() {}
+`,
+		},
+		{
+			name: "part of the block redeclared",
+			source: `package example
+
+const B = 10
+
+func helper() {}
+`,
+			wantErr: ErrIotaBlockRedeclared,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			commonFile := filepath.Join(tmpDir, "common.go")
+			if err := os.WriteFile(commonFile, []byte(commonContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "consts.go"), []byte(tc.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("SplitPublicFunctions error = %v, want %v", err, tc.wantErr)
+			}
+
+			content, err := os.ReadFile(commonFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantErr != nil {
+				if string(content) != commonContent {
+					t.Errorf("common.go should be left alone, got:\n%s", content)
+				}
+
+				return
+			}
+			if strings.Count(string(content), "iota") != 1 || !strings.Contains(string(content), "\tB\n\tC\n") {
+				t.Errorf("common.go should hold the block once, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_CommonFileImportConflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"a.go": `package example
+
+import "math/rand"
+
+var X = rand.Intn(2)
+`,
+		"b.go": `package example
+
+import "crypto/rand"
+
+var Y = rand.Reader
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard})
+	if !errors.Is(err, ErrImportConflict) {
+		t.Fatalf("SplitPublicFunctions error = %v, want %v", err, ErrImportConflict)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "common.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "crypto/rand") || strings.Contains(string(content), "var Y") {
+		t.Errorf("common.go should not take Y, got:\n%s", content)
+	}
+	got, err := os.ReadFile(filepath.Join(tmpDir, "b.go"))
+	if err != nil || string(got) != files["b.go"] {
+		t.Errorf("b.go should be left alone: %v", err)
+	}
+}
+
+func TestSplitPublicFunctions_GroupByType(t *testing.T) {
+	testContent := `package server
+
+// Server serves requests.
+type Server struct {
+	addr string
+}
+
+// NewServer creates a Server.
+func NewServer(addr string) *Server {
+	return &Server{addr: addr}
+}
+
+// Start starts the server.
+func (s *Server) Start() error {
+	return nil
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {}
+
+// Version reports the version.
+func Version() string {
+	return "1.0"
+}
+`
+
+	for _, strategy := range []MethodStrategy{MethodStrategySeparate, MethodStrategyMixed} {
+		t.Run(string(strategy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := Options{MethodStrategy: strategy, GroupByType: true, Verify: true, Output: io.Discard}
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "server.go"))
+			if err != nil {
+				t.Fatalf("Expected server.go to be created: %v", err)
+			}
+			for _, want := range []string{"type Server struct", "func NewServer(", "func (s *Server) Start()", "func (s *Server) Stop()"} {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("Expected server.go to contain %q, got:\n%s", want, content)
+				}
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			if want := "server.go version.go"; strings.Join(files, " ") != want {
+				t.Errorf("Expected files %v, got %v", want, files)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_CollidingTypeNames(t *testing.T) {
+	files := map[string]string{
+		"legacy.go": `package web
+
+// HttpServer is the old server.
+type HttpServer struct{}
+
+// Start starts the old server.
+func (s *HttpServer) Start() {}
+`,
+		"server.go": `package web
+
+// HTTPServer is the new server.
+type HTTPServer struct{}
+
+// Start starts the new server.
+func (s *HTTPServer) Start() {}
+
+// Addr returns the address, with a value receiver.
+func (s HTTPServer) Addr() string { return "" }
+`,
+	}
+
+	tests := []struct {
+		strategy MethodStrategy
+		want     map[string][]string
+	}{
+		{
+			strategy: MethodStrategySeparate,
+			want: map[string][]string{
+				"http_server_start.go":   {"func (s *HTTPServer) Start()"},
+				"http_server_addr.go":    {"func (s HTTPServer) Addr()"},
+				"http_server_2_start.go": {"func (s *HttpServer) Start()"},
+			},
+		},
+		{
+			strategy: MethodStrategyWithStruct,
+			want: map[string][]string{
+				"http_server.go":   {"type HTTPServer struct", "func (s *HTTPServer) Start()", "func (s HTTPServer) Addr()"},
+				"http_server_2.go": {"type HttpServer struct", "func (s *HttpServer) Start()"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: tt.strategy, Verify: true, Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			for file, wants := range tt.want {
+				content, err := os.ReadFile(filepath.Join(tmpDir, file))
+				if err != nil {
+					t.Fatalf("Expected %s to be created: %v", file, err)
+				}
+				for _, want := range wants {
+					if !strings.Contains(string(content), want) {
+						t.Errorf("Expected %s to contain %q, got:\n%s", file, want, content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_WithStructRelatedDeclarations(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "srv.go")
+	testContent := `package srv
+
+import "time"
+
+type Server struct{}
+
+type Config struct {
+	Name string
+}
+
+// DefaultServer is the default Server.
+var DefaultServer = &Server{}
+
+// ServerTimeout bounds requests.
+const ServerTimeout = 30 * time.Second
+
+var Version = "v1"
+
+func (s *Server) Start() {}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"server.go": {"type Server struct", "// DefaultServer is the default Server.", "var DefaultServer = &Server{}", "// ServerTimeout bounds requests.", "const ServerTimeout = 30 * time.Second", `"time"`, "func (s *Server) Start()"},
+		"config.go": {"type Config struct"},
+		"common.go": {`var Version = "v1"`},
+	}
+	unexpected := map[string][]string{
+		"common.go": {"DefaultServer", "ServerTimeout", "Config"},
+	}
+
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+		for _, want := range contents {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+			}
+		}
+		for _, notWant := range unexpected[file] {
+			if strings.Contains(string(content), notWant) {
+				t.Errorf("%s should not contain %q", file, notWant)
+			}
+		}
+	}
+}
+
+func TestSplitPublicFunctions_KeepsBuildTaggedOriginal(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "server_linux.go")
+	testContent := `//go:build linux
+
+// Package server serves things.
+package server
+
+// Run starts the server.
+func Run() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected server_linux.go to be preserved: %v", err)
+	}
+	for _, want := range []string{"//go:build linux", "// Package server serves things.", "package server"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("server_linux.go should keep %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "func Run") {
+		t.Error("Run should have been moved out of server_linux.go")
+	}
+
+	// Run is still built for linux only
+	runContent, err := os.ReadFile(filepath.Join(tmpDir, "run_linux.go"))
+	if err != nil {
+		t.Fatalf("Expected run_linux.go to be created: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "run_linux.go", runContent, parser.ParseComments); err != nil {
+		t.Errorf("run_linux.go should be valid Go: %v\n%s", err, runContent)
+	}
+	if !strings.HasPrefix(string(runContent), "//go:build linux\n\npackage server") {
+		t.Errorf("run_linux.go should keep the build constraint, got:\n%s", runContent)
+	}
+}
+
+func TestSplitPublicFunctions_SkipsCgoFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "native.go")
+	testContent := `package native
+
+/*
+int add(int a, int b) { return a + b; }
+*/
+import "C"
+
+// Add adds two numbers in C.
+func Add(a, b int) int {
+	return int(C.add(C.int(a), C.int(b)))
+}
+
+// Version is not cgo-specific but stays with its file.
+func Version() string {
+	return "v1"
+}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected native.go to be preserved: %v", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("native.go should be left untouched, got:\n%s", content)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected no files to be created, got %d entries", len(entries))
+	}
+}
+
+func TestSplitPublicFunctions_IncludePrivate(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		expectedFiles  []string
+		originalExists bool
+	}{
+		{
+			name: "mixed public and private functions",
+			content: `package cfg
+
+// ParseConfig parses the config.
+func ParseConfig(s string) string {
+	return parseLine(s)
+}
+
+// parseLine parses a single line.
+func parseLine(s string) string {
+	return s
+}
+`,
+			expectedFiles:  []string{"parse_config.go", "parse_line.go"},
+			originalExists: false,
+		},
+		{
+			name: "init stays in the original",
+			content: `package cfg
+
+var loaded bool
+
+func init() {
+	loaded = true
+}
+
+func loadDefaults() {}
+`,
+			expectedFiles:  []string{"load_defaults.go"},
+			originalExists: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "config.go")
+			if err := os.WriteFile(testFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{IncludePrivate: true}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			for _, name := range tt.expectedFiles {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+					t.Errorf("Expected %s to be created: %v", name, err)
+				}
+			}
+
+			content, err := os.ReadFile(testFile)
+			if tt.originalExists != (err == nil) {
+				t.Fatalf("Expected original to exist: %v, got error: %v", tt.originalExists, err)
+			}
+			if tt.originalExists && (!strings.Contains(string(content), "func init()") || strings.Contains(string(content), "loadDefaults")) {
+				t.Errorf("Original should keep only init and its state, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_MoveExclusiveHelpers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"config.go": `package cfg
+
+// ParseConfig parses the config.
+func ParseConfig(s string) string {
+	return parseLine(s)
+}
+
+// parseLine is used only by ParseConfig.
+func parseLine(s string) string {
+	return trim(s)
+}
+
+// Validate validates the config.
+func Validate(s string) bool {
+	return trim(s) != "" && isKnown(s)
+}
+
+// trim is shared by parseLine and Validate.
+func trim(s string) string {
+	return s
+}
+
+// isKnown is also used by another file.
+func isKnown(s string) bool {
+	return s != ""
+}
+`,
+		"other.go": `package cfg
+
+func check(s string) bool {
+	return isKnown(s)
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MoveExclusiveHelpers: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	parseContent, err := os.ReadFile(filepath.Join(tmpDir, "parse_config.go"))
+	if err != nil {
+		t.Fatalf("Expected parse_config.go to be created: %v", err)
+	}
+	if !strings.Contains(string(parseContent), "func parseLine(") {
+		t.Error("parse_config.go should contain its exclusive helper parseLine")
+	}
+	if !strings.Contains(string(parseContent), "// parseLine is used only by ParseConfig.") {
+		t.Error("parse_config.go should keep the helper's doc comment")
+	}
+
+	original, err := os.ReadFile(filepath.Join(tmpDir, "config.go"))
+	if err != nil {
+		t.Fatalf("Expected config.go to be preserved: %v", err)
+	}
+	if strings.Contains(string(original), "func parseLine(") {
+		t.Error("parseLine should have been moved out of config.go")
+	}
+	for _, shared := range []string{"func trim(", "func isKnown("} {
+		if !strings.Contains(string(original), shared) {
+			t.Errorf("Shared helper %q should stay in config.go", shared)
+		}
+	}
+}
+
+func TestSplit_TestHelpers(t *testing.T) {
+	source := `package p
+
+func Parse(s string) string { return s }
+
+func Format(s string) string { return s }
+`
+	shared := `package p
+
+import "testing"
+
+// newFixture is used by the tests of both functions.
+func newFixture(t *testing.T) string {
+	t.Helper()
+
+	return "x"
+}
+
+func TestParse(t *testing.T) {
+	if Parse(newFixture(t)) != "x" {
+		t.Fail()
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if Format(newFixture(t)) != "x" {
+		t.Fail()
+	}
+}
+`
+	exclusive := `package p
+
+import "testing"
+
+// newFixture is used by TestParse only.
+func newFixture(t *testing.T) string {
+	t.Helper()
+
+	return "x"
+}
+
+func TestParse(t *testing.T) {
+	if Parse(newFixture(t)) != "x" {
+		t.Fail()
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if Format("x") != "x" {
+		t.Fail()
+	}
+}
+`
+
+	tests := []struct {
+		name      string
+		testFile  string
+		split     func(string, Options) error
+		opts      Options
+		helperIn  string
+		wantFiles []string
+	}{
+		{
+			name:      "shared helper keeps the test file",
+			testFile:  shared,
+			split:     SplitPublicFunctions,
+			helperIn:  "p_test.go",
+			wantFiles: []string{"format.go", "format_test.go", "p_test.go", "parse.go", "parse_test.go"},
+		},
+		{
+			name:      "shared helper keeps the test file when splitting tests",
+			testFile:  shared,
+			split:     SplitTestFunctions,
+			helperIn:  "p_test.go",
+			wantFiles: []string{"format_test.go", "p.go", "p_test.go", "parse_test.go"},
+		},
+		{
+			name:      "exclusive helper moves with its test",
+			testFile:  exclusive,
+			split:     SplitPublicFunctions,
+			opts:      Options{MoveExclusiveHelpers: true},
+			helperIn:  "parse_test.go",
+			wantFiles: []string{"format.go", "format_test.go", "parse.go", "parse_test.go"},
+		},
+		{
+			name:      "exclusive helper moves with its test when splitting tests",
+			testFile:  exclusive,
+			split:     SplitTestFunctions,
+			opts:      Options{MoveExclusiveHelpers: true},
+			helperIn:  "parse_test.go",
+			wantFiles: []string{"format_test.go", "p.go", "parse_test.go"},
+		},
+		{
+			name:      "exclusive helper stays without the option",
+			testFile:  exclusive,
+			split:     SplitPublicFunctions,
+			helperIn:  "p_test.go",
+			wantFiles: []string{"format.go", "format_test.go", "p_test.go", "parse.go", "parse_test.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "p.go"), []byte(source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "p_test.go"), []byte(tt.testFile), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := tt.opts
+			opts.Output = io.Discard
+			opts.Verify = true
+			if err := tt.split(tmpDir, opts); err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.wantFiles, " ") {
+				t.Errorf("Expected files %v, got %v", tt.wantFiles, got)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, tt.helperIn))
+			if err != nil {
+				t.Fatalf("Expected %s to be kept: %v", tt.helperIn, err)
+			}
+			if !strings.Contains(string(content), "func newFixture(") {
+				t.Errorf("Expected newFixture in %s, got:\n%s", tt.helperIn, content)
+			}
+			// The helper keeps its place above the test, set apart by a blank line
+			if tt.helperIn != "p_test.go" && !strings.Contains(string(content), "\n}\n\nfunc TestParse(") {
+				t.Errorf("Expected newFixture above TestParse, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_AddProvenance(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"config.go": `package cfg
+
+const Version = "v1"
+
+// ParseConfig parses the config.
+func ParseConfig(s string) string {
+	return s
+}
+`,
+		"config_test.go": `package cfg
+
+import "testing"
+
+func TestParseConfig(t *testing.T) {
+	if ParseConfig("a") != "a" {
+		t.Error("unexpected result")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{AddProvenance: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expectedHeaders := map[string]string{
+		"parse_config.go":      "// Code split from config.go (declaration 2).\n\npackage cfg",
+		"common.go":            "// Code split from config.go (declaration 1).\n\npackage cfg",
+		"parse_config_test.go": "// Code split from config_test.go (declaration 1).\n\npackage cfg",
+	}
+	for name, header := range expectedHeaders {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+		if !strings.HasPrefix(string(content), header) {
+			t.Errorf("%s should start with %q, got:\n%s", name, header, content)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_MergesCommonFileWithAliasedImport(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"common.go": `package errs
+
+import stderrors "errors"
+
+var ErrA = stderrors.New("a")
+`,
+		"b.go": `package errs
+
+import "errors"
+
+var ErrB = errors.New("b")
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "common.go"))
+	if err != nil {
+		t.Fatalf("Expected common.go to exist: %v", err)
+	}
+	// Both names are referenced, so both imports of the same path are needed
+	for _, want := range []string{`stderrors "errors"`, `"errors"`, "ErrA", "ErrB"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("common.go should contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Count(string(content), `"errors"`) != 2 {
+		t.Errorf("Expected exactly two errors imports, got:\n%s", content)
+	}
+}
+
+func TestSplitPublicFunctions_SkipsVendor(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorFile := filepath.Join(tmpDir, "vendor", "example.com", "dep", "dep.go")
+	vendorContent := `package dep
+
+func Exported() {}
+
+func Another() {}
+`
+	if err := os.MkdirAll(filepath.Dir(vendorFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(vendorFile, []byte(vendorContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(vendorFile)
+	if err != nil {
+		t.Fatalf("Expected vendored file to be left in place: %v", err)
+	}
+	if string(content) != vendorContent {
+		t.Errorf("Vendored file should be untouched, got:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(vendorFile), "exported.go")); !os.IsNotExist(err) {
+		t.Error("No files should be created inside vendor")
+	}
+}
+
+func TestSplitPublicFunctions_MinFunctionsToSplit(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		minFunctions int
+		expectSplit  bool
+	}{
+		{
+			name: "two functions below threshold",
+			content: `package small
+
+func First() {}
+
+func Second() {}
+`,
+			minFunctions: 3,
+			expectSplit:  false,
+		},
+		{
+			name: "three functions at threshold",
+			content: `package small
+
+func First() {}
+
+func Second() {}
+
+func Third() {}
+`,
+			minFunctions: 3,
+			expectSplit:  true,
+		},
+		{
+			name: "declarations are still moved",
+			content: `package small
+
+const Limit = 10
+
+func First() {}
+`,
+			minFunctions: 3,
+			expectSplit:  true,
+		},
+		{
+			name: "default splits everything",
+			content: `package small
+
+func First() {}
+`,
+			minFunctions: 0,
+			expectSplit:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "small.go")
+			if err := os.WriteFile(testFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{MinFunctionsToSplit: tt.minFunctions}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			_, err := os.Stat(filepath.Join(tmpDir, "first.go"))
+			if split := err == nil; split != tt.expectSplit {
+				t.Errorf("Expected split: %v, got: %v", tt.expectSplit, split)
+			}
+			if !tt.expectSplit {
+				content, err := os.ReadFile(testFile)
+				if err != nil || string(content) != tt.content {
+					t.Errorf("small.go should be untouched, got:\n%s", content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitTestFunctions_GroupTestsByPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "handlers_test.go")
+	testContent := `package handlers
+
+import "testing"
+
+func TestUserCreate(t *testing.T) {}
+
+func TestUserUpdate(t *testing.T) {}
+
+func TestUserDelete(t *testing.T) {}
+
+func TestHealth(t *testing.T) {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitTestFunctions(tmpDir, Options{GroupTestsByPrefix: true}); err != nil {
+		t.Fatalf("SplitTestFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "user_test.go"))
+	if err != nil {
+		t.Fatalf("Expected user_test.go to be created: %v", err)
+	}
+	for _, name := range []string{"TestUserCreate", "TestUserUpdate", "TestUserDelete"} {
+		if !strings.Contains(string(content), "func "+name+"(") {
+			t.Errorf("user_test.go should contain %s", name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "health_test.go")); err != nil {
+		t.Errorf("A test without siblings should keep its own file: %v", err)
+	}
+	for _, name := range []string{"user_create_test.go", "user_update_test.go", "user_delete_test.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be created when grouping", name)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_WithStructGroupedTypeBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "models.go")
+	testContent := `package models
+
+type (
+	// User is a user.
+	User struct {
+		Name string
+	}
+
+	// Group is a group of users.
+	Group struct {
+		Users []User
+	}
+)
+
+// DisplayName returns the name to show.
+func (u User) DisplayName() string {
+	return u.Name
+}
+
+// Size returns the number of users.
+func (g Group) Size() int {
+	return len(g.Users)
+}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	tests := []struct {
+		file     string
+		expected []string
+		excluded []string
+	}{
+		{
+			file:     "user.go",
+			expected: []string{"// User is a user.", "type User struct", "func (u User) DisplayName() string"},
+			excluded: []string{"Group", "type ("},
+		},
+		{
+			file:     "group.go",
+			expected: []string{"// Group is a group of users.", "type Group struct", "func (g Group) Size() int"},
+			excluded: []string{"type User", "DisplayName", "type ("},
+		},
+	}
+
+	for _, tt := range tests {
+		content, err := os.ReadFile(filepath.Join(tmpDir, tt.file))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", tt.file, err)
+		}
+		for _, want := range tt.expected {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", tt.file, want, content)
+			}
+		}
+		for _, unwanted := range tt.excluded {
+			if strings.Contains(string(content), unwanted) {
+				t.Errorf("%s should not contain %q, got:\n%s", tt.file, unwanted, content)
+			}
+		}
+	}
+}
+
+func TestSplitPublicFunctions_TypeAliases(t *testing.T) {
+	testContent := `package ids
+
+type ID = string
+
+type Name string
+
+type (
+	Key = int
+	Code int
+)
+`
+	expectedAlias := map[string]bool{"ID": true, "Name": false, "Key": true, "Code": false}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		files []string
+	}{
+		{"separate", Options{}, []string{"common.go"}},
+		{"with-struct", Options{MethodStrategy: MethodStrategyWithStruct}, []string{"id.go", "name.go", "key.go", "code.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "types.go"), []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			gotAlias := make(map[string]bool)
+			for _, file := range tt.files {
+				content, err := os.ReadFile(filepath.Join(tmpDir, file))
+				if err != nil {
+					t.Fatalf("Expected %s to be created: %v", file, err)
+				}
+				node, err := parser.ParseFile(token.NewFileSet(), file, content, 0)
+				if err != nil {
+					t.Fatalf("%s does not parse: %v", file, err)
+				}
+				for _, decl := range node.Decls {
+					genDecl, ok := decl.(*ast.GenDecl)
+					if !ok {
+						continue
+					}
+					for _, spec := range genDecl.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							gotAlias[ts.Name.Name] = ts.Assign.IsValid()
+						}
+					}
+				}
+				if strings.Contains(string(content), "type ID = string") != (file == "common.go" || file == "id.go") {
+					t.Errorf("%s: unexpected rendering of the ID alias:\n%s", file, content)
+				}
+			}
+
+			for name, alias := range expectedAlias {
+				got, ok := gotAlias[name]
+				if !ok {
+					t.Errorf("Type %s was not written", name)
+				} else if got != alias {
+					t.Errorf("Type %s: alias = %v, want %v", name, got, alias)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_WithStructDeterministicOrder(t *testing.T) {
+	testContent := `package shapes
+
+type Square struct{}
+
+type Circle struct{}
+
+type Triangle struct{}
+
+func (s Square) Area() int { return 0 }
+
+func (c Circle) Area() int { return 0 }
+
+func (t Triangle) Area() int { return 0 }
+
+func (h Hexagon) Area() int { return 0 }
+
+func (o Octagon) Area() int { return 0 }
+`
+
+	run := func() ([]string, string) {
+		tmpDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tmpDir, "shapes.go"), []byte(testContent), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var output strings.Builder
+		if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct, Output: &output}); err != nil {
+			t.Fatalf("SplitPublicFunctions failed: %v", err)
+		}
+
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			files = append(files, entry.Name())
+		}
+
+		return files, strings.ReplaceAll(output.String(), tmpDir, "")
+	}
+
+	firstFiles, firstOutput := run()
+	for range 5 {
+		files, output := run()
+		if strings.Join(files, ",") != strings.Join(firstFiles, ",") {
+			t.Fatalf("File sets differ between runs: %v vs %v", firstFiles, files)
+		}
+		if output != firstOutput {
+			t.Fatalf("Output differs between runs:\n%s\nvs\n%s", firstOutput, output)
+		}
+	}
+
+	expectedOrder := []string{"circle.go", "square.go", "triangle.go", "hexagon_area.go", "octagon_area.go"}
+	last := -1
+	for _, name := range expectedOrder {
+		i := strings.Index(firstOutput, string(filepath.Separator)+name)
+		if i < last {
+			t.Errorf("Expected %s to be reported after the previous files:\n%s", name, firstOutput)
+		}
+		last = i
+	}
+}
+
+func TestSplitPublicFunctions_CorrespondingTestMatching(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"parser.go": `package parser
+
+func Parse(s string) string {
+	return s
+}
+
+func helper() {}
+`,
+		"parser_test.go": `package parser
+
+import "testing"
+
+func TestParse(t *testing.T) {}
+
+func TestParse_Empty(t *testing.T) {}
+
+func TestParseConfig(t *testing.T) {}
+
+func TestReparse(t *testing.T) {}
+
+func TestParsed(t *testing.T) {}
+
+func newParseFixture() string { return "" }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	moved, err := os.ReadFile(filepath.Join(tmpDir, "parse_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parse_test.go to be created: %v", err)
+	}
+	remaining, err := os.ReadFile(filepath.Join(tmpDir, "parser_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parser_test.go to be kept: %v", err)
+	}
+
+	for _, name := range []string{"TestParse(", "TestParse_Empty(", "TestParseConfig("} {
+		if !strings.Contains(string(moved), name) {
+			t.Errorf("parse_test.go should contain %s", name)
+		}
+	}
+	for _, name := range []string{"TestReparse(", "TestParsed(", "newParseFixture("} {
+		if strings.Contains(string(moved), name) {
+			t.Errorf("parse_test.go should not contain %s", name)
+		}
+		if !strings.Contains(string(remaining), name) {
+			t.Errorf("parser_test.go should still contain %s", name)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_MovesBenchmarksAndExamples(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"parser.go": `package parser
+
+func Parse(s string) string {
+	return s
+}
+
+func helper() {}
+`,
+		"parser_test.go": `package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {}
+
+func BenchmarkParse(b *testing.B) {
+	for range b.N {
+		Parse("x")
+	}
+}
+
+func ExampleParse() {
+	fmt.Println(Parse("x"))
+	// Output: x
+}
+
+func FuzzParse(f *testing.F) {}
+
+func BenchmarkParsed(b *testing.B) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	moved, err := os.ReadFile(filepath.Join(tmpDir, "parse_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parse_test.go to be created: %v", err)
+	}
+	remaining, err := os.ReadFile(filepath.Join(tmpDir, "parser_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parser_test.go to be kept: %v", err)
+	}
+
+	for _, want := range []string{"TestParse(", "BenchmarkParse(", "ExampleParse(", "// Output: x", "FuzzParse(", `"fmt"`} {
+		if !strings.Contains(string(moved), want) {
+			t.Errorf("parse_test.go should contain %s, got:\n%s", want, moved)
+		}
+		if want != `"fmt"` && strings.Contains(string(remaining), want) {
+			t.Errorf("parser_test.go should no longer contain %s", want)
+		}
+	}
+	if strings.Contains(string(moved), "BenchmarkParsed(") || !strings.Contains(string(remaining), "BenchmarkParsed(") {
+		t.Errorf("BenchmarkParsed should stay in parser_test.go, got:\n%s", remaining)
+	}
+}
+
+func TestSplitPublicFunctions_SkipCorrespondingTests(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testContent := `package parser
+
+import "testing"
+
+func TestParse(t *testing.T) {}
+
+func TestFormat(t *testing.T) {}
+`
+	files := map[string]string{
+		"parser.go": `package parser
+
+func Parse(s string) string { return s }
+
+func Format(s string) string { return s }
+`,
+		"parser_test.go": testContent,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{SkipCorrespondingTests: true, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	for _, name := range []string{"parse.go", "format.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Errorf("Expected %s to be created: %v", name, err)
+		}
+	}
+	for _, name := range []string{"parse_test.go", "format_test.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be created", name)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "parser_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parser_test.go to be kept: %v", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("parser_test.go should be left intact, got:\n%s", content)
+	}
+}
+
+func TestSplitPublicFunctions_GenericReceivers(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "containers.go")
+	testContent := `package containers
+
+// Pair holds two values.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Swap returns the pair reversed.
+func (p Pair[K, V]) Swap() Pair[K, V] { return p }
+
+// SetValue replaces the value.
+func (p *Pair[K, V]) SetValue(v V) { p.Value = v }
+
+func helper() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "pair.go"))
+	if err != nil {
+		t.Fatalf("Expected pair.go to be created: %v", err)
+	}
+	for _, want := range []string{"type Pair[K comparable, V any] struct", "func (p Pair[K, V]) Swap()", "func (p *Pair[K, V]) SetValue(v V)"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("pair.go should contain %q, got:\n%s", want, content)
+		}
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected containers.go to be kept: %v", err)
+	}
+	if strings.Contains(string(original), "Pair") {
+		t.Errorf("containers.go should no longer contain Pair or its methods, got:\n%s", original)
+	}
+}
+
+func TestSplitPublicFunctions_WithStructTypeAlias(t *testing.T) {
+	alias := `package counter
+
+type impl struct {
+	n int
+}
+
+// Counter is the public name of impl.
+type Counter = impl
+`
+	methods := `
+// Inc increments the count.
+func (c *impl) Inc() {
+	c.n++
+}
+
+// Value reports the count.
+func (c Counter) Value() int {
+	return c.n
+}
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "same file",
+			files: map[string]string{"counter_impl.go": alias + methods},
+		},
+		{
+			name: "methods in another file",
+			files: map[string]string{
+				"counter_impl.go": alias,
+				"methods.go":      "package counter\n" + methods,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := Options{MethodStrategy: MethodStrategyWithStruct, Verify: true, Output: io.Discard}
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "counter.go"))
+			if err != nil {
+				t.Fatalf("Expected counter.go to be created: %v", err)
+			}
+			for _, want := range []string{"type Counter = impl", "func (c *impl) Inc()", "func (c Counter) Value()"} {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("Expected counter.go to contain %q, got:\n%s", want, content)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "impl_inc.go")); !os.IsNotExist(err) {
+				t.Error("impl.Inc should not get a file of its own")
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_WithStructMethodsAcrossFiles(t *testing.T) {
+	typeContent := `package server
+
+import "fmt"
+
+// Server serves requests.
+type Server struct {
+	name string
+}
+
+// Start starts the server.
+func (s *Server) Start() {
+	fmt.Println("start", s.name)
+}
+`
+	methodContent := `package server
+
+import "strings"
+
+// Stop stops the server.
+func (s *Server) Stop() string {
+	return strings.ToUpper(s.name)
+}
+
+// Restart restarts the server.
+func (s *Server) Restart() {
+	s.Stop()
+	s.Start()
+}
+
+func helper() {}
+`
+
+	tests := []struct {
+		name       string
+		typeFile   string
+		methodFile string
+	}{
+		{name: "type first", typeFile: "a.go", methodFile: "b.go"},
+		{name: "methods first", typeFile: "z.go", methodFile: "b.go"},
+		{name: "type in server.go", typeFile: "server.go", methodFile: "b.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, tt.typeFile), []byte(typeContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, tt.methodFile), []byte(methodContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct, Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "server.go"))
+			if err != nil {
+				t.Fatalf("Expected server.go to be created: %v", err)
+			}
+			for _, want := range []string{
+				"type Server struct",
+				"func (s *Server) Start()",
+				"func (s *Server) Stop() string",
+				"func (s *Server) Restart()",
+				`"fmt"`,
+				`"strings"`,
+			} {
+				if strings.Count(string(content), want) != 1 {
+					t.Errorf("server.go should contain %q once, got:\n%s", want, content)
+				}
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "server.go", content, 0); err != nil {
+				t.Errorf("server.go should parse: %v", err)
+			}
+
+			for _, name := range []string{"server_stop.go", "server_restart.go"} {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err == nil {
+					t.Errorf("%s should not be created", name)
+				}
+			}
+			remaining, err := os.ReadFile(filepath.Join(tmpDir, tt.methodFile))
+			if err != nil {
+				t.Fatalf("Expected %s to keep helper: %v", tt.methodFile, err)
+			}
+			if strings.Contains(string(remaining), "Server") {
+				t.Errorf("%s should no longer contain Server methods, got:\n%s", tt.methodFile, remaining)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_MixedMethodStrategy(t *testing.T) {
+	tmpDir := t.TempDir()
+	testContent := `package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Server serves requests.
+type Server struct {
+	name string
+}
+
+// Start starts the server.
+func (s *Server) Start() {
+	s.log("start")
+}
+
+// log prints msg.
+func (s *Server) log(msg string) {
+	fmt.Println(s.name, msg)
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.log(s.upper())
+}
+
+func (s *Server) upper() string {
+	return strings.ToUpper(s.name)
+}
+
+type conn struct{}
+
+func (c conn) close() {}
+
+func helper() {}
+`
+	testFile := filepath.Join(tmpDir, "server.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	methodFile := filepath.Join(tmpDir, "more.go")
+	methodContent := `package server
+
+func (s *Server) reset() {
+	s.name = ""
+}
+`
+	if err := os.WriteFile(methodFile, []byte(methodContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyMixed, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	tests := []struct {
+		file    string
+		want    []string
+		notWant []string
+	}{
+		{file: "server_start.go", want: []string{"func (s *Server) Start()"}, notWant: []string{"log(msg", `"fmt"`}},
+		{file: "server_stop.go", want: []string{"func (s *Server) Stop()"}, notWant: []string{"upper() string"}},
+		{
+			file:    "server_private.go",
+			want:    []string{"// log prints msg.", "func (s *Server) log(msg string)", "func (s *Server) upper() string", "func (s *Server) reset()", `"fmt"`, `"strings"`},
+			notWant: []string{"Start()", "Stop()"},
+		},
+		{file: "conn_private.go", want: []string{"func (c conn) close()"}},
+		{file: "common.go", want: []string{"type Server struct"}},
+		{file: "server.go", want: []string{"type conn struct{}", "func helper()"}, notWant: []string{"func (", "import"}},
+	}
+	for _, tt := range tests {
+		content, err := os.ReadFile(filepath.Join(tmpDir, tt.file))
+		if err != nil {
+			t.Errorf("Expected %s to exist: %v", tt.file, err)
+
+			continue
+		}
+		for _, want := range tt.want {
+			if strings.Count(string(content), want) != 1 {
+				t.Errorf("%s should contain %q once, got:\n%s", tt.file, want, content)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(string(content), notWant) {
+				t.Errorf("%s should not contain %q, got:\n%s", tt.file, notWant, content)
+			}
+		}
+	}
+	for _, name := range []string{"server_log.go", "server_upper.go", "more.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err == nil {
+			t.Errorf("%s should not exist", name)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_WithStructKeepsTypeComments(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "service.go")
+	testContent := `package service
+
+//go:generate mockgen -source=service.go -destination=mock_service.go
+
+// Server serves requests.
+type Server struct {
+	Addr string
+}
+
+// Start starts the server.
+func (s *Server) Start() error { return nil }
+
+func helper() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: MethodStrategyWithStruct, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "server.go"))
+	if err != nil {
+		t.Fatalf("Expected server.go to be created: %v", err)
+	}
+	directive := strings.Index(string(content), "//go:generate mockgen")
+	doc := strings.Index(string(content), "// Server serves requests.")
+	typeDecl := strings.Index(string(content), "type Server struct")
+	if directive == -1 || doc == -1 || !(directive < doc && doc < typeDecl) {
+		t.Errorf("server.go should keep the directive and doc comment above the type, got:\n%s", content)
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected service.go to be kept: %v", err)
+	}
+	if strings.Contains(string(original), "go:generate") {
+		t.Errorf("The directive should have moved out of service.go, got:\n%s", original)
+	}
+}
+
+func TestSplitPublicFunctions_NamePatterns(t *testing.T) {
+	testContent := `package handlers
+
+func HandleLogin() {}
+
+func HandleLogout() {}
+
+func ServeHTTP() {}
+
+func Parse() {}
+`
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantSplit []string
+		wantKept  []string
+	}{
+		{
+			name:      "include",
+			opts:      Options{IncludePattern: regexp.MustCompile(`^(Handle|Serve)`)},
+			wantSplit: []string{"handle_login.go", "handle_logout.go", "serve_http.go"},
+			wantKept:  []string{"func Parse()"},
+		},
+		{
+			name:      "exclude",
+			opts:      Options{ExcludePattern: regexp.MustCompile(`^Handle`)},
+			wantSplit: []string{"serve_http.go", "parse.go"},
+			wantKept:  []string{"func HandleLogin()", "func HandleLogout()"},
+		},
+		{
+			name:      "include and exclude",
+			opts:      Options{IncludePattern: regexp.MustCompile(`^Handle`), ExcludePattern: regexp.MustCompile(`Logout$`)},
+			wantSplit: []string{"handle_login.go"},
+			wantKept:  []string{"func HandleLogout()", "func ServeHTTP()", "func Parse()"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "handlers.go")
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.opts.Output = io.Discard
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.wantSplit)+1 {
+				t.Errorf("Expected %d files, got %d", len(tt.wantSplit)+1, len(entries))
+			}
+			for _, name := range tt.wantSplit {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+					t.Errorf("Expected %s to be created: %v", name, err)
+				}
+			}
+
+			original, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Expected handlers.go to be kept: %v", err)
+			}
+			for _, want := range tt.wantKept {
+				if !strings.Contains(string(original), want) {
+					t.Errorf("handlers.go should still contain %s, got:\n%s", want, original)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_ContinueOnError(t *testing.T) {
+	files := map[string]string{
+		"a.go": `package pkg
+
+func Alpha() {}
+
+func Beta() {}
+`,
+		"broken.go": `package pkg
+
+func Broken( {
+`,
+		"c.go": `package pkg
+
+func Gamma() {}
+
+func Delta() {}
+`,
+	}
+
+	tests := []struct {
+		name            string
+		continueOnError bool
+		wantCreated     []string
+	}{
+		{
+			name:            "fail fast",
+			continueOnError: false,
+			wantCreated:     []string{"alpha.go", "beta.go"},
+		},
+		{
+			name:            "continue on error",
+			continueOnError: true,
+			wantCreated:     []string{"alpha.go", "beta.go", "gamma.go", "delta.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out strings.Builder
+			err := SplitPublicFunctions(tmpDir, Options{ContinueOnError: tt.continueOnError, Output: &out})
+			if err == nil || !strings.Contains(err.Error(), "broken.go") {
+				t.Fatalf("Expected an error naming broken.go, got %v", err)
+			}
+
+			for _, name := range tt.wantCreated {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+					t.Errorf("Expected %s to be created: %v", name, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "gamma.go")); tt.continueOnError == os.IsNotExist(err) {
+				t.Errorf("gamma.go created = %v, want %v", !os.IsNotExist(err), tt.continueOnError)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "broken.go"))
+			if err != nil || string(content) != files["broken.go"] {
+				t.Error("broken.go should be left untouched")
+			}
+			if tt.continueOnError && !strings.Contains(out.String(), "Warning: failed to process "+filepath.Join(tmpDir, "broken.go")) {
+				t.Errorf("Expected a warning for broken.go, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_KeepsDirectivesWithFunction(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "logic.go")
+	testContent := `package logic
+
+func helper() {}
+
+//nolint:gocyclo
+
+// Complex decides.
+func Complex(x int) int {
+	if x > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func Simple() {} //nolint:unused
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "complex.go"))
+	if err != nil {
+		t.Fatalf("Expected complex.go to be created: %v", err)
+	}
+	want := "//nolint:gocyclo\n\n// Complex decides.\nfunc Complex(x int) int {"
+	if !strings.Contains(string(content), want) {
+		t.Errorf("complex.go should keep the directive above the function, got:\n%s", content)
+	}
+
+	simple, err := os.ReadFile(filepath.Join(tmpDir, "simple.go"))
+	if err != nil {
+		t.Fatalf("Expected simple.go to be created: %v", err)
+	}
+	if strings.Contains(string(simple), "gocyclo") || !strings.Contains(string(simple), "func Simple() {} //nolint:unused") {
+		t.Errorf("simple.go should keep only its own trailing directive, got:\n%s", simple)
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected logic.go to be kept: %v", err)
+	}
+	if strings.Contains(string(original), "nolint") {
+		t.Errorf("logic.go should not keep the directives, got:\n%s", original)
+	}
+}
+
+func TestSplitPublicFunctions_MaxFilesPerDirectory(t *testing.T) {
+	names := []string{"Add", "Bind", "Close", "Delete", "Find", "Insert", "Join", "Keys", "Merge", "Open", "Read", "Sort", "Trim", "Write"}
+	var src strings.Builder
+	src.WriteString("package store\n\nimport \"strings\"\n")
+	for _, name := range names {
+		fmt.Fprintf(&src, "\n// %s reports its name.\nfunc %s() string {\n\treturn strings.ToLower(%q)\n}\n", name, name, name)
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "store.go"), []byte(src.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{MaxFilesPerDirectory: 3, Verify: true, Output: io.Discard}
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"a_i.go": {"Add", "Bind", "Close", "Delete", "Find", "Insert"},
+		"j_r.go": {"Join", "Keys", "Merge", "Open", "Read"},
+		"s_z.go": {"Sort", "Trim", "Write"},
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d bucket files, got %v", len(want), entries)
+	}
+	for file, funcs := range want {
+		fset := token.NewFileSet()
+		node, err := parser.ParseFile(fset, filepath.Join(tmpDir, file), nil, 0)
+		if err != nil {
+			t.Fatalf("Expected %s to parse: %v", file, err)
+		}
+		var got []string
+		for _, decl := range node.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				got = append(got, fn.Name.Name)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(funcs, " ") {
+			t.Errorf("Expected %s to hold %v, got %v", file, funcs, got)
+		}
+	}
+
+	// Splitting again leaves the bucket files alone
+	result := &SplitResult{}
+	opts.Result = result
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed on rerun: %v", err)
+	}
+	if result.changes() != 0 {
+		t.Errorf("Expected no changes on rerun, got %+v", result)
+	}
+}
+
+func TestSplitPublicFunctions_MaxFilesCountsMethodAndCommonFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	testContent := `package store
+
+// Version is the store version.
+const Version = 1
+
+// Server serves the store.
+type Server struct{}
+
+// Start starts the server.
+func (s *Server) Start() {}
+
+// Stop stops the server.
+func (s *Server) Stop() {}
+
+// Add adds.
+func Add() {}
+
+// Write writes.
+func Write() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "store.go"), []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two function files fit, but with the method files and common.go
+	// the split would write five
+	opts := Options{MaxFilesPerDirectory: 3, Verify: true, Output: io.Discard}
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	for _, name := range []string{"a_i.go", "s_z.go", "server_start.go", "server_stop.go", "common.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Errorf("Expected %s to be created: %v", name, err)
+		}
+	}
+	for _, name := range []string{"add.go", "write.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be created, functions belong in bucket files", name)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_SingleFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"a.go": `package a
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A prints.
+func A() { fmt.Println() }
+
+func helper() {}
+
+// B upper-cases.
+func B() string { return strings.ToUpper("b") }
+`,
+		"c.go": `package a
+
+import "fmt"
+
+// C prints too.
+func C() { fmt.Print() }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{SingleFile: true, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, singleFileName))
+	if err != nil {
+		t.Fatalf("Expected %s to be created: %v", singleFileName, err)
+	}
+	for _, want := range []string{"func A()", "func B()", "func C()", `"fmt"`, `"strings"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s should contain %s, got:\n%s", singleFileName, want, content)
+		}
+	}
+	if n := strings.Count(string(content), `"fmt"`); n != 1 {
+		t.Errorf("%s should import fmt once, got %d times", singleFileName, n)
+	}
+
+	for _, name := range []string{"a_func.go", "b.go", "c_func.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err == nil {
+			t.Errorf("%s should not be created in single-file mode", name)
+		}
+	}
+
+	original, err := os.ReadFile(filepath.Join(tmpDir, "a.go"))
+	if err != nil {
+		t.Fatalf("Expected a.go to be kept: %v", err)
+	}
+	if !strings.Contains(string(original), "func helper()") || strings.Contains(string(original), "func A()") {
+		t.Errorf("a.go should keep only the private helper, got:\n%s", original)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "c.go")); err == nil {
+		t.Error("c.go should be deleted once its only function moved")
+	}
+}
+
+func TestSplitPublicFunctions_SplitTypes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "settings.go")
+	testContent := `package settings
+
+import (
+	"net/http"
+	"time"
+)
+
+// Config holds the settings.
+type Config struct {
+	Timeout time.Duration
+}
+
+// Server serves requests.
+type Server struct {
+	Client *http.Client
+}
+
+const Version = "1"
+
+func helper() {}
+`
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{SplitTypes: true, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"type_config.go": {"// Config holds the settings.", "type Config struct", `"time"`},
+		"type_server.go": {"// Server serves requests.", "type Server struct", `"net/http"`},
+		"common.go":      {`const Version = "1"`},
+		"settings.go":    {"func helper()"},
+	}
+	unexpected := map[string][]string{
+		"type_config.go": {`"net/http"`, "Server"},
+		"type_server.go": {`"time"`, "Config"},
+		"common.go":      {"Config", "Server", "import"},
+		"settings.go":    {"Config", "Server", "import"},
+	}
+
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", file, err)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), file, content, 0); err != nil {
+			t.Errorf("%s does not parse: %v", file, err)
+		}
+		for _, want := range contents {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+			}
+		}
+		for _, notWant := range unexpected[file] {
+			if strings.Contains(string(content), notWant) {
+				t.Errorf("%s should not contain %q", file, notWant)
+			}
+		}
+	}
+}
+
+func TestSplitPublicFunctions_KeepsFileModes(t *testing.T) {
+	tests := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "group and world readable", mode: 0o644},
+		{name: "group readable", mode: 0o640},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "util.go")
+			testContent := `package util
+
+func Public() {}
+
+func helper() {}
+`
+			if err := os.WriteFile(testFile, []byte(testContent), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			// WriteFile applies the umask; set the mode under test exactly
+			if err := os.Chmod(testFile, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			info, err := os.Stat(testFile)
+			if err != nil {
+				t.Fatalf("Expected util.go to be kept: %v", err)
+			}
+			if info.Mode().Perm() != tt.mode {
+				t.Errorf("util.go mode = %o, want %o", info.Mode().Perm(), tt.mode)
+			}
+
+			info, err = os.Stat(filepath.Join(tmpDir, "public.go"))
+			if err != nil {
+				t.Fatalf("Expected public.go to be created: %v", err)
+			}
+			if info.Mode().Perm() != newFileMode {
+				t.Errorf("public.go mode = %o, want %o", info.Mode().Perm(), os.FileMode(newFileMode))
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_KeepsInitAndItsImports(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "public functions", opts: Options{Output: io.Discard}},
+		{name: "private functions too", opts: Options{IncludePrivate: true, Output: io.Discard}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "setup.go")
+			testContent := `package setup
+
+import "log"
+
+func init() {
+	log.SetPrefix("setup: ")
+}
+
+// Report logs a message.
+func Report(msg string) {
+	log.Println(msg)
+}
+`
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			original, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Expected setup.go to be kept: %v", err)
+			}
+			for _, want := range []string{`import "log"`, "func init() {"} {
+				if !strings.Contains(string(original), want) {
+					t.Errorf("setup.go should keep %q, got:\n%s", want, original)
+				}
+			}
+			if strings.Contains(string(original), "func Report(") {
+				t.Errorf("setup.go should no longer contain Report, got:\n%s", original)
+			}
+
+			report, err := os.ReadFile(filepath.Join(tmpDir, "report.go"))
+			if err != nil {
+				t.Fatalf("Expected report.go to be created: %v", err)
+			}
+			if !strings.Contains(string(report), `import "log"`) || strings.Contains(string(report), "init") {
+				t.Errorf("report.go should import log and not contain init, got:\n%s", report)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "init.go")); err == nil {
+				t.Error("init should never be split into its own file")
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_ImportsFromTypeAssertions(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "conn.go")
+	testContent := `package conn
+
+import (
+	"fmt"
+	"net"
+)
+
+// IsConn reports whether x is a network connection.
+func IsConn(x any) bool {
+	_, ok := x.(net.Conn)
+
+	return ok
+}
+
+// Describe formats x.
+func Describe(x any) string {
+	return fmt.Sprint(x)
+}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "is_conn.go"))
+	if err != nil {
+		t.Fatalf("Expected is_conn.go to be created: %v", err)
+	}
+	if !strings.Contains(string(content), `import "net"`) {
+		t.Errorf("is_conn.go should import net, got:\n%s", content)
+	}
+	if strings.Contains(string(content), `"fmt"`) {
+		t.Errorf("is_conn.go should not import fmt, got:\n%s", content)
+	}
+}
+
+func TestSplitPublicFunctions_ImportsFromNamedResults(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "conn.go")
+	testContent := `package conn
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Open opens a connection.
+func Open() (conn net.Conn, err error) {
+	return
+}
+
+// Dialer dials.
+type Dialer struct{}
+
+// Dial dials with a timeout.
+func (d Dialer) Dial() (timeout time.Duration, err error) {
+	return
+}
+
+func check() error {
+	return errors.New("unchecked")
+}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	// Functions are written as their text, methods are reprinted
+	for name, want := range map[string]string{
+		"open.go":        `import "net"`,
+		"dialer_dial.go": `import "time"`,
+	} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s should keep the import of its named result type, got:\n%s", name, content)
+		}
+		if strings.Contains(string(content), `"errors"`) {
+			t.Errorf("%s should not import errors, got:\n%s", name, content)
+		}
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(original), `"net"`) || strings.Contains(string(original), `"time"`) {
+		t.Errorf("conn.go should drop the imports only the moved results used, got:\n%s", original)
+	}
+}
+
+func TestSplit_KeepOriginal(t *testing.T) {
+	files := map[string]string{
+		"runner.go": `package runner
+
+import "fmt"
+
+// Runner runs jobs.
+type Runner struct {
+	name string
+}
+
+// Run runs the job.
+func (r *Runner) Run() {
+	fmt.Println(r.name)
+}
+
+// Parse parses s.
+func Parse(s string) string {
+	return s
+}
+
+func helper() {}
+`,
+		"runner_test.go": `package runner
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	if Parse("a") != "a" {
+		t.Fail()
+	}
+}
+
+func TestHelper(t *testing.T) {
+	helper()
+}
+`,
+	}
+
+	tests := []struct {
+		name    string
+		split   func(string, Options) error
+		opts    Options
+		created []string
+	}{
+		{
+			name:    "separate",
+			split:   SplitPublicFunctions,
+			opts:    Options{KeepOriginal: true, Output: io.Discard},
+			created: []string{"parse.go", "parse_test.go", "runner_run.go", "common.go"},
+		},
+		{
+			name:    "with-struct writing over the original",
+			split:   SplitPublicFunctions,
+			opts:    Options{KeepOriginal: true, MethodStrategy: MethodStrategyWithStruct, Output: io.Discard},
+			created: []string{"parse.go", "parse_test.go"},
+		},
+		{
+			name:    "tests",
+			split:   SplitTestFunctions,
+			opts:    Options{KeepOriginal: true, Output: io.Discard},
+			created: []string{"parse_test.go", "helper_test.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.split(tmpDir, tt.opts); err != nil {
+				t.Fatalf("split failed: %v", err)
+			}
+
+			for name, want := range files {
+				got, err := os.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil {
+					t.Fatalf("Expected %s to be kept: %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("%s should be unchanged, got:\n%s", name, got)
+				}
+			}
+			for _, name := range tt.created {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+					t.Errorf("Expected %s to be created: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_SortsImports(t *testing.T) {
+	tests := []struct {
+		name    string
+		imports string
+	}{
+		{
+			name: "interleaved",
+			imports: `import (
+	"github.com/acme/log"
+	"strings"
+	"example.com/text"
+	"fmt"
+)`,
+		},
+		{
+			name: "grouped the other way round",
+			imports: `import (
+	"example.com/text"
+	"github.com/acme/log"
+
+	"fmt"
+	"strings"
+)`,
+		},
+	}
+
+	want := `import (
+	"fmt"
+	"strings"
+
+	"example.com/text"
+	"github.com/acme/log"
+)`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "hello.go")
+			testContent := `package greet
+
+` + tt.imports + `
+
+// Greet greets name.
+func Greet(name string) {
+	// Loud on purpose
+	log.Print(fmt.Sprint(strings.ToUpper(text.Trim(name))))
+}
+
+// Wave waves.
+func Wave() {
+	fmt.Println("wave")
+}
+`
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "greet.go"))
+			if err != nil {
+				t.Fatalf("Expected greet.go to be created: %v", err)
+			}
+			if !strings.Contains(string(content), want) {
+				t.Errorf("greet.go should import the standard library first, got:\n%s", content)
+			}
+			if !strings.Contains(string(content), "// Loud on purpose") {
+				t.Errorf("greet.go should keep the comment in Greet, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestSplit_VerboseCommentAttribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		split    func(string, Options) error
+		filename string
+		content  string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "functions",
+			split:    SplitPublicFunctions,
+			filename: "render.go",
+			content: `package render
+
+func helper() {} // helper's own
+
+// Ambiguous note
+
+// Draw draws.
+func Draw() {} // trailing
+
+// Section: output
+
+// Print prints.
+func Print() {}
+`,
+			want: []string{
+				`Verbose: comment "// Ambiguous note" on line 5 stays, although Draw follows it: closer to the previous declaration (2 lines) than to this one (3 lines)`,
+				`Verbose: comment "// trailing" on line 8 moves with Draw: trails its closing brace`,
+				`Verbose: comment "// Section: output" on line 10 stays, although Print follows it: closer to the previous declaration (2 lines) than to this one (3 lines)`,
+			},
+			notWant: []string{"helper's own", "// Draw draws.", "// Print prints."},
+		},
+		{
+			name:     "tests",
+			split:    SplitTestFunctions,
+			filename: "render_test.go",
+			content: `package render
+
+import "testing"
+
+func TestDraw(t *testing.T) {}
+
+// Ambiguous note
+
+func TestPrint(t *testing.T) {}
+`,
+			want: []string{
+				`Verbose: comment "// Ambiguous note" on line 7 moves with TestPrint: 2 lines from the previous declaration, 2 from this one, a gap of 1 lines to the declaration (at most 1)`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, tt.filename), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			if err := tt.split(tmpDir, Options{Verbose: true, Output: &out}); err != nil {
+				t.Fatalf("split failed: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want+"\n") {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("Expected output not to mention %q, got:\n%s", notWant, out.String())
+				}
+			}
+
+			// Without Verbose, nothing is explained
+			quiet := t.TempDir()
+			if err := os.WriteFile(filepath.Join(quiet, tt.filename), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out.Reset()
+			if err := tt.split(quiet, Options{Output: &out}); err != nil {
+				t.Fatalf("split failed: %v", err)
+			}
+			if strings.Contains(out.String(), "Verbose:") {
+				t.Errorf("Expected no explanations without Verbose, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_LeaveMoveMarker(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "separate",
+			opts: Options{LeaveMoveMarker: true, Output: io.Discard},
+			want: `package shop
+
+// Moved to checkout.go
+
+func tax() int { return 8 }
+
+// Moved to cart_total.go
+
+func discount() int { return 0 }
+`,
+		},
+		{
+			name: "with-struct",
+			opts: Options{LeaveMoveMarker: true, MethodStrategy: MethodStrategyWithStruct, Output: io.Discard},
+			want: `package shop
+
+// Moved to checkout.go
+
+func tax() int { return 8 }
+
+// Moved to cart.go
+
+func discount() int { return 0 }
+`,
+		},
+		{
+			name: "without markers",
+			opts: Options{Output: io.Discard},
+			want: `package shop
+
+func tax() int { return 8 }
+
+func discount() int { return 0 }
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "shop.go")
+			testContent := `package shop
+
+// Checkout completes the order.
+func Checkout() int {
+	return tax()
+}
+
+func tax() int { return 8 }
+
+// Cart holds items.
+type Cart struct{}
+
+// Total sums the cart.
+func (c Cart) Total() int { return discount() }
+
+func discount() int { return 0 }
+`
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Expected shop.go to be kept: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("shop.go mismatch\ngot:\n%s\nwant:\n%s", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_OnlyMethodsOfTypeElsewhere(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "default", opts: Options{Output: io.Discard}},
+		{name: "min functions", opts: Options{MinFunctionsToSplit: 3, Output: io.Discard}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			typeContent := `package svc
+
+// Service does the work.
+type Service struct{}
+`
+			methodContent := `package svc
+
+import "fmt"
+
+// Do does the work.
+func (s *Service) Do() {
+	fmt.Println("do")
+}
+`
+			if err := os.WriteFile(filepath.Join(tmpDir, "service.go"), []byte(typeContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "work.go"), []byte(methodContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "service_do.go"))
+			if err != nil {
+				t.Fatalf("Expected service_do.go to be created: %v", err)
+			}
+			if !strings.Contains(string(content), "func (s *Service) Do()") || !strings.Contains(string(content), `import "fmt"`) {
+				t.Errorf("service_do.go should hold Do and its import, got:\n%s", content)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "work.go")); !os.IsNotExist(err) {
+				t.Errorf("work.go should be deleted once its only method moved, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_NoCommonFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "defs.go")
+	testContent := `package defs
+
+import (
+	"errors"
+	"time"
+)
+
+// Config holds the settings.
+type Config struct {
+	Timeout time.Duration
+}
+
+// Errors returned by the package.
+var (
+	ErrClosed, ErrTimeout = errors.New("closed"), errors.New("timeout")
+)
+
+const (
+	First = iota
+	Second
+)
+
+type (
+	// Name is a name.
+	Name string
+	Kind int
+)
+
+func helper() {}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{NoCommonFile: true, Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"config.go":     {"// Config holds the settings.", "type Config struct", `import "time"`},
+		"err_closed.go": {"// Errors returned by the package.", "ErrClosed, ErrTimeout = ", `import "errors"`},
+		"first.go":      {"First = iota\n\tSecond\n"},
+		"name.go":       {"// Name is a name.\ntype Name string"},
+		"kind.go":       {"type Kind int"},
+	}
+	for file, wants := range expected {
+		content, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", file, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+			}
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, commonFileName)); err == nil {
+		t.Errorf("%s should not be created", commonFileName)
+	}
+
+	original, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Expected defs.go to be kept: %v", err)
+	}
+	if got := strings.TrimSpace(string(original)); got != "package defs\n\nfunc helper() {}" {
+		t.Errorf("defs.go should keep only the helper, got:\n%s", original)
+	}
+}
+
+func TestSplitPublicFunctions_FiltersMovedTestComments(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"parser.go": `package parser
+
+func Parse(s string) string {
+	return s
+}
+
+func helper() {}
+`,
+		"parser_test.go": `package parser
+
+import "testing"
+
+// TestParse checks parsing.
+func TestParse(t *testing.T) {
+	// Arrange
+	in := "x"
+	_ = Parse(in)
+}
+
+// TestHelper checks the helper.
+func TestHelper(t *testing.T) {
+	// Arrange
+	helper()
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	remaining, err := os.ReadFile(filepath.Join(tmpDir, "parser_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parser_test.go to be kept: %v", err)
+	}
+	if strings.Contains(string(remaining), "TestParse checks parsing.") {
+		t.Errorf("parser_test.go should not keep the doc comment of TestParse, got:\n%s", remaining)
+	}
+	want := "// TestHelper checks the helper.\nfunc TestHelper(t *testing.T) {\n\t// Arrange\n\thelper()\n}"
+	if !strings.Contains(string(remaining), want) {
+		t.Errorf("parser_test.go should keep TestHelper with its comments, got:\n%s", remaining)
+	}
+
+	moved, err := os.ReadFile(filepath.Join(tmpDir, "parse_test.go"))
+	if err != nil {
+		t.Fatalf("Expected parse_test.go to be created: %v", err)
+	}
+	if !strings.Contains(string(moved), "// TestParse checks parsing.\nfunc TestParse(t *testing.T) {\n\t// Arrange\n") {
+		t.Errorf("parse_test.go should contain TestParse with its comments, got:\n%s", moved)
+	}
+}
+
+func TestSplitPublicFunctions_SelfNamedSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		opts     Options
+		contains []string
+	}{
+		{
+			name: "function",
+			file: "parse.go",
+			content: `package parse
+
+// Parse parses.
+func Parse() { helper() }
+
+func helper() {}
+`,
+			contains: []string{"func Parse()", "func helper()"},
+		},
+		{
+			name: "type with methods",
+			file: "runner.go",
+			content: `package runner
+
+import "fmt"
+
+// Runner runs.
+type Runner struct{}
+
+// Run runs.
+func (r *Runner) Run() { helper() }
+
+func helper() { fmt.Println() }
+`,
+			opts:     Options{MethodStrategy: MethodStrategyWithStruct},
+			contains: []string{`import "fmt"`, "type Runner struct{}", "func (r *Runner) Run()", "func helper()"},
+		},
+		{
+			name: "method",
+			file: "user_get.go",
+			content: `package user
+
+// Get gets.
+func (u User) Get() {}
+
+func helper() {}
+`,
+			contains: []string{"func (u User) Get()", "func helper()"},
+		},
+		{
+			name: "interface",
+			file: "reader.go",
+			content: `package reader
+
+// Reader reads.
+type Reader interface{ Read() }
+
+func helper() {}
+`,
+			opts:     Options{SplitInterfaces: true},
+			contains: []string{"type Reader interface", "func helper()"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, tt.file)
+			if err := os.WriteFile(testFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := tt.opts
+			opts.Output = io.Discard
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Expected %s to be kept: %v", tt.file, err)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), tt.file, content, 0); err != nil {
+				t.Errorf("%s does not parse: %v", tt.file, err)
+			}
+			for _, want := range tt.contains {
+				if strings.Count(string(content), want) != 1 {
+					t.Errorf("%s should contain %q exactly once, got:\n%s", tt.file, want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_KeepsFieldComments(t *testing.T) {
+	source := `package models
+
+import "time"
+
+// User is a user.
+type User struct {
+	// Name is the display name.
+	Name string ` + "`json:\"name\"`" + ` // never empty
+	// fields below are optional
+
+	Age time.Duration // zero when unknown
+}
+
+func helper() {}
+`
+	existing := `package models
+
+// Existing is there.
+const Existing = 1 // trailing existing
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "fresh common.go",
+			files: map[string]string{"user.go": source},
+		},
+		{
+			name:  "common.go written by an earlier file",
+			files: map[string]string{"a.go": existing, "user.go": source},
+		},
+	}
+
+	comments := []string{
+		"// User is a user.",
+		"// Name is the display name.",
+		"// never empty",
+		"// fields below are optional",
+		"// zero when unknown",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			common, err := os.ReadFile(filepath.Join(tmpDir, "common.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "common.go", common, 0); err != nil {
+				t.Fatalf("common.go does not parse: %v\n%s", err, common)
+			}
+			want := comments
+			if _, ok := tt.files["a.go"]; ok {
+				want = append(want, "// Existing is there.\nconst Existing = 1 // trailing existing")
+			}
+			for _, comment := range want {
+				if !strings.Contains(string(common), comment) {
+					t.Errorf("common.go should contain %q, got:\n%s", comment, common)
+				}
+			}
+
+			original, err := os.ReadFile(filepath.Join(tmpDir, "user.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, comment := range comments {
+				if strings.Contains(string(original), comment) {
+					t.Errorf("user.go should no longer contain %q, got:\n%s", comment, original)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_KeepsEmbedDirectives(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		opts     Options
+		file     string
+		expected []string
+	}{
+		{
+			name: "embed.FS into common.go",
+			source: `package assets
+
+import "embed"
+
+//go:embed templates/*
+var Templates embed.FS
+
+func helper() {}
+`,
+			file:     "common.go",
+			expected: []string{`import "embed"`, "//go:embed templates/*\nvar Templates embed.FS"},
+		},
+		{
+			name: "string var with a blank embed import",
+			source: `package assets
+
+import _ "embed"
+
+// Version is the release version.
+//
+//go:embed version.txt
+var Version string
+
+func helper() {}
+`,
+			opts:     Options{GroupVarsByBlock: true},
+			file:     "version.go",
+			expected: []string{`import _ "embed"`, "//go:embed version.txt\nvar Version string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "assets.go"), []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.opts.Output = io.Discard
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("%s should contain %q, got:\n%s", tt.file, want, content)
+				}
+			}
+
+			original, err := os.ReadFile(filepath.Join(tmpDir, "assets.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(original), "embed") {
+				t.Errorf("assets.go should no longer mention embed, got:\n%s", original)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_SortDeclarations(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testContent := `package shapes
+
+// Zeta goes last.
+func (s *Shape) Zeta() {}
+
+const (
+	Small Size = iota
+	Large
+)
+
+// Alpha goes first.
+func (s *Shape) Alpha() {
+	// inside alpha
+}
+
+var Default = Small
+
+// NewShape builds a shape.
+func NewShape() *Shape { return &Shape{} }
+
+// Shape is a shape.
+type Shape struct{}
+
+type Size int
+
+func (s *Shape) Middle() {} // trailing middle
+
+func helper() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "shapes.go"), []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{MethodStrategy: MethodStrategyWithStruct, SortDeclarations: true, Output: io.Discard}
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "shape.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "shape.go", content, parser.ParseComments); err != nil {
+		t.Fatalf("shape.go does not parse: %v\n%s", err, content)
+	}
+
+	// Each part must appear after the one before it
+	ordered := []string{
+		"// Shape is a shape.\ntype Shape struct{}",
+		"// NewShape builds a shape.\nfunc NewShape()",
+		"// Alpha goes first.\nfunc (s *Shape) Alpha() {\n\t// inside alpha\n}",
+		"func (s *Shape) Middle() {} // trailing middle",
+		"// Zeta goes last.\nfunc (s *Shape) Zeta() {}",
+	}
+	last := -1
+	for _, want := range ordered {
+		index := strings.Index(string(content), want)
+		if index <= last {
+			t.Fatalf("shape.go should contain %q after the previous part, got:\n%s", want, content)
+		}
+		last = index
+	}
+
+	size, err := os.ReadFile(filepath.Join(tmpDir, "size.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(size), "Small Size = iota\n\tLarge") {
+		t.Errorf("size.go should keep the iota block as is, got:\n%s", size)
+	}
+}
+
+func TestSplitPublicFunctions_NotesStayingDependencies(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "process.go")
+	testContent := `package process
+
+const limit = 3
+
+// Run runs.
+func Run(s string) string {
+	return helper(s)
+}
+
+// Count shadows limit with a local.
+func Count() int {
+	limit := 2
+
+	return limit
+}
+
+func helper(s string) string { return s[:limit] }
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := SplitPublicFunctions(tmpDir, Options{Output: &out}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	want := "Note: Run uses helper, which stays in " + testFile
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("output should contain %q, got:\n%s", want, out.String())
+	}
+	if strings.Contains(out.String(), "Note: Count") {
+		t.Errorf("Count only uses a local limit, got:\n%s", out.String())
+	}
+}
+
+func TestSplitTestFunctions_TestFileSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"user_internal_test.go": `package user
+
+import "testing"
+
+func TestCreate(t *testing.T) {}
+
+func TestDelete(t *testing.T) {}
+`,
+		"user_test.go": `package user_test
+
+import "testing"
+
+func TestLookup(t *testing.T) {}
+
+func TestList(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitTestFunctions(tmpDir, Options{TestFileSuffix: "_internal_test.go", Output: io.Discard}); err != nil {
+		t.Fatalf("SplitTestFunctions failed: %v", err)
+	}
+
+	for _, name := range []string{"create_internal_test.go", "delete_internal_test.go"} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected file %s was not created: %v", name, err)
+		}
+		if !strings.HasPrefix(string(content), "package user\n") {
+			t.Errorf("%s should keep the package clause, got:\n%s", name, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "user_internal_test.go")); !os.IsNotExist(err) {
+		t.Errorf("user_internal_test.go should be deleted once empty")
+	}
+
+	// Black-box tests don't end in the suffix and are left alone
+	content, err := os.ReadFile(filepath.Join(tmpDir, "user_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != files["user_test.go"] {
+		t.Errorf("user_test.go should be untouched, got:\n%s", content)
+	}
+}
+
+func TestSplit_KeepsTestPackagesApart(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		split    func(string, Options) error
+		expected map[string][]string
+	}{
+		{
+			name: "split tests",
+			files: map[string]string{
+				"a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) { parse() }\n",
+				"b_test.go": "package foo_test\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) { t.Log() }\n",
+			},
+			split: SplitTestFunctions,
+			expected: map[string][]string{
+				"parse_test.go":          {"package foo\n", "parse()"},
+				"parse_external_test.go": {"package foo_test\n", "t.Log()"},
+			},
+		},
+		{
+			name: "colocated tests",
+			files: map[string]string{
+				"foo.go":        "package foo\n\nfunc Parse() {}\n\nfunc helper() {}\n",
+				"foo_test.go":   "package foo_test\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) { t.Log() }\n",
+				"parse_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestParseInternals(t *testing.T) {}\n",
+			},
+			split: SplitPublicFunctions,
+			expected: map[string][]string{
+				"parse_test.go":          {"package foo\n", "TestParseInternals"},
+				"parse_external_test.go": {"package foo_test\n", "func TestParse(t *testing.T) { t.Log() }"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.split(tmpDir, Options{Output: io.Discard}); err != nil {
+				t.Fatalf("split failed: %v", err)
+			}
+
+			for file, contents := range tt.expected {
+				content, err := os.ReadFile(filepath.Join(tmpDir, file))
+				if err != nil {
+					t.Fatalf("Expected file %s was not created: %v", file, err)
+				}
+				for _, want := range contents {
+					if !strings.Contains(string(content), want) {
+						t.Errorf("%s should contain %q, got:\n%s", file, want, content)
+					}
+				}
+				if strings.Count(string(content), "package ") != 1 {
+					t.Errorf("%s should have one package clause, got:\n%s", file, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_MixedDeclarationBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "default", opts: Options{Output: io.Discard}},
+		{name: "with-struct", opts: Options{MethodStrategy: MethodStrategyWithStruct, Output: io.Discard}},
+		{name: "no common file", opts: Options{NoCommonFile: true, Output: io.Discard}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testContent := `package limits
+
+// Limits of a request.
+const (
+	// MaxSize is the largest request accepted.
+	MaxSize = 1 << 20
+	minSize = 16 // smaller requests are padded
+)
+
+var (
+	Default = "default"
+	fallback = "fallback"
+)
+
+// Level is a log level.
+type Level int
+
+// Levels, in order of severity.
+const (
+	Debug Level = iota
+	info
+	Error
+)
+
+// Check reports whether size is accepted.
+func Check(size int) bool {
+	return size >= minSize && fallback != "" && info > Debug
+}
+`
+			if err := os.WriteFile(filepath.Join(tmpDir, "limits.go"), []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, tt.opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			typeErrs, err := typeCheckDirectory(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, typeErr := range typeErrs {
+				t.Errorf("Split package doesn't type-check: %v", typeErr)
+			}
+
+			original, err := os.ReadFile(filepath.Join(tmpDir, "limits.go"))
+			if err != nil {
+				t.Fatalf("Expected limits.go to keep the private declarations: %v", err)
+			}
+			for _, want := range []string{"minSize = 16 // smaller requests are padded", `fallback = "fallback"`} {
+				if !strings.Contains(string(original), want) {
+					t.Errorf("limits.go should keep %q, got:\n%s", want, original)
+				}
+			}
+			for _, unwanted := range []string{"MaxSize", "Default", "Limits of a request", "iota"} {
+				if strings.Contains(string(original), unwanted) {
+					t.Errorf("limits.go should not keep %q, got:\n%s", unwanted, original)
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_KeepsLineEndings(t *testing.T) {
+	source := "package text\n\nimport \"strings\"\n\n// Shout upper-cases s.\nfunc Shout(s string) string {\n\treturn strings.ToUpper(trim(s))\n}\n\nfunc trim(s string) string {\n\treturn strings.TrimSpace(s)\n}\n"
+	testSource := "package text\n\nimport \"testing\"\n\nfunc TestShout(t *testing.T) {\n\thelper(t)\n}\n\nfunc helper(t *testing.T) {\n\tt.Helper()\n}\n"
+
+	tests := []struct {
+		name     string
+		ending   string
+		split    func(string, Options) error
+		filename string
+		source   string
+		want     []string
+	}{
+		{name: "crlf source", ending: "\r\n", split: SplitPublicFunctions, filename: "text.go", source: source, want: []string{"shout.go", "text.go"}},
+		{name: "lf source", ending: "\n", split: SplitPublicFunctions, filename: "text.go", source: source, want: []string{"shout.go", "text.go"}},
+		{name: "crlf tests", ending: "\r\n", split: SplitTestFunctions, filename: "text_test.go", source: testSource, want: []string{"shout_test.go", "text_test.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			content := strings.ReplaceAll(tt.source, "\n", tt.ending)
+			if err := os.WriteFile(filepath.Join(tmpDir, tt.filename), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.split(tmpDir, Options{Output: io.Discard}); err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+
+			for _, file := range tt.want {
+				content, err := os.ReadFile(filepath.Join(tmpDir, file))
+				if err != nil {
+					t.Fatalf("Expected %s to exist: %v", file, err)
+				}
+				lf := strings.ReplaceAll(string(content), "\r\n", "\n")
+				if strings.ReplaceAll(lf, "\n", tt.ending) != string(content) {
+					t.Errorf("%s should end every line in %q, got %q", file, tt.ending, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_CarriesBuildConstraints(t *testing.T) {
+	tests := []struct {
+		name    string
+		split   func(string, Options) error
+		opts    Options
+		files   map[string]string
+		want    map[string]string // file => expected start
+		deleted []string
+		wantErr error
+	}{
+		{
+			name:  "single function under a build tag",
+			split: SplitPublicFunctions,
+			files: map[string]string{
+				"server_unix.go": "//go:build unix\n\npackage server\n\n// Run starts the server.\nfunc Run() {}\n",
+			},
+			want: map[string]string{
+				"run_unix.go": "//go:build unix\n\npackage server\n\n// Run starts the server.\nfunc Run() {}",
+			},
+			deleted: []string{"server_unix.go"},
+		},
+		{
+			name:  "platform variants",
+			split: SplitPublicFunctions,
+			files: map[string]string{
+				"server_linux.go":   "package server\n\nconst Port = 80\n\nfunc Run() {}\n",
+				"server_windows.go": "//go:build windows\n\npackage server\n\nconst Port = 8080\n\nfunc Run() {}\n",
+			},
+			want: map[string]string{
+				"run_linux.go":      "package server\n\nfunc Run() {}",
+				"common_linux.go":   "package server\n\nconst Port = 80",
+				"run_windows.go":    "//go:build windows\n\npackage server\n\nfunc Run() {}",
+				"common_windows.go": "//go:build windows\n\npackage server\n\nconst Port = 8080",
+			},
+			deleted: []string{"server_linux.go", "server_windows.go"},
+		},
+		{
+			name:  "tagged tests",
+			split: SplitTestFunctions,
+			files: map[string]string{
+				"integration_test.go": "//go:build integration\n\npackage server\n\nimport \"testing\"\n\nfunc TestRun(t *testing.T) {}\n",
+			},
+			want: map[string]string{
+				"run_integration_test.go": "//go:build integration\n\npackage server\n\nimport \"testing\"\n\nfunc TestRun(t *testing.T) {}",
+			},
+			deleted: []string{"integration_test.go"},
+		},
+		{
+			name:  "tagged files sharing the common file",
+			split: SplitPublicFunctions,
+			files: map[string]string{
+				"a_unix.go": "//go:build unix\n\npackage server\n\nconst A = 1\n\nfunc Fa() {}\n",
+				"b_unix.go": "//go:build unix\n\npackage server\n\nconst B = 2\n\nfunc Fb() {}\n",
+			},
+			want: map[string]string{
+				"common_unix.go": "//go:build unix\n\npackage server\n\nconst A = 1\n\nconst B = 2",
+			},
+			deleted: []string{"a_unix.go", "b_unix.go"},
+		},
+		{
+			name:  "tagged files sharing a type file",
+			split: SplitPublicFunctions,
+			opts:  Options{MethodStrategy: MethodStrategyWithStruct},
+			files: map[string]string{
+				"a_unix.go": "//go:build unix\n\npackage server\n\n// T is a type.\ntype T struct{}\n\nfunc Fa() {}\n",
+				"b_unix.go": "//go:build unix\n\npackage server\n\n// M is a method.\nfunc (T) M() {}\n\nfunc Fb() {}\n",
+			},
+			want: map[string]string{
+				"t_unix.go": "//go:build unix\n\npackage server\n\n// T is a type.\ntype T struct{}\n\n// M is a method.\nfunc (T) M() {}",
+			},
+			deleted: []string{"a_unix.go", "b_unix.go"},
+		},
+		{
+			name:  "different tags behind the same file name",
+			split: SplitPublicFunctions,
+			files: map[string]string{
+				"a_unix.go": "//go:build linux\n\npackage server\n\nconst A = 1\n\nfunc Fa() {}\n",
+				"b_unix.go": "//go:build darwin\n\npackage server\n\nconst B = 2\n\nfunc Fb() {}\n",
+			},
+			want: map[string]string{
+				"common_unix.go": "//go:build linux\n\npackage server\n\nconst A = 1\n",
+			},
+			wantErr: ErrConstraintMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := tt.opts
+			opts.Output = io.Discard
+			if err := tt.split(tmpDir, opts); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Split error = %v, want %v", err, tt.wantErr)
+			}
+
+			for file, want := range tt.want {
+				content, err := os.ReadFile(filepath.Join(tmpDir, file))
+				if err != nil {
+					t.Errorf("Expected %s to be created: %v", file, err)
+
+					continue
+				}
+				if !strings.HasPrefix(string(content), want) {
+					t.Errorf("%s should start with\n%s\ngot:\n%s", file, want, content)
+				}
+			}
+			for _, file := range tt.deleted {
+				if _, err := os.Stat(filepath.Join(tmpDir, file)); !os.IsNotExist(err) {
+					t.Errorf("%s should be deleted once empty, got: %v", file, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_VarInitializerImports(t *testing.T) {
+	declarations := []struct {
+		name string
+		decl string
+		want string // the import the file holding decl needs
+	}{
+		{name: "package value", decl: "var DefaultClient = http.DefaultClient", want: `"net/http"`},
+		{name: "function call", decl: "var Buf = bytes.NewBuffer(nil)", want: `"bytes"`},
+		{name: "error value", decl: `var ErrClosed = errors.New("closed")`, want: `"errors"`},
+		{name: "aliased import", decl: `var Upper = stdstrings.ToUpper("a")`, want: `stdstrings "strings"`},
+		{name: "type only", decl: "var Mu sync.Mutex", want: `"sync"`},
+		{name: "composite literal", decl: "var Client = &http.Client{Timeout: time.Second}", want: `"time"`},
+		{name: "call in a closure", decl: "var Name = func() string { return fmt.Sprint(1) }()", want: `"fmt"`},
+	}
+	strategies := []struct {
+		name string
+		opts Options
+	}{
+		{name: "common file", opts: Options{Output: io.Discard}},
+		{name: "file per block", opts: Options{GroupVarsByBlock: true, Output: io.Discard}},
+		{name: "with-struct", opts: Options{MethodStrategy: MethodStrategyWithStruct, Output: io.Discard}},
+	}
+
+	for _, strategy := range strategies {
+		for _, tt := range declarations {
+			t.Run(strategy.name+"/"+tt.name, func(t *testing.T) {
+				tmpDir := t.TempDir()
+				testContent := `package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	stdstrings "strings"
+	"sync"
+	"time"
+)
+
+` + tt.decl + `
+
+// Do does nothing with the imports.
+func Do() {}
+`
+				if err := os.WriteFile(filepath.Join(tmpDir, "client.go"), []byte(testContent), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := SplitPublicFunctions(tmpDir, strategy.opts); err != nil {
+					t.Fatalf("SplitPublicFunctions failed: %v", err)
+				}
+
+				found := false
+				entries, err := os.ReadDir(tmpDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, entry := range entries {
+					content, err := os.ReadFile(filepath.Join(tmpDir, entry.Name()))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if strings.Contains(string(content), tt.decl) {
+						found = strings.Contains(string(content), tt.want)
+					}
+				}
+				if !found {
+					t.Errorf("The file holding %q should import %s", tt.decl, tt.want)
+				}
+
+				// None of the imports is left behind for Do
+				content, err := os.ReadFile(filepath.Join(tmpDir, "do.go"))
+				if err != nil {
+					t.Fatalf("Expected do.go to be created: %v", err)
+				}
+				if strings.Contains(string(content), "\nimport ") {
+					t.Errorf("do.go should not import anything, got:\n%s", content)
+				}
+			})
+		}
+	}
+}
+
+func TestSplitPublicFunctions_LicenseHeader(t *testing.T) {
+	license := "// Copyright 2024 The Authors. All rights reserved.\n// Use of this source code is governed by the MIT license."
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "license block",
+			content: license + "\n\n// Package shop sells things.\npackage shop\n",
+			want:    license + "\n\npackage shop\n",
+		},
+		{
+			name:    "license block above a build constraint",
+			content: license + "\n\n//go:build !js\n\npackage shop\n",
+			want:    license + "\n\n//go:build !js\n\npackage shop\n",
+		},
+		{
+			name:    "package doc only",
+			content: "// Package shop sells things.\npackage shop\n",
+			want:    "package shop\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testContent := tt.content + `
+import "fmt"
+
+// Currency is the currency prices are in.
+const Currency = "EUR"
+
+// Cart holds items.
+type Cart struct{ items []string }
+
+// Add adds an item.
+func (c *Cart) Add(item string) { c.items = append(c.items, item) }
+
+// Checkout prints the total.
+func Checkout(total int) { fmt.Println(total, Currency) }
+`
+			testFile := filepath.Join(tmpDir, "shop.go")
+			if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			testsContent := license + "\n\npackage shop\n\nimport \"testing\"\n\nfunc TestCheckout(t *testing.T) { Checkout(1) }\n"
+			if err := os.WriteFile(filepath.Join(tmpDir, "shop_test.go"), []byte(testsContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			result := &SplitResult{}
+			if err := SplitPublicFunctions(tmpDir, Options{LicenseHeader: true, Output: io.Discard, Result: result}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			if len(result.CreatedFiles) < 4 {
+				t.Fatalf("Expected common.go, cart_add.go, checkout.go and checkout_test.go, got %v", result.CreatedFiles)
+			}
+			for _, file := range result.CreatedFiles {
+				content, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.want
+				if strings.HasSuffix(file, "_test.go") {
+					// Tests take the license of their own file
+					want = license + "\n\n"
+				}
+				if !strings.HasPrefix(string(content), want) {
+					t.Errorf("%s should start with\n%s\ngot:\n%s", filepath.Base(file), want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_LicenseHeaderMerged(t *testing.T) {
+	license := "// Copyright 2024 The Authors. All rights reserved."
+	files := map[string]string{
+		"a.go": license + "\n\npackage shop\n\n// Currency is the currency prices are in.\nconst Currency = \"EUR\"\n\n// Cart holds items.\ntype Cart struct{}\n\n// Checkout checks out.\nfunc Checkout() {}\n",
+		"b.go": license + "\n\npackage shop\n\n// Rate is the tax rate.\nconst Rate = 20\n\n// Add adds an item.\nfunc (c *Cart) Add() {}\n\n// Refund refunds.\nfunc Refund() {}\n",
+	}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		files []string // files both sources were merged into
+	}{
+		{name: "common file", opts: Options{}, files: []string{"common.go"}},
+		{name: "type file", opts: Options{MethodStrategy: MethodStrategyWithStruct}, files: []string{"common.go", "cart.go"}},
+		{name: "single file", opts: Options{SingleFile: true}, files: []string{"common.go", "public.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := tt.opts
+			opts.LicenseHeader = true
+			opts.Output = io.Discard
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			for _, name := range tt.files {
+				content, err := os.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(content), license+"\n\npackage shop\n") {
+					t.Errorf("%s should keep the license once merged, got:\n%s", name, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_DocFile(t *testing.T) {
+	source := `// Package shop sells things.
+//
+// Carts are checked out in EUR.
+package shop
+
+import "fmt"
+
+// Checkout prints the total.
+func Checkout(total int) { fmt.Println(total, Currency) }
+
+// Currency is the currency prices are in.
+const Currency = "EUR"
+
+// Cart holds items.
+type Cart struct{ items []string }
+
+// Add adds an item.
+func (c *Cart) Add(item string) { c.items = append(c.items, item) }
+`
+	wantDoc := `// Package shop sells things.
+//
+// Carts are checked out in EUR.
+package shop
+
+// Declarations of shop.go before splitting, in their original order:
+//
+//   - Checkout
+//   - Currency
+//   - Cart
+//   - Cart.Add
+`
+
+	tests := []struct {
+		name         string
+		docFile      bool
+		existingDoc  string
+		wantDoc      string
+		wantOriginal bool
+	}{
+		{
+			name:    "package doc moves to doc.go",
+			docFile: true,
+			wantDoc: wantDoc,
+		},
+		{
+			name:         "existing doc.go is left alone",
+			docFile:      true,
+			existingDoc:  "// Package shop is documented here.\npackage shop\n",
+			wantDoc:      "// Package shop is documented here.\npackage shop\n",
+			wantOriginal: true,
+		},
+		{
+			name:         "package doc stays without the option",
+			wantOriginal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "shop.go"), []byte(source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			docPath := filepath.Join(tmpDir, "doc.go")
+			if tt.existingDoc != "" {
+				if err := os.WriteFile(docPath, []byte(tt.existingDoc), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{DocFile: tt.docFile, Output: io.Discard, Verify: true}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(docPath)
+			switch {
+			case tt.wantDoc == "" && !os.IsNotExist(err):
+				t.Errorf("Expected no doc.go, got %v:\n%s", err, content)
+			case tt.wantDoc != "" && string(content) != tt.wantDoc:
+				t.Errorf("Expected doc.go:\n%s\ngot (%v):\n%s", tt.wantDoc, err, content)
+			}
+
+			original, err := os.ReadFile(filepath.Join(tmpDir, "shop.go"))
+			if !tt.wantOriginal {
+				if !os.IsNotExist(err) {
+					t.Errorf("Expected shop.go to be deleted, got:\n%s", original)
+				}
+
+				return
+			}
+			if !strings.HasPrefix(string(original), "// Package shop sells things.") {
+				t.Errorf("Expected shop.go to keep the package doc, got (%v):\n%s", err, original)
+			}
+		})
+	}
+}
+
+func TestSplit_NameFunc(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		split     func(string, Options) error
+		wantFiles []string
+	}{
+		{
+			name: "functions, methods and their tests",
+			files: map[string]string{
+				"api.go": `package api
+
+// Config configures the client.
+type Config struct{ Base string }
+
+// Load loads the config.
+func (c *Config) Load() {}
+
+// GetURL returns the URL of path.
+func GetURL(path string) string { return path }
+`,
+				"api_test.go": `package api
+
+import "testing"
+
+func TestGetURL(t *testing.T) { GetURL("/") }
+`,
+			},
+			split:     SplitPublicFunctions,
+			wantFiles: []string{"common.go", "config_load.go", "geturl.go", "geturl_test.go"},
+		},
+		{
+			name: "tests",
+			files: map[string]string{
+				"api_test.go": `package api
+
+import "testing"
+
+func TestGetURL(t *testing.T) {}
+
+func TestParseJSON(t *testing.T) {}
+`,
+			},
+			split:     SplitTestFunctions,
+			wantFiles: []string{"geturl_test.go", "parsejson_test.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.split(tmpDir, Options{NameFunc: strings.ToLower, Output: io.Discard}); err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.wantFiles, " ") {
+				t.Errorf("Expected files %v, got %v", tt.wantFiles, got)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_MultiplePackages(t *testing.T) {
+	files := map[string]string{
+		"lib.go": `package lib
+
+// Version is the library version.
+const Version = "1"
+
+// Parse returns the version.
+func Parse() string { return Version }
+`,
+		"serve.go": `package main
+
+// Mode is the mode the tool serves in.
+const Mode = "x"
+
+// Serve serves.
+func Serve() {}
+`,
+	}
+
+	for _, strategy := range []MethodStrategy{MethodStrategySeparate, MethodStrategyWithStruct} {
+		t.Run(string(strategy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := Options{MethodStrategy: strategy, Output: io.Discard}
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			for name, want := range map[string]string{
+				"common.go":      "package lib\n\n// Version is the library version.\nconst Version",
+				"common_main.go": "package main\n\n// Mode is the mode the tool serves in.\nconst Mode",
+			} {
+				content, err := os.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil {
+					t.Fatalf("Expected %s to be created: %v", name, err)
+				}
+				if !strings.HasPrefix(string(content), want) {
+					t.Errorf("%s should start with\n%s\ngot:\n%s", name, want, content)
+				}
+			}
+
+			// Each package finds its own common file again
+			result := &SplitResult{}
+			opts.Result = result
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed on the second run: %v", err)
+			}
+			if result.Created+result.Updated+result.Deleted != 0 {
+				t.Errorf("Expected the second run to change nothing, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_NumberPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	testContent := `package codec
+
+import "strings"
+
+// Parse parses s.
+func Parse(s string) string { return strings.TrimSpace(s) }
+
+// Version is the codec version.
+const Version = "1"
+
+func trim(s string) string { return s }
+
+// Serialize serializes s.
+func Serialize(s string) string { return trim(s) + Version }
+
+// Close closes the codec.
+func Close() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "codec.go"), []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{NumberPrefix: true, Output: io.Discard, Verify: true}
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	// Prefixes follow the position among the declarations, imports aside
+	for name, want := range map[string]string{
+		"01_parse.go":     "func Parse(",
+		"04_serialize.go": "func Serialize(",
+		"05_close.go":     "func Close(",
+	} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected %s in %s, got:\n%s", want, name, content)
+		}
+	}
+
+	// Splitting again keeps the numbers
+	result := &SplitResult{}
+	opts.Result = result
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed on the second run: %v", err)
+	}
+	if result.Created+result.Updated+result.Deleted != 0 {
+		t.Errorf("Expected the second run to change nothing, got %+v", result)
+	}
+}
+
+func TestSplitPublicFunctions_VarBlockTrailingComment(t *testing.T) {
+	testContent := `package settings
+
+var (
+	Limit = 10
+	// Timeout is still being tuned.
+) // end of settings
+// Run runs.
+func Run() int {
+	return Limit
+}
+`
+
+	for _, strategy := range []MethodStrategy{MethodStrategySeparate, MethodStrategyWithStruct} {
+		t.Run(string(strategy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "settings.go"), []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: strategy, Output: io.Discard}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			common, err := os.ReadFile(filepath.Join(tmpDir, "common.go"))
+			if err != nil {
+				t.Fatalf("Expected common.go to be created: %v", err)
+			}
+			if want := "\t// Timeout is still being tuned.\n) // end of settings\n"; !strings.Contains(string(common), want) {
+				t.Errorf("Expected the var block to keep its comments %q, got:\n%s", want, common)
+			}
+
+			run, err := os.ReadFile(filepath.Join(tmpDir, "run.go"))
+			if err != nil {
+				t.Fatalf("Expected run.go to be created: %v", err)
+			}
+			if strings.Contains(string(run), "Timeout") || strings.Contains(string(run), "end of settings") {
+				t.Errorf("Comments of the var block should not move with Run, got:\n%s", run)
+			}
+			if !strings.Contains(string(run), "// Run runs.\nfunc Run") {
+				t.Errorf("Expected run.go to keep the doc comment, got:\n%s", run)
+			}
+		})
+	}
+}
+
+func TestSplit_KeepsDocCommentsVerbatim(t *testing.T) {
+	// Doc comments in gofmt's canonical form, with code blocks, lists and
+	// deeper indentation, must come out byte for byte
+	source := `package lib
+
+import "strings"
+
+// Limits are the parser limits.
+//
+//	if depth > MaxDepth {
+//		fail()
+//	}
+var (
+	// MaxDepth is the deepest nesting.
+	//
+	//	  indented   more
+	MaxDepth = 10
+)
+
+// Config holds settings.
+//
+//	c := Config{Name: "x"}
+type Config struct{ Name string }
+
+// Load loads c.
+//
+// Steps:
+//
+//   - read the file
+//   - decode it
+//
+// Then it is ready.
+func (c *Config) Load() {}
+
+// Parse parses s.
+//
+// Example:
+//
+//	v := Parse("a b")
+//	for _, f := range v {
+//		fmt.Println(f)
+//	}
+//
+// Rules:
+//  1. spaces split
+//  2. tabs split too
+func Parse(s string) []string {
+	// Fields splits on any space:
+	//
+	//	"a\tb" → ["a", "b"]
+	return strings.Fields(s)
+}
+
+/*
+Format formats.
+
+	Format(x)
+		nested
+*/
+func Format() {}
+`
+	testSource := `package lib
+
+import "testing"
+
+// TestParse checks Parse.
+//
+//	go test -run TestParse
+//	  -v
+func TestParse(t *testing.T) {}
+`
+	formatted, err := format.Source([]byte(source))
+	if err != nil || string(formatted) != source {
+		t.Fatalf("The source must be gofmt-clean: %v\n%s", err, formatted)
+	}
+	comments := regexp.MustCompile(`(?m)(?:^[ \t]*//.*\n)+|/\*[\s\S]*?\*/\n`).FindAllString(source+testSource, -1)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "separate"},
+		{name: "with-struct", opts: Options{MethodStrategy: MethodStrategyWithStruct}},
+		{name: "mixed", opts: Options{MethodStrategy: MethodStrategyMixed}},
+		{name: "single file", opts: Options{SingleFile: true}},
+		{name: "split types", opts: Options{SplitTypes: true}},
+		{name: "vars by block", opts: Options{GroupVarsByBlock: true}},
+		{name: "provenance", opts: Options{AddProvenance: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "lib.go"), []byte(source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "lib_test.go"), []byte(testSource), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := tt.opts
+			opts.Output = io.Discard
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var all strings.Builder
+			for _, entry := range entries {
+				content, err := os.ReadFile(filepath.Join(tmpDir, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				all.Write(content)
+			}
+			for _, comment := range comments {
+				if strings.Count(all.String(), comment) != 1 {
+					t.Errorf("Expected the comment verbatim, once:\n%s\ngot:\n%s", comment, all.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_RangeOverFunc(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"seq.go": `package seq
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Registry holds names.
+type Registry struct{ names map[string]bool }
+
+// Keys yields the names of r.
+func (r Registry) Keys() iter.Seq[string] { return maps.Keys(r.names) }
+
+// Filter yields the values of seq that keep accepts.
+func Filter[V any](seq iter.Seq[V], keep func(V) bool) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for v := range seq {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Upper yields the sorted keys of m, upper-cased.
+func Upper(m map[string]int) iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i, k := range slices.Sorted(maps.Keys(m)) {
+			if !yield(i, strings.ToUpper(k)) {
+				return
+			}
+		}
+	}
+}
+
+// Repeat returns s n times.
+func Repeat(s string, n int) []string {
+	var out []string
+	for range n {
+		out = append(out, s)
+	}
+
+	return out
+}
+
+// Names collects the names of a registry held in a parameter named like
+// package maps.
+func Names(maps Registry) []string {
+	return slices.Sorted(maps.Keys())
+}
+`,
+		"seq_test.go": `package seq
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	even := Filter(slices.Values([]int{1, 2, 3, 4}), func(v int) bool { return v%2 == 0 })
+	if got := slices.Collect(even); len(got) != 2 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestNames(t *testing.T) {
+	maps := Registry{names: map[string]bool{"a": true}}
+	for name := range maps.Keys() {
+		if name != "a" {
+			t.Errorf("got %s", name)
+		}
+	}
+}
+
+func TestUpper(t *testing.T) {
+	m := maps.Collect(func(yield func(string, int) bool) { yield("a", 1) })
+	for _, k := range Upper(m) {
+		if k != "A" {
+			t.Errorf("got %s", k)
+		}
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitPublicFunctions(tmpDir, Options{Output: io.Discard, Verify: true}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	for name, want := range map[string][]string{
+		"filter.go":      {`"iter"`},
+		"upper.go":       {`"iter"`, `"maps"`, `"slices"`, `"strings"`},
+		"repeat.go":      nil,
+		"names.go":       {`"slices"`},
+		"filter_test.go": {`"slices"`, `"testing"`},
+		"names_test.go":  {`"testing"`},
+		"upper_test.go":  {`"maps"`, `"testing"`},
+	} {
+		node, err := parser.ParseFile(token.NewFileSet(), filepath.Join(tmpDir, name), nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+		var got []string
+		for _, imp := range node.Imports {
+			got = append(got, imp.Path.Value)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("Expected %s to import %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_ConsolidateTestHelpers(t *testing.T) {
+	files := map[string]string{
+		"client.go": `package client
+
+// Get fetches a value.
+func Get(key string) string {
+	return key
+}
+
+// Put stores a value.
+func Put(key, value string) {}
+`,
+		"client_test.go": `package client
+
+import (
+	"strings"
+	"testing"
+)
+
+// fixtureKey is shared by the tests.
+const fixtureKey = "key"
+
+// setupClient prepares the tests.
+func setupClient(t *testing.T) string {
+	t.Helper()
+
+	return strings.ToUpper(fixtureKey)
+}
+
+func TestGet(t *testing.T) {
+	if Get(setupClient(t)) == "" {
+		t.Fatal("empty")
+	}
+}
+
+func TestPut(t *testing.T) {
+	Put(setupClient(t), "value")
+}
+`,
+	}
+
+	tmpDir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := Options{ConsolidateTestHelpers: true, Verify: true, Output: io.Discard}
+	if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+		t.Fatalf("SplitPublicFunctions failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "client_test.go")); !os.IsNotExist(err) {
+		t.Errorf("client_test.go should be deleted, got %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, "testhelpers_test.go"))
+	if err != nil {
+		t.Fatalf("Expected testhelpers_test.go to be created: %v", err)
+	}
+	for _, want := range []string{"// fixtureKey is shared by the tests.\nconst fixtureKey", "// setupClient prepares the tests.\nfunc setupClient(", `"strings"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected testhelpers_test.go to contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "func Test") {
+		t.Errorf("testhelpers_test.go should hold no tests, got:\n%s", content)
+	}
+	for _, file := range []string{"get_test.go", "put_test.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, file)); err != nil {
+			t.Errorf("Expected %s to be created: %v", file, err)
+		}
+	}
+}
+
+func TestSplitPublicFunctions_ScaffoldMissingTests(t *testing.T) {
+	files := map[string]string{
+		"lib.go": `package lib
+
+// Parse parses s.
+func Parse(s string) string { return s }
+
+// Format formats s.
+func Format(s string) string { return s }
+
+// Close closes nothing.
+func Close() {}
+
+func helper() {}
+`,
+		"lib_test.go": `package lib
+
+import "testing"
+
+func TestParse(t *testing.T) { Parse("") }
+`,
+		"example_test.go": `package lib_test
+
+import "example.com/lib"
+
+func ExampleClose() { lib.Close() }
+`,
+	}
+
+	tests := []struct {
+		name     string
+		scaffold bool
+		wantStub bool
+	}{
+		{name: "untested function gets a stub", scaffold: true, wantStub: true},
+		{name: "no stubs without the option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := Options{IncludePrivate: true, ScaffoldMissingTests: tt.scaffold, Output: io.Discard, Verify: true}
+			if err := SplitPublicFunctions(tmpDir, opts); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "format_test.go"))
+			if !tt.wantStub {
+				if err == nil {
+					t.Errorf("Expected no format_test.go, got:\n%s", content)
+				}
+
+				return
+			}
+			want := "package lib\n\nimport \"testing\"\n\nfunc TestFormat(t *testing.T) {\n\tt.Skip(\"TODO\")\n}\n"
+			if string(content) != want {
+				t.Errorf("Expected format_test.go:\n%s\ngot (%v):\n%s", want, err, content)
+			}
+
+			// Functions with a test anywhere in the package, and private ones,
+			// get no stub
+			parseTest, err := os.ReadFile(filepath.Join(tmpDir, "parse_test.go"))
+			if err != nil || strings.Contains(string(parseTest), "t.Skip") {
+				t.Errorf("Expected parse_test.go to hold only TestParse, got (%v):\n%s", err, parseTest)
+			}
+			for _, name := range []string{"close_test.go", "helper_test.go"} {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); err == nil {
+					t.Errorf("Expected no %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_BlankIdentifiers(t *testing.T) {
+	testContent := `package srv
+
+// Server serves.
+type Server struct{}
+
+// Ping ignores its receiver and some parameters.
+func (_ *Server) Ping(_ int, _, b string) {}
+
+// Pong names nothing.
+func (*Server) Pong(int, string) {}
+
+// Do has blank parameters and results.
+func Do(_ int, _ func(_ string) error) (_ bool, err error) { return }
+
+// Map has a blank type parameter.
+func Map[_ any, V any](v V) V { return v }
+`
+	signatures := []string{
+		"func (_ *Server) Ping(_ int, _, b string) {}",
+		"func (*Server) Pong(int, string) {}",
+		"func Do(_ int, _ func(_ string) error) (_ bool, err error) { return }",
+		"func Map[_ any, V any](v V) V { return v }",
+	}
+
+	for _, strategy := range []MethodStrategy{MethodStrategySeparate, MethodStrategyWithStruct, MethodStrategyMixed} {
+		t.Run(string(strategy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "srv.go"), []byte(testContent), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := SplitPublicFunctions(tmpDir, Options{MethodStrategy: strategy, Output: io.Discard, Verify: true}); err != nil {
+				t.Fatalf("SplitPublicFunctions failed: %v", err)
+			}
+
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var all strings.Builder
+			for _, entry := range entries {
+				content, err := os.ReadFile(filepath.Join(tmpDir, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				all.Write(content)
+			}
+			for _, signature := range signatures {
+				if !strings.Contains(all.String(), signature) {
+					t.Errorf("Expected %q to be kept, got:\n%s", signature, all.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_MissingAndEmptyDirectory(t *testing.T) {
+	splits := []struct {
+		name  string
+		split func(string, Options) error
+		want  string
+	}{
+		{"SplitPublicFunctions", SplitPublicFunctions, "No Go files found in"},
+		{"SplitTestFunctions", SplitTestFunctions, "No test files found in"},
+		{"SplitAll", SplitAll, "No Go files found in"},
+	}
+
+	for _, tt := range splits {
+		t.Run(tt.name+" missing", func(t *testing.T) {
+			missing := filepath.Join(t.TempDir(), "missing")
+			err := tt.split(missing, Options{Output: io.Discard})
+			if !errors.Is(err, ErrDirectoryNotFound) {
+				t.Fatalf("Expected ErrDirectoryNotFound, got %v", err)
+			}
+			if !strings.Contains(err.Error(), missing) {
+				t.Errorf("Expected the error to name %s, got %v", missing, err)
+			}
+		})
+
+		t.Run(tt.name+" empty", func(t *testing.T) {
+			tmpDir := t.TempDir()
+			var out bytes.Buffer
+			if err := tt.split(tmpDir, Options{Output: &out}); err != nil {
+				t.Fatalf("Expected an empty directory to succeed, got %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want+" "+tmpDir) {
+				t.Errorf("Expected %q, got:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestSplitAll(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"text.go": `package text
+
+import "strings"
+
+// Parse splits s into fields.
+func Parse(s string) []string {
+	return strings.Fields(s)
+}
+
+// Format joins fields.
+func Format(fields []string) string {
+	return strings.Join(fields, " ")
+}
+`,
+		"text_test.go": `package text
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	if len(Parse("a b")) != 2 {
+		t.Fail()
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	if len(Parse("")) != 0 {
+		t.Fail()
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if Format([]string{"a"}) != "a" {
+		t.Fail()
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	if Format(Parse("a b")) != "a b" {
+		t.Fail()
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SplitAll(tmpDir, Options{Output: io.Discard, Verify: true}); err != nil {
+		t.Fatalf("SplitAll failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"parse.go":           nil,
+		"format.go":          nil,
+		"parse_test.go":      {"TestParse", "TestParse_Empty"},
+		"format_test.go":     {"TestFormat"},
+		"round_trip_test.go": {"TestRoundTrip"},
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		var got []string
+		for _, entry := range entries {
+			got = append(got, entry.Name())
+		}
+		t.Fatalf("Expected %d files, got %v", len(want), got)
+	}
+
+	// Every test is in exactly one file
+	for name, tests := range want {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+		for _, test := range tests {
+			if !strings.Contains(string(content), "func "+test+"(") {
+				t.Errorf("Expected %s in %s, got:\n%s", test, name, content)
+			}
+		}
+		if strings.Count(string(content), "func Test") != len(tests) {
+			t.Errorf("Expected %d tests in %s, got:\n%s", len(tests), name, content)
+		}
+	}
+}
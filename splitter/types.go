@@ -3,32 +3,269 @@ package splitter
 import (
 	"errors"
 	"go/ast"
+	"io"
+	"regexp"
 )
 
 var ErrTypeCast = errors.New("failed to cast to GenDecl")
 
+// ErrTestFileTaken is returned when every file tests could be written to
+// belongs to another package.
+var ErrTestFileTaken = errors.New("test file belongs to another package")
+
+// ErrCommonFileTaken is returned when every file public declarations could
+// be gathered in belongs to another package.
+var ErrCommonFileTaken = errors.New("common file belongs to another package")
+
+// ErrConstraintMismatch is returned when split code would be added to a file
+// built under other build constraints, like code for linux to a file named
+// _unix.go that holds code for darwin.
+var ErrConstraintMismatch = errors.New("build constraints differ")
+
+// ErrImportConflict is returned when merged files import different packages
+// under the same name, like math/rand and crypto/rand.
+var ErrImportConflict = errors.New("different packages imported under the same name")
+
+// ErrIotaBlockRedeclared is returned when split declarations would replace
+// only some of the constants of an iota block in an existing file, which
+// would change the values of the others.
+var ErrIotaBlockRedeclared = errors.New("part of an iota const block would be redeclared")
+
+// ErrDirectoryNotFound is returned when the directory to split doesn't exist.
+var ErrDirectoryNotFound = errors.New("directory does not exist")
+
+// commonFileName is the file public const/var/type declarations are gathered in.
+const commonFileName = "common.go"
+
+// singleFileName is the file all functions are gathered in under SingleFile.
+const singleFileName = "public.go"
+
+// testHelpersBaseName names the test file ConsolidateTestHelpers gathers
+// helpers in, before its test file suffix.
+const testHelpersBaseName = "testhelpers"
+
+// docFileName is the file the package doc is moved to under DocFile.
+const docFileName = "doc.go"
+
+// newFileMode is the permission generated files are created with.
+const newFileMode = 0o644
+
 type MethodStrategy string
 
 const (
 	MethodStrategySeparate   MethodStrategy = "separate"
 	MethodStrategyWithStruct MethodStrategy = "with-struct"
+	// MethodStrategyMixed writes exported methods to files of their own, like
+	// MethodStrategySeparate, and gathers the unexported methods of each type
+	// in <type>_private.go.
+	MethodStrategyMixed MethodStrategy = "mixed"
 )
 
+// Options configures how files are split.
+type Options struct {
+	MethodStrategy MethodStrategy
+	// Abbreviations are additional words (e.g. "ACL", "SKU") that are kept
+	// together during snake_case conversion, merged with the built-in list.
+	Abbreviations []string
+	// NumberPrefix starts the name of each function file with the function's
+	// position among the declarations of its source file, zero-padded, so
+	// the files list in source order: 01_parse.go, 02_serialize.go. Files
+	// already numbered keep their number when split again.
+	NumberPrefix bool
+	// NameFunc, when set, derives the file name (without extension) for a
+	// function, type or test name instead of the snake_case conversion, e.g.
+	// strings.ToLower for "getURL" → "geturl.go". Tests are named after what
+	// follows "Test", and method files join the names of the receiver type
+	// and the method with an underscore.
+	NameFunc func(name string) string
+	// Formatter, when set, is run over each file the split creates or
+	// updates, after all of them are written, so tools like gofumpt or
+	// goimports can have the last word. It is given the gofmt'ed source and
+	// returns the source to write instead; CommandFormatter wraps a command.
+	// By default files are left as gofmt writes them.
+	Formatter func(src []byte) ([]byte, error)
+	// MaxDepth limits how many directory levels below the root are walked
+	// (0 = only the root). Nil means no limit.
+	MaxDepth *int
+	// IncludeGlob, when set, restricts the walk to the Go files whose base
+	// name matches it as a filepath.Match pattern, e.g. "*_handler.go".
+	IncludeGlob string
+	// ExcludeGlob, when set, leaves the Go files whose base name matches it
+	// as a filepath.Match pattern alone.
+	ExcludeGlob string
+	// MinimalDiff updates the files functions and declarations are split
+	// from by cutting the moved code out of their source, leaving everything
+	// else byte for byte as it was, instead of reformatting the whole file.
+	// Files where moved code shares a line with code that stays are
+	// reformatted as usual, with a warning.
+	MinimalDiff bool
+	// ProcessGenerated splits generated files as well. By default files with
+	// a "// Code generated ... DO NOT EDIT." line before their package clause
+	// are left alone, since the generator would overwrite the split.
+	ProcessGenerated bool
+	// GroupVarsByBlock writes each public var/const block to its own file,
+	// named after the first public name in the block, instead of common.go.
+	GroupVarsByBlock bool
+	// KeepLineDirectives skips files containing //line directives, since
+	// reformatting them would invalidate the recorded line mapping.
+	KeepLineDirectives bool
+	// SplitInterfaces writes each public interface type to its own file
+	// instead of common.go.
+	SplitInterfaces bool
+	// SplitTypes writes each public type to its own file (type_config.go for
+	// Config) instead of common.go under the separate method strategy.
+	SplitTypes bool
+	// NoCommonFile writes every public declaration to a file of its own
+	// instead of common.go: each type to <name>.go and each const/var block
+	// to a file named after its first public name, so var (A, B = f()) goes
+	// to a.go. Blocks stay whole since their specs may depend on each other
+	// through iota.
+	NoCommonFile bool
+	// GroupByFirstParam writes functions whose first parameter has a local
+	// type (e.g. *Request) into a file named after that type (request.go).
+	GroupByFirstParam bool
+	// GroupByType writes each public type together with its constructors and
+	// methods to <type>.go, the way MethodStrategyWithStruct does, whatever
+	// the method strategy.
+	GroupByType bool
+	// IncludePrivate splits unexported top-level functions into their own
+	// files as well.
+	IncludePrivate bool
+	// MoveExclusiveHelpers moves a private function referenced by exactly one
+	// extracted public function, and nothing else in the package, into that
+	// function's file. Test helpers move along with the one test using them
+	// the same way.
+	MoveExclusiveHelpers bool
+	// AddProvenance starts every generated file with a comment naming the
+	// file it was split from and the original position of its declaration.
+	AddProvenance bool
+	// IncludeVendor walks into vendor directories, which are skipped by
+	// default along with testdata and hidden directories.
+	IncludeVendor bool
+	// MinFunctionsToSplit leaves files with fewer extractable functions, and
+	// no declarations or methods to move, untouched. Values up to 1 split
+	// every file.
+	MinFunctionsToSplit int
+	// GroupTestsByPrefix writes tests sharing the first snake_case segment of
+	// their name (TestUserCreate, TestUserDelete) into one file (user_test.go).
+	GroupTestsByPrefix bool
+	// SkipCorrespondingTests leaves the tests of split functions in the
+	// existing _test.go file instead of moving them to <name>_test.go.
+	SkipCorrespondingTests bool
+	// ConsolidateTestHelpers moves what is left of the test file of a split
+	// file once its tests have moved out, like a setupClient helper shared by
+	// several tests, to testhelpers_test.go, so the test file goes away.
+	// Test files still holding tests or restricted to some platforms are
+	// left alone.
+	ConsolidateTestHelpers bool
+	// ScaffoldMissingTests writes a skipped Test<Name> stub to <name>_test.go
+	// for each split public function no test file of the package has a test
+	// of yet.
+	ScaffoldMissingTests bool
+	// TestFileSuffix ends the names of generated test files instead of
+	// _test.go, e.g. "_internal_test.go" to keep white-box tests apart from
+	// black-box ones. SplitTestFunctions then splits only the test files
+	// ending in it, and the tests of split functions are looked up under it.
+	// The package clause of the tests is kept either way.
+	TestFileSuffix string
+	// SingleFile writes all extracted functions of a package into one
+	// public.go instead of a file per function. Methods and declarations are
+	// handled as usual.
+	SingleFile bool
+	// MaxFilesPerDirectory, when above 0, keeps splitting from flooding a
+	// directory. When a file per function would leave more Go files than
+	// that, functions are gathered in at most MaxFilesPerDirectory files by
+	// the first letter of their name instead, one per range of the alphabet
+	// (a_i.go, j_r.go, s_z.go for 3). The method, type and common files the
+	// split writes count towards the limit, but are handled as usual.
+	MaxFilesPerDirectory int
+	// SortDeclarations orders the contents of generated files holding several
+	// declarations (common.go, with-struct type files, grouped test files):
+	// types, consts, vars, functions, then methods, with types, functions and
+	// methods sorted by name. Const and var blocks keep their order and are
+	// never reordered inside, so iota values stay the same.
+	SortDeclarations bool
+	// IncludePattern, when set, restricts splitting to the functions whose
+	// name it matches. Other functions stay in the original file.
+	IncludePattern *regexp.Regexp
+	// ExcludePattern, when set, keeps the functions whose name it matches in
+	// the original file.
+	ExcludePattern *regexp.Regexp
+	// LicenseHeader starts every generated file with the first comment
+	// block of the file it was split from, like a copyright notice, unless
+	// that block is the package doc or build constraints.
+	LicenseHeader bool
+	// DocFile moves the package doc comment of a split file to doc.go,
+	// followed by a comment listing the file's declarations in their original
+	// order, so the doc isn't tied to a file splitting may delete.
+	DocFile bool
+	// LeaveMoveMarker leaves a "// Moved to <file>" comment in the original
+	// file where each moved function and method used to be. Files left
+	// without declarations are still deleted.
+	LeaveMoveMarker bool
+	// KeepOriginal writes the split files but leaves the files they were
+	// split from, source and test files alike, unchanged. The split
+	// declarations then exist twice until the originals are cleaned up by
+	// hand, so the package won't compile in between.
+	KeepOriginal bool
+	// ContinueOnError skips, with a warning, files that fail to parse or
+	// split instead of stopping the run. The errors are returned joined once
+	// every file has been processed.
+	ContinueOnError bool
+	// Verify type-checks each package the run changed with go/types once
+	// splitting is done, and fails the run with ErrVerification when the
+	// split files don't compile together. Imports are checked from source,
+	// which makes it slower.
+	Verify bool
+	// Verbose explains, for each standalone comment next to a split function,
+	// whether it moves along with it and the line distances that decided it.
+	Verbose bool
+	// Output receives progress lines and warnings. Nil means os.Stdout.
+	Output io.Writer
+	// DryRun reports what a run would do without changing any file: the split
+	// runs on a scratch copy of the directory's Go files.
+	DryRun bool
+	// Check implies DryRun and fails the run with ErrNotSplit when any file
+	// would be created, updated or deleted.
+	Check bool
+	// Result, when set, is filled in with the files the run created, updated
+	// and deleted.
+	Result *SplitResult
+
+	// buildConstraint holds the //go:build lines of the file being split,
+	// which the files split from it start with, and buildSuffix the suffix
+	// their names end in, like "_linux" (see splitFileSuffix).
+	buildSuffix     string
+	buildConstraint string
+	// typeBaseNames holds the file base names of the types of the package
+	// whose default name another type shares, like HTTPServer and HttpServer
+	// (see typeBaseName).
+	typeBaseNames map[string]string
+	// buckets is the number of alphabet ranges functions are gathered in
+	// when MaxFilesPerDirectory would be exceeded, or 0.
+	buckets int
+}
+
 type PublicFunction struct {
 	Name               string
 	FuncDecl           *ast.FuncDecl
 	Comments           *ast.CommentGroup
 	StandaloneComments []*ast.CommentGroup
-	InlineComments     []*ast.CommentGroup // Comments inside the function body
+	InlineComments     []*ast.CommentGroup // Comments inside the function, in its signature or body
 	Imports            []*ast.ImportSpec
 	Package            string
+	Provenance         string // Header comment for the generated file, if any
+	source             []byte // The function's text in its file, if it can be moved as is
 }
 
 type PublicDeclaration struct {
-	GenDecl  *ast.GenDecl
-	Comments *ast.CommentGroup
-	Package  string
-	Imports  []*ast.ImportSpec
+	GenDecl            *ast.GenDecl
+	Comments           *ast.CommentGroup
+	StandaloneComments []*ast.CommentGroup // Comments above the doc comment, moved along with it
+	InlineComments     []*ast.CommentGroup // Comments inside the declaration, like those on struct fields
+	Package            string
+	Imports            []*ast.ImportSpec
+	Provenance         string // Header comment for the generated file, if any
 }
 
 type TestFunction struct {
@@ -36,9 +273,11 @@ type TestFunction struct {
 	FuncDecl           *ast.FuncDecl
 	Comments           *ast.CommentGroup
 	StandaloneComments []*ast.CommentGroup
-	InlineComments     []*ast.CommentGroup // Comments inside the function body
+	InlineComments     []*ast.CommentGroup // Comments inside the function, in its signature or body
 	Imports            []*ast.ImportSpec
 	Package            string
+	Provenance         string // Header comment for the generated file, if any
+	source             []byte // The function's text in its file, if it can be moved as is
 }
 
 type PublicMethod struct {
@@ -50,4 +289,5 @@ type PublicMethod struct {
 	InlineComments     []*ast.CommentGroup
 	Imports            []*ast.ImportSpec
 	Package            string
+	Provenance         string // Header comment for the generated file, if any
 }
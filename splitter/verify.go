@@ -0,0 +1,114 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrVerification is returned by Verify runs when the split files of a
+// package don't type-check.
+var ErrVerification = errors.New("split files do not type-check")
+
+// verifySplit type-checks each package directory the run created, updated or
+// deleted files in, and logs every type error it finds.
+func verifySplit(opts Options) error {
+	if !opts.Verify || opts.Result == nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, list := range [][]string{opts.Result.CreatedFiles, opts.Result.UpdatedFiles, opts.Result.DeletedFiles} {
+		for _, path := range list {
+			if dir := filepath.Dir(path); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	slices.Sort(dirs)
+
+	count := 0
+	for _, dir := range dirs {
+		typeErrs, err := typeCheckDirectory(dir)
+		if err != nil {
+			return err
+		}
+		for _, typeErr := range typeErrs {
+			opts.logf("Verify: %v\n", typeErr)
+		}
+		count += len(typeErrs)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d errors", ErrVerification, count)
+	}
+
+	return nil
+}
+
+// typeCheckDirectory type-checks the package in dir: its Go files for the
+// current platform, along with the tests in the same package. Imports are
+// type-checked from source; those that can't be found, like packages of other
+// modules, are not reported, and their uses are taken on trust.
+func typeCheckDirectory(dir string) ([]error, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	var files, tests []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			tests = append(tests, file)
+		} else {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	// Black-box tests import the package, so only white-box ones are checked
+	for _, test := range tests {
+		if test.Name.Name == files[0].Name.Name {
+			files = append(files, test)
+		}
+	}
+
+	var typeErrs []error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			var typeErr types.Error
+			// Continuation lines, like "other declaration of", are left out
+			if errors.As(err, &typeErr) && (strings.HasPrefix(typeErr.Msg, "could not import") || strings.HasPrefix(typeErr.Msg, "\t")) {
+				return
+			}
+			typeErrs = append(typeErrs, err)
+		},
+	}
+	_, _ = conf.Check(files[0].Name.Name, fset, files, nil)
+
+	return typeErrs, nil
+}
@@ -0,0 +1,141 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTypeCheckDirectory(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "compiles",
+			files: map[string]string{
+				"a.go": "package p\n\nimport \"fmt\"\n\nfunc A() { fmt.Println(b()) }\n",
+				"b.go": "package p\n\nfunc b() int { return 1 }\n",
+			},
+		},
+		{
+			name: "missing import",
+			files: map[string]string{
+				"a.go": "package p\n\nfunc A() string { return strings.ToUpper(\"a\") }\n",
+			},
+			want: []string{"a.go:3:26: undefined: strings"},
+		},
+		{
+			name: "unused import",
+			files: map[string]string{
+				"a.go": "package p\n\nimport \"os\"\n\nfunc A() {}\n",
+			},
+			want: []string{`"os" imported and not used`},
+		},
+		{
+			name: "redeclared",
+			files: map[string]string{
+				"a.go": "package p\n\nfunc A() {}\n",
+				"b.go": "package p\n\nfunc A() {}\n",
+			},
+			want: []string{"A redeclared in this block"},
+		},
+		{
+			name: "packages of other modules are trusted",
+			files: map[string]string{
+				"a.go": "package p\n\nimport \"example.com/missing/thing\"\n\nfunc A() int { return thing.Count(thing.New()) }\n",
+			},
+		},
+		{
+			name: "white-box tests are checked, black-box tests and other platforms are not",
+			files: map[string]string{
+				"a.go":         "package p\n\nfunc A() {}\n",
+				"a_test.go":    "package p\n\nfunc helper() { B() }\n",
+				"x_test.go":    "package p_test\n\nfunc helper() { C() }\n",
+				"a_plan9.go":   "package p\n\nfunc A() {}\n",
+				"ignored.go":   "//go:build ignore\n\npackage main\n",
+				"testdata.txt": "not go",
+			},
+			want: []string{"undefined: B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			typeErrs, err := typeCheckDirectory(tmpDir)
+			if err != nil {
+				t.Fatalf("typeCheckDirectory failed: %v", err)
+			}
+			if len(typeErrs) != len(tt.want) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.want), typeErrs)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(typeErrs[i].Error(), want) {
+					t.Errorf("Expected error %q, got %q", want, typeErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPublicFunctions_Verify(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "text.go")
+	testContent := `package text
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shout upper-cases s.
+func Shout(s string) string {
+	return strings.ToUpper(s)
+}
+
+// Greet greets name.
+func Greet(name string) string {
+	return fmt.Sprintf("hello %s", Shout(name))
+}
+`
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &SplitResult{}
+	if err := SplitPublicFunctions(tmpDir, Options{Verify: true, Output: io.Discard, Result: result}); err != nil {
+		t.Fatalf("SplitPublicFunctions failed verification: %v", err)
+	}
+
+	// Drop the import shout.go needs, as an import bug would
+	shoutFile := filepath.Join(tmpDir, "shout.go")
+	content, err := os.ReadFile(shoutFile)
+	if err != nil {
+		t.Fatalf("Expected shout.go to be created: %v", err)
+	}
+	broken := strings.Replace(string(content), `import "strings"`, "", 1)
+	if err := os.WriteFile(shoutFile, []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = verifySplit(Options{Verify: true, Output: &out, Result: result})
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("Expected ErrVerification, got %v", err)
+	}
+	if !strings.Contains(out.String(), "shout.go") || !strings.Contains(out.String(), "undefined: strings") {
+		t.Errorf("Expected the missing import to be reported, got:\n%s", out.String())
+	}
+}
@@ -1,25 +1,38 @@
 package splitter
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
 func writePublicFunction(filename string, fn PublicFunction, fset *token.FileSet) error {
-	return writeFunctionGeneric(filename, fn.FuncDecl, fn.Comments, fn.StandaloneComments, fn.InlineComments, fn.Imports, fn.Package, fset)
+	if fn.source != nil {
+		return writeFunctionSource(filename, fn, fset)
+	}
+
+	return writeFunctionGeneric(filename, fn.Provenance, fn.FuncDecl, fn.Comments, fn.StandaloneComments, fn.InlineComments, fn.Imports, fn.Package, fset)
 }
 
 func writeTestFunction(filename string, test TestFunction, fset *token.FileSet) error {
-	return writeFunctionGeneric(filename, test.FuncDecl, test.Comments, test.StandaloneComments, test.InlineComments, test.Imports, test.Package, fset)
+	if test.source != nil {
+		return writeFunctionSource(filename, PublicFunction(test), fset)
+	}
+
+	return writeFunctionGeneric(filename, test.Provenance, test.FuncDecl, test.Comments, test.StandaloneComments, test.InlineComments, test.Imports, test.Package, fset)
 }
 
 // writeFunctionGeneric is a generic function to write a function (either public or test) to a file.
-func writeFunctionGeneric(filename string, funcDecl *ast.FuncDecl, comments *ast.CommentGroup, standaloneComments, inlineComments []*ast.CommentGroup, imports []*ast.ImportSpec, packageName string, fset *token.FileSet) error {
+func writeFunctionGeneric(filename, provenance string, funcDecl *ast.FuncDecl, comments *ast.CommentGroup, standaloneComments, inlineComments []*ast.CommentGroup, imports []*ast.ImportSpec, packageName string, fset *token.FileSet) error {
 	var decls []ast.Decl
 
 	// Find which imports are actually used
@@ -27,14 +40,7 @@ func writeFunctionGeneric(filename string, funcDecl *ast.FuncDecl, comments *ast
 
 	// Add import declarations if there are any used imports
 	if len(usedImports) > 0 {
-		importDecl := &ast.GenDecl{
-			Tok:   token.IMPORT,
-			Specs: make([]ast.Spec, len(usedImports)),
-		}
-		for i, imp := range usedImports {
-			importDecl.Specs[i] = imp
-		}
-		decls = append(decls, importDecl)
+		decls = append(decls, importDeclaration(usedImports))
 	}
 
 	// Add the function with its comments
@@ -43,26 +49,162 @@ func writeFunctionGeneric(filename string, funcDecl *ast.FuncDecl, comments *ast
 	}
 	decls = append(decls, funcDecl)
 
-	// Combine all comments: doc, standalone, and inline
-	var allComments []*ast.CommentGroup
-	if comments != nil {
-		allComments = append(allComments, comments)
-	}
-	allComments = append(allComments, standaloneComments...)
-	allComments = append(allComments, inlineComments...)
+	// The doc comment is attached once, through funcDecl.Doc
+	var otherComments []*ast.CommentGroup
+	otherComments = append(otherComments, standaloneComments...)
+	otherComments = append(otherComments, inlineComments...)
 
 	// Create an AST file
 	astFile := &ast.File{
 		Name:     &ast.Ident{Name: packageName},
 		Decls:    decls,
-		Comments: allComments,
+		Comments: fileComments(decls, otherComments),
 	}
 
 	// Format and write to file
-	return formatAndWriteFile(filename, astFile, fset)
+	return formatAndWriteFile(filename, provenance, astFile, fset)
+}
+
+// writeFunctionSource writes fn to filename as the text it had in its file,
+// below the imports it uses. Reprinting the function places its comments by
+// positions that no longer hold in the new file, so this keeps it exactly as
+// it was, aligned comments and all.
+func writeFunctionSource(filename string, fn PublicFunction, fset *token.FileSet) error {
+	astFile := &ast.File{Name: &ast.Ident{Name: fn.Package}}
+	if usedImports := findUsedImports(fn.FuncDecl, fn.Imports); len(usedImports) > 0 {
+		astFile.Decls = []ast.Decl{importDeclaration(usedImports)}
+	}
+	head, err := formatFile(fn.Provenance, astFile, fset)
+	if err != nil {
+		return err
+	}
+
+	src, err := format.Source(slices.Concat(head, []byte("\n"), fn.source, []byte("\n")))
+	if err != nil {
+		return fmt.Errorf("failed to format code: %w", err)
+	}
+
+	return writeSource(filename, src)
+}
+
+// fileComments returns the comment list of a generated file. Without other
+// comments it is nil, so doc (and field) comments print through the nodes of
+// decls; with them, go/printer prints only the listed comments, so the node
+// comments are listed as well. Each group appears once, in source order.
+func fileComments(decls []ast.Decl, others []*ast.CommentGroup) []*ast.CommentGroup {
+	if len(others) == 0 {
+		return nil
+	}
+
+	var all []*ast.CommentGroup
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if cg, ok := n.(*ast.CommentGroup); ok {
+				all = append(all, cg)
+			}
+
+			return true
+		})
+	}
+	all = append(all, others...)
+
+	seen := make(map[*ast.CommentGroup]bool, len(all))
+	result := make([]*ast.CommentGroup, 0, len(all))
+	for _, cg := range all {
+		if cg == nil || seen[cg] {
+			continue
+		}
+		seen[cg] = true
+		result = append(result, cg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Pos() < result[j].Pos()
+	})
+
+	return result
+}
+
+// writeFunctionsToFile writes several functions into a single file, keeping
+// their comments and only the imports they use.
+func writeFunctionsToFile(filename string, fns []PublicFunction, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	return formatAndWriteFile(filename, fns[0].Provenance, functionsFile(fns, packageName, imports), fset)
+}
+
+// appendFunctionsToFile writes fns to filename after the declarations it
+// already holds, so that several source files can share one output file.
+func appendFunctionsToFile(filename string, fns []PublicFunction, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	existing, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return writeFunctionsToFile(filename, fns, packageName, imports, fset)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+
+	src, err := formatFile(fns[0].Provenance, functionsFile(fns, packageName, imports), fset)
+	if err != nil {
+		return err
+	}
+
+	return mergeSources(filename, existing, src)
+}
+
+// functionsFile builds a file holding fns and the imports they use.
+func functionsFile(fns []PublicFunction, packageName string, imports []*ast.ImportSpec) *ast.File {
+	funcDecls := make([]ast.Decl, 0, len(fns))
+	var otherComments []*ast.CommentGroup
+	for _, fn := range fns {
+		if fn.Comments != nil {
+			fn.FuncDecl.Doc = fn.Comments
+		}
+		otherComments = append(otherComments, fn.StandaloneComments...)
+		otherComments = append(otherComments, fn.InlineComments...)
+		funcDecls = append(funcDecls, fn.FuncDecl)
+	}
+
+	decls := make([]ast.Decl, 0, len(funcDecls)+1)
+	if usedImports := findUsedImportsInDecls(funcDecls, imports); len(usedImports) > 0 {
+		decls = append(decls, importDeclaration(usedImports))
+	}
+	decls = append(decls, funcDecls...)
+
+	return &ast.File{
+		Name:     &ast.Ident{Name: packageName},
+		Decls:    decls,
+		Comments: fileComments(decls, otherComments),
+	}
 }
 
 func writeCommonFile(filename string, decls []PublicDeclaration, pkgName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	astFile := declarationsFile(decls, pkgName, imports)
+
+	// Keep what an earlier run or a human already put in the file, unless it
+	// is the file being split. It is rendered on its own, since its positions
+	// can't be mixed with ours. Like a partly redeclared iota block, imports
+	// of different packages under one name make the merge fail.
+	var existing []byte
+	if fset.Position(decls[0].GenDecl.Pos()).Filename != filename {
+		var err error
+		existing, err = readExistingDeclarations(filename, decls, pkgName)
+		if err != nil {
+			return err
+		}
+	}
+	if existing == nil {
+		return formatAndWriteFile(filename, decls[0].Provenance, astFile, fset)
+	}
+
+	src, err := formatFile(decls[0].Provenance, astFile, fset)
+	if err != nil {
+		return err
+	}
+
+	return mergeSources(filename, existing, src)
+}
+
+// declarationsFile builds a file holding decls with their comments, including
+// those on struct fields, and the imports they use.
+func declarationsFile(decls []PublicDeclaration, pkgName string, imports []*ast.ImportSpec) *ast.File {
 	astDecls := make([]ast.Decl, 0, len(decls)+1)
 
 	// Collect all used imports from declarations
@@ -95,35 +237,247 @@ func writeCommonFile(filename string, decls []PublicDeclaration, pkgName string,
 			usedImports = append(usedImports, imp)
 		}
 	}
+	genDecls := make([]ast.Decl, 0, len(decls))
+	for _, decl := range decls {
+		genDecls = append(genDecls, decl.GenDecl)
+	}
+	usedImports = dedupeImports(keepEmbedImport(genDecls, usedImports, imports))
 
 	if len(usedImports) > 0 {
-		importDecl := &ast.GenDecl{
-			Tok:   token.IMPORT,
-			Specs: make([]ast.Spec, len(usedImports)),
-		}
-		for i, imp := range usedImports {
-			importDecl.Specs[i] = imp
-		}
-		astDecls = append(astDecls, importDecl)
+		astDecls = append(astDecls, importDeclaration(usedImports))
 	}
 
 	// Add all public declarations
+	var otherComments []*ast.CommentGroup
 	for _, decl := range decls {
 		astDecls = append(astDecls, decl.GenDecl)
+		otherComments = append(otherComments, decl.StandaloneComments...)
+		otherComments = append(otherComments, decl.InlineComments...)
 	}
 
-	// Create an AST file
-	astFile := &ast.File{
-		Name:  &ast.Ident{Name: pkgName},
-		Decls: astDecls,
+	return &ast.File{
+		Name:     &ast.Ident{Name: pkgName},
+		Decls:    astDecls,
+		Comments: fileComments(astDecls, otherComments),
 	}
+}
 
-	// Format and write to file
-	if err := formatAndWriteFile(filename, astFile, fset); err != nil {
-		return err
+// writeDeclarationBlocks writes each public var/const block to its own file named
+// after the first public name in the block, and returns the remaining declarations.
+func writeDeclarationBlocks(opts Options, outputDir string, decls []PublicDeclaration, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) ([]PublicDeclaration, error) {
+	var remaining []PublicDeclaration
+	for _, decl := range decls {
+		if decl.GenDecl.Tok != token.VAR && decl.GenDecl.Tok != token.CONST {
+			remaining = append(remaining, decl)
+
+			continue
+		}
+
+		snakeCaseName := opts.fileBaseName(firstPublicName(decl.GenDecl))
+		outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
+
+		if err := writeCommonFile(outputFile, []PublicDeclaration{decl}, packageName, imports, fset); err != nil {
+			return nil, fmt.Errorf("failed to write declaration file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s\n", outputFile)
 	}
 
-	return nil
+	return remaining, nil
+}
+
+// writeInterfaces writes each public interface type to its own file, together
+// with its doc comment, and returns the declarations without those interfaces.
+func writeInterfaces(opts Options, outputDir string, decls []PublicDeclaration, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) ([]PublicDeclaration, error) {
+	isInterface := func(ts *ast.TypeSpec) bool {
+		_, ok := ts.Type.(*ast.InterfaceType)
+
+		return ok
+	}
+
+	return writeTypeSpecs(decls, packageName, imports, isInterface, func(name string, decl PublicDeclaration) error {
+		snakeCaseName := opts.typeBaseName(name)
+		outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
+
+		if err := writeCommonFile(outputFile, []PublicDeclaration{decl}, packageName, imports, fset); err != nil {
+			return fmt.Errorf("failed to write interface file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (interface)\n", outputFile)
+
+		return nil
+	})
+}
+
+// writeTypes writes each public type to its own file named <prefix><name>.go,
+// together with its doc comment, and returns the remaining declarations. A
+// prefix like "type_" keeps a type apart from the functions and methods named
+// after it.
+func writeTypes(opts Options, outputDir, prefix string, decls []PublicDeclaration, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) ([]PublicDeclaration, error) {
+	anyType := func(*ast.TypeSpec) bool { return true }
+
+	return writeTypeSpecs(decls, packageName, imports, anyType, func(name string, decl PublicDeclaration) error {
+		snakeCaseName := opts.typeBaseName(name)
+		outputFile := filepath.Join(outputDir, opts.goFileName(prefix+snakeCaseName))
+
+		if err := writeCommonFile(outputFile, []PublicDeclaration{decl}, packageName, imports, fset); err != nil {
+			return fmt.Errorf("failed to write type file %s: %w", outputFile, err)
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (type)\n", outputFile)
+
+		return nil
+	})
+}
+
+// writeTypeSpecs calls write for every public type spec in decls accepted by
+// match, splitting grouped type blocks as needed, and returns the declarations
+// that are left.
+func writeTypeSpecs(decls []PublicDeclaration, packageName string, imports []*ast.ImportSpec, match func(*ast.TypeSpec) bool, write func(name string, decl PublicDeclaration) error) ([]PublicDeclaration, error) {
+	var remaining []PublicDeclaration
+	for _, decl := range decls {
+		if decl.GenDecl.Tok != token.TYPE {
+			remaining = append(remaining, decl)
+
+			continue
+		}
+
+		var otherSpecs, writtenSpecs []ast.Spec
+		for _, spec := range decl.GenDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || !isPublicName(ts.Name.Name) || !match(ts) {
+				otherSpecs = append(otherSpecs, spec)
+
+				continue
+			}
+			writtenSpecs = append(writtenSpecs, spec)
+
+			typeDecl := decl
+			if len(decl.GenDecl.Specs) > 1 {
+				typeDecl = PublicDeclaration{
+					GenDecl:        singleTypeDecl(ts),
+					Comments:       ts.Doc,
+					InlineComments: commentsWithin(decl.InlineComments, ts),
+					Package:        packageName,
+					Imports:        imports,
+					Provenance:     decl.Provenance,
+				}
+			}
+
+			if err := write(ts.Name.Name, typeDecl); err != nil {
+				return nil, err
+			}
+		}
+
+		switch len(otherSpecs) {
+		case 0:
+			// Every spec was written
+		case len(decl.GenDecl.Specs):
+			remaining = append(remaining, decl)
+		default:
+			genDecl := *decl.GenDecl
+			genDecl.Specs = otherSpecs
+			decl.GenDecl = &genDecl
+			// Comments of the written specs went with them
+			decl.InlineComments = slices.DeleteFunc(slices.Clone(decl.InlineComments), func(cg *ast.CommentGroup) bool {
+				return slices.ContainsFunc(writtenSpecs, func(spec ast.Spec) bool {
+					return len(commentsWithin([]*ast.CommentGroup{cg}, spec)) > 0
+				})
+			})
+			remaining = append(remaining, decl)
+		}
+	}
+
+	return remaining, nil
+}
+
+// singleTypeDecl turns a spec taken out of a grouped type block into its own
+// declaration, keeping its original position so its doc comment stays above it.
+func singleTypeDecl(ts *ast.TypeSpec) *ast.GenDecl {
+	spec := *ts
+	spec.Doc = nil
+
+	return &ast.GenDecl{Doc: ts.Doc, TokPos: ts.Pos(), Tok: token.TYPE, Specs: []ast.Spec{&spec}}
+}
+
+// readExistingDeclarations parses filename, if it exists and belongs to the
+// same package, and returns its source without the specs decls redeclare and
+// their comments. It returns nil when there is no such file. A const block
+// relying on iota is only removed whole, since removing some of its specs
+// would change the values of the rest.
+func readExistingDeclarations(filename string, decls []PublicDeclaration, pkgName string) ([]byte, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing file %s: %w", filename, err)
+	}
+	if node.Name.Name != pkgName {
+		return nil, nil
+	}
+
+	newNames := make(map[string]bool)
+	for _, decl := range decls {
+		for _, name := range declaredNames(decl.GenDecl) {
+			newNames[name] = true
+		}
+	}
+
+	// The removed specs and blocks, whose comments go with them
+	var removed []ast.Node
+	nodeDecls := make([]ast.Decl, 0, len(node.Decls))
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok == token.IMPORT {
+			nodeDecls = append(nodeDecls, decl)
+
+			continue
+		}
+
+		var specs []ast.Spec
+		for _, spec := range genDecl.Specs {
+			redeclared := false
+			for _, name := range specNames(spec) {
+				if newNames[name] {
+					redeclared = true
+				}
+			}
+			if redeclared {
+				removed = append(removed, spec)
+
+				continue
+			}
+			specs = append(specs, spec)
+		}
+		if len(specs) == 0 {
+			removed = append(removed, genDecl)
+
+			continue
+		}
+		if len(specs) < len(genDecl.Specs) && usesIota(genDecl) {
+			return nil, fmt.Errorf("%w in %s", ErrIotaBlockRedeclared, filename)
+		}
+		genDecl.Specs = specs
+		nodeDecls = append(nodeDecls, genDecl)
+	}
+	node.Decls = nodeDecls
+
+	var comments []*ast.CommentGroup
+	for _, cg := range node.Comments {
+		if !slices.ContainsFunc(removed, func(n ast.Node) bool {
+			start, end := commentRange(n)
+
+			return cg.Pos() >= start && cg.End() <= end
+		}) {
+			comments = append(comments, cg)
+		}
+	}
+	node.Comments = comments
+
+	return formatFile("", node, fset)
 }
 
 func writeTestsToFile(filename string, tests []TestFunction, fset *token.FileSet) error {
@@ -131,6 +485,12 @@ func writeTestsToFile(filename string, tests []TestFunction, fset *token.FileSet
 		return nil
 	}
 
+	return formatAndWriteFile(filename, tests[0].Provenance, testsFile(tests), fset)
+}
+
+// testsFile builds a file of tests' package holding them and the imports they
+// use.
+func testsFile(tests []TestFunction) *ast.File {
 	decls := make([]ast.Decl, 0, len(tests)+1)
 
 	// Collect all imports needed
@@ -139,15 +499,7 @@ func writeTestsToFile(filename string, tests []TestFunction, fset *token.FileSet
 	usedPackages["testing"] = true
 
 	for _, test := range tests {
-		ast.Inspect(test.FuncDecl, func(n ast.Node) bool {
-			if x, ok := n.(*ast.SelectorExpr); ok {
-				if ident, ok := x.X.(*ast.Ident); ok {
-					usedPackages[ident.Name] = true
-				}
-			}
-
-			return true
-		})
+		addPackageNames(usedPackages, test.FuncDecl)
 	}
 
 	// Add import declarations
@@ -166,53 +518,221 @@ func writeTestsToFile(filename string, tests []TestFunction, fset *token.FileSet
 			usedImports = append(usedImports, imp)
 		}
 	}
+	usedImports = dedupeImports(usedImports)
 
 	if len(usedImports) > 0 {
-		importDecl := &ast.GenDecl{
-			Tok:   token.IMPORT,
-			Specs: make([]ast.Spec, len(usedImports)),
-		}
-		for i, imp := range usedImports {
-			importDecl.Specs[i] = imp
-		}
-		decls = append(decls, importDecl)
+		decls = append(decls, importDeclaration(usedImports))
 	}
 
 	// Add all test functions
+	var otherComments []*ast.CommentGroup
 	for _, test := range tests {
 		if test.Comments != nil {
 			test.FuncDecl.Doc = test.Comments
 		}
+		otherComments = append(otherComments, test.StandaloneComments...)
+		otherComments = append(otherComments, test.InlineComments...)
 		decls = append(decls, test.FuncDecl)
 	}
 
-	// Create an AST file
-	astFile := &ast.File{
-		Name:  &ast.Ident{Name: tests[0].Package},
-		Decls: decls,
+	return &ast.File{
+		Name:     &ast.Ident{Name: tests[0].Package},
+		Decls:    decls,
+		Comments: fileComments(decls, otherComments),
 	}
+}
 
-	// Format and write to file
-	if err := formatAndWriteFile(filename, astFile, fset); err != nil {
+// appendTestsToFile writes tests to filename after the tests it already holds,
+// which must belong to the same package, instead of overwriting them.
+func appendTestsToFile(filename string, tests []TestFunction, fset *token.FileSet) error {
+	existing, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return writeTestsToFile(filename, tests, fset)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+
+	src, err := formatFile(tests[0].Provenance, testsFile(tests), fset)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return mergeSources(filename, existing, src)
 }
 
-func formatAndWriteFile(filename string, astFile *ast.File, fset *token.FileSet) error {
-	var buf strings.Builder
-	if err := format.Node(&buf, fset, astFile); err != nil {
-		return fmt.Errorf("failed to format code: %w", err)
+// writeDocFile writes the package doc of node, parsed from src, to doc.go
+// next to filename, followed by a comment listing the declarations of
+// filename in their original order. It reports false, leaving the doc to
+// filename, when filename is doc.go or doc.go already exists.
+func writeDocFile(opts Options, filename string, src []byte, node *ast.File) (bool, error) {
+	docFile := filepath.Join(filepath.Dir(filename), opts.withBuildSuffix(docFileName))
+	if docFile == filename {
+		return false, nil
+	}
+	if _, err := os.Stat(docFile); err == nil {
+		opts.logf("Note: %s already exists, so the package doc stays in %s\n", docFile, filename)
+
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to check for doc file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if header := opts.fileHeader(node, opts.buildConstraint); header != "" {
+		buf.WriteString(header + "\n\n")
+	}
+	buf.Write(src[node.Doc.Pos()-node.FileStart : node.Doc.End()-node.FileStart])
+	fmt.Fprintf(&buf, "\npackage %s\n", node.Name.Name)
+
+	var names []string
+	for _, decl := range node.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			names = append(names, funcDeclKey(d))
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, name := range declaredNames(d) {
+				if name != "_" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&buf, "\n// Declarations of %s before splitting, in their original order:\n//\n", filepath.Base(filename))
+		for _, name := range names {
+			fmt.Fprintf(&buf, "//   - %s\n", name)
+		}
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return false, fmt.Errorf("failed to format doc file: %w", err)
+	}
+	if err := writeSource(docFile, formatted); err != nil {
+		return false, err
+	}
+	opts.recordCreated(docFile)
+	opts.logf("Created: %s (package doc)\n", docFile)
+
+	return true, nil
+}
+
+// formatAndWriteFile formats astFile and writes it to filename, preceded by the
+// header comment if one is given.
+func formatAndWriteFile(filename, header string, astFile *ast.File, fset *token.FileSet) error {
+	src, err := formatFile(header, astFile, fset)
+	if err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(filename, []byte(buf.String()), 0o600); err != nil {
+	return writeSource(filename, src)
+}
+
+// writeSource writes src to filename. An existing file keeps its permissions
+// and, for formatted source, its line endings; a new one is created with
+// newFileMode, like the go tool does.
+func writeSource(filename string, src []byte) error {
+	mode := os.FileMode(newFileMode)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+		// Formatted source has LF endings; src with any CR is written as is
+		if existing, err := os.ReadFile(filename); err == nil && !bytes.ContainsRune(src, '\r') {
+			src = withLineEnding(src, lineEnding(existing))
+		}
+	}
+	if err := os.WriteFile(filename, src, mode); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
 
+// lineEnding returns the line ending most lines of src end with: "\r\n" or
+// "\n".
+func lineEnding(src []byte) string {
+	crlf := bytes.Count(src, []byte("\r\n"))
+	if crlf > 0 && 2*crlf > bytes.Count(src, []byte("\n")) {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// withLineEnding returns src, which has LF line endings, with each line
+// ending in ending instead. Go drops carriage returns from raw strings and
+// comments, so this doesn't change what the code means.
+func withLineEnding(src []byte, ending string) []byte {
+	if ending == "\n" {
+		return src
+	}
+
+	return bytes.ReplaceAll(src, []byte("\n"), []byte(ending))
+}
+
+// convertLineEndings rewrites filename, written with LF line endings, to end
+// its lines in ending.
+func convertLineEndings(filename, ending string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if bytes.ContainsRune(src, '\r') {
+		return nil
+	}
+
+	return writeSource(filename, withLineEnding(src, ending))
+}
+
+// formatFile renders astFile as gofmt'ed source, preceded by header if any.
+func formatFile(header string, astFile *ast.File, fset *token.FileSet) ([]byte, error) {
+	var buf bytes.Buffer
+	if header != "" {
+		// The blank line keeps the header from becoming the package doc
+		buf.WriteString(header + "\n\n")
+	}
+	if err := format.Node(&buf, fset, astFile); err != nil {
+		return nil, fmt.Errorf("failed to format code: %w", err)
+	}
+
+	return separateImportGroups(buf.Bytes()), nil
+}
+
+// separateImportGroups inserts a blank line where a standard library import
+// is directly followed by another one, as goimports does.
+func separateImportGroups(src []byte) []byte {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return src
+	}
+	file := fset.File(node.Pos())
+
+	var offsets []int
+	for i := 1; i < len(node.Imports); i++ {
+		prev, cur := node.Imports[i-1], node.Imports[i]
+		if !isStandardImport(prev) || isStandardImport(cur) {
+			continue
+		}
+		start := cur.Pos()
+		if cur.Doc != nil {
+			start = cur.Doc.Pos()
+		}
+		if line := file.Line(start); line == file.Line(prev.End())+1 {
+			offsets = append(offsets, file.Offset(file.LineStart(line)))
+		}
+	}
+
+	for _, offset := range slices.Backward(offsets) {
+		src = slices.Insert(src, offset, '\n')
+	}
+
+	return src
+}
+
 func writePublicMethod(filename string, method PublicMethod, fset *token.FileSet) error {
 	// Build the declarations
 	var decls []ast.Decl
@@ -234,16 +754,10 @@ func writePublicMethod(filename string, method PublicMethod, fset *token.FileSet
 			usedImports = append(usedImports, imp)
 		}
 	}
+	usedImports = dedupeImports(usedImports)
 
 	if len(usedImports) > 0 {
-		importDecl := &ast.GenDecl{
-			Tok:   token.IMPORT,
-			Specs: make([]ast.Spec, len(usedImports)),
-		}
-		for i, imp := range usedImports {
-			importDecl.Specs[i] = imp
-		}
-		decls = append(decls, importDecl)
+		decls = append(decls, importDeclaration(usedImports))
 	}
 
 	// Add the method with its comment
@@ -252,31 +766,27 @@ func writePublicMethod(filename string, method PublicMethod, fset *token.FileSet
 	}
 	decls = append(decls, method.FuncDecl)
 
+	// Add standalone and inline comments
+	var otherComments []*ast.CommentGroup
+	otherComments = append(otherComments, method.StandaloneComments...)
+	otherComments = append(otherComments, method.InlineComments...)
+
 	// Create an AST file
 	astFile := &ast.File{
-		Name:  &ast.Ident{Name: method.Package},
-		Decls: decls,
-	}
-
-	// Add inline comments
-	if len(method.InlineComments) > 0 {
-		astFile.Comments = method.InlineComments
-	}
-
-	// Add standalone comments if present
-	if len(method.StandaloneComments) > 0 {
-		astFile.Comments = append(method.StandaloneComments, astFile.Comments...)
+		Name:     &ast.Ident{Name: method.Package},
+		Decls:    decls,
+		Comments: fileComments(decls, otherComments),
 	}
 
 	// Format and write to file
-	if err := formatAndWriteFile(filename, astFile, fset); err != nil {
+	if err := formatAndWriteFile(filename, method.Provenance, astFile, fset); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func writeMethodsWithStructs(outputDir string, publicDecls []PublicDeclaration, publicMethods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+func writeMethodsWithStructs(opts Options, outputDir string, publicDecls []PublicDeclaration, constructors map[string][]PublicFunction, publicMethods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
 	// Group methods by their receiver type
 	methodsByType := make(map[string][]PublicMethod)
 	for _, method := range publicMethods {
@@ -285,13 +795,21 @@ func writeMethodsWithStructs(outputDir string, publicDecls []PublicDeclaration,
 
 	// Collect type declarations
 	typeDecls := make(map[string]*ast.GenDecl)
+	typeProvenance := make(map[string]string)
+	typeComments := make(map[string][]*ast.CommentGroup)
 	otherDecls := []PublicDeclaration{}
 
 	for _, decl := range publicDecls {
 		hasType := false
 		for _, spec := range decl.GenDecl.Specs {
 			if ts, ok := spec.(*ast.TypeSpec); ok {
+				// Each type of a grouped block gets only its own spec
 				typeDecls[ts.Name.Name] = decl.GenDecl
+				if len(decl.GenDecl.Specs) > 1 {
+					typeDecls[ts.Name.Name] = singleTypeDecl(ts)
+				}
+				typeProvenance[ts.Name.Name] = decl.Provenance
+				typeComments[ts.Name.Name] = append(slices.Clone(decl.StandaloneComments), commentsWithin(decl.InlineComments, ts)...)
 				hasType = true
 			}
 		}
@@ -300,81 +818,162 @@ func writeMethodsWithStructs(outputDir string, publicDecls []PublicDeclaration,
 		}
 	}
 
-	// Write each type with its methods to a separate file
-	for typeName, typeDecl := range typeDecls {
+	// Methods of an unexported type go to the file of its public alias
+	for target, alias := range aliasOwners(publicDecls) {
+		if methods, ok := methodsByType[target]; ok {
+			methodsByType[alias] = append(methodsByType[alias], methods...)
+			slices.SortStableFunc(methodsByType[alias], func(a, b PublicMethod) int {
+				return cmp.Compare(a.FuncDecl.Pos(), b.FuncDecl.Pos())
+			})
+			delete(methodsByType, target)
+		}
+	}
+
+	// Move consts/vars that belong to a type into that type's file
+	typeNames := make(map[string]bool, len(typeDecls))
+	for typeName := range typeDecls {
+		typeNames[typeName] = true
+	}
+	relatedDecls := make(map[string][]*ast.GenDecl)
+	unrelatedDecls := []PublicDeclaration{}
+	for _, decl := range otherDecls {
+		if typeName := associatedTypeName(decl.GenDecl, typeNames); typeName != "" {
+			relatedDecls[typeName] = append(relatedDecls[typeName], decl.GenDecl)
+		} else {
+			unrelatedDecls = append(unrelatedDecls, decl)
+		}
+	}
+	otherDecls = unrelatedDecls
+
+	// Write each type with its methods to a separate file, in a stable order
+	sortedTypeNames := make([]string, 0, len(typeDecls))
+	for typeName := range typeDecls {
+		sortedTypeNames = append(sortedTypeNames, typeName)
+	}
+	sort.Strings(sortedTypeNames)
+	for _, typeName := range sortedTypeNames {
+		typeDecl := typeDecls[typeName]
 		methods := methodsByType[typeName]
 
-		snakeCaseName := functionNameToSnakeCase(typeName)
-		outputFileName := snakeCaseName + ".go"
-		outputFile := filepath.Join(outputDir, outputFileName)
+		snakeCaseName := opts.typeBaseName(typeName)
+		outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
 
-		if err := writeTypeWithMethods(outputFile, typeDecl, methods, packageName, imports, fset); err != nil {
+		if err := writeTypeWithMethods(outputFile, typeProvenance[typeName], typeDecl, typeComments[typeName], relatedDecls[typeName], constructors[typeName], methods, packageName, imports, fset); err != nil {
 			return fmt.Errorf("failed to write type file %s: %w", outputFile, err)
 		}
-		fmt.Printf("Created: %s (with %d methods)\n", outputFile, len(methods))
+		if err := opts.sortFile(outputFile); err != nil {
+			return err
+		}
+		opts.recordCreated(outputFile)
+		opts.logf("Created: %s (with %d methods)\n", outputFile, len(methods))
+	}
+
+	// Write each public var/const block to its own file when requested
+	if opts.GroupVarsByBlock || opts.NoCommonFile {
+		var err error
+		otherDecls, err = writeDeclarationBlocks(opts, outputDir, otherDecls, packageName, imports, fset)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Write types without methods and other declarations to common.go
+	// Write declarations not tied to any type to common.go. Every type,
+	// with or without methods, already has its own file.
 	if len(otherDecls) > 0 {
-		// Add types that don't have methods
-		for typeName, typeDecl := range typeDecls {
-			if _, hasMethods := methodsByType[typeName]; !hasMethods {
-				otherDecls = append(otherDecls, PublicDeclaration{
-					GenDecl: typeDecl,
-					Package: packageName,
-					Imports: imports,
-				})
+		commonFile, err := opts.commonFileFor(outputDir, packageName)
+		if err != nil {
+			return err
+		}
+		if err := writeCommonFile(commonFile, otherDecls, packageName, imports, fset); err != nil {
+			return fmt.Errorf("failed to write common.go: %w", err)
+		}
+		if err := opts.sortFile(commonFile); err != nil {
+			return err
+		}
+		opts.recordCreated(commonFile)
+		opts.logf("Created: %s\n", commonFile)
+	}
+
+	// Write orphaned methods (methods whose types aren't found). Those of a
+	// type declared in another file of the package, or given a public alias
+	// there, go to the type's file, where splitting that file puts the type
+	// as well.
+	receiverTypes := make([]string, 0, len(methodsByType))
+	for typeName := range methodsByType {
+		receiverTypes = append(receiverTypes, typeName)
+	}
+	sort.Strings(receiverTypes)
+	var siblingTypes map[string]string
+	for _, typeName := range receiverTypes {
+		if _, found := typeDecls[typeName]; found {
+			continue
+		}
+		methods := methodsByType[typeName]
+		if siblingTypes == nil {
+			var err error
+			siblingTypes, err = siblingTypeNames(fset.Position(methods[0].FuncDecl.Pos()).Filename, packageName)
+			if err != nil {
+				return fmt.Errorf("failed to parse package files: %w", err)
 			}
 		}
 
-		if len(otherDecls) > 0 {
-			commonFile := filepath.Join(outputDir, "common.go")
-			if err := writeCommonFile(commonFile, otherDecls, packageName, imports, fset); err != nil {
-				return fmt.Errorf("failed to write common.go: %w", err)
+		owner := siblingTypes[typeName]
+		typeFile := filepath.Join(outputDir, opts.goFileName(opts.typeBaseName(owner)))
+		if owner != "" && opts.mayAppendTo(typeFile) {
+			if err := appendMethodsToFile(typeFile, methods, packageName, imports, fset); err != nil {
+				return fmt.Errorf("failed to write type file %s: %w", typeFile, err)
+			}
+			if err := opts.sortFile(typeFile); err != nil {
+				return err
 			}
-			fmt.Printf("Created: %s\n", commonFile)
+			opts.recordCreated(typeFile)
+			opts.logf("Created: %s (with %d methods)\n", typeFile, len(methods))
+
+			continue
 		}
-	}
 
-	// Write orphaned methods (methods whose types aren't found)
-	for typeName, methods := range methodsByType {
-		if _, found := typeDecls[typeName]; !found {
-			// Write each orphaned method separately
-			for _, method := range methods {
-				snakeCaseName := methodNameToSnakeCase(method.ReceiverType, method.Name)
-				outputFileName := snakeCaseName + ".go"
-				outputFile := filepath.Join(outputDir, outputFileName)
+		// Write each orphaned method separately
+		for _, method := range methods {
+			snakeCaseName := opts.methodBaseName(method.ReceiverType, method.Name)
+			outputFile := filepath.Join(outputDir, opts.goFileName(snakeCaseName))
 
-				if err := writePublicMethod(outputFile, method, fset); err != nil {
-					return fmt.Errorf("failed to write orphaned method file %s: %w", outputFile, err)
-				}
-				fmt.Printf("Created: %s (orphaned method)\n", outputFile)
+			if err := writePublicMethod(outputFile, method, fset); err != nil {
+				return fmt.Errorf("failed to write orphaned method file %s: %w", outputFile, err)
 			}
+			opts.recordCreated(outputFile)
+			opts.logf("Created: %s (orphaned method)\n", outputFile)
 		}
 	}
 
 	return nil
 }
 
-func writeTypeWithMethods(filename string, typeDecl *ast.GenDecl, methods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+func writeTypeWithMethods(filename, provenance string, typeDecl *ast.GenDecl, typeComments []*ast.CommentGroup, relatedDecls []*ast.GenDecl, constructors []PublicFunction, methods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
 	// Build the declarations
-	decls := make([]ast.Decl, 0, len(methods)+2)
+	decls := make([]ast.Decl, 0, len(relatedDecls)+len(constructors)+len(methods)+2)
 
 	// Find all used packages
 	usedPackages := make(map[string]bool)
 
-	// Check type declaration for used packages
-	ast.Inspect(typeDecl, func(n ast.Node) bool {
-		if sel, ok := n.(*ast.SelectorExpr); ok {
-			if ident, ok := sel.X.(*ast.Ident); ok {
-				usedPackages[ident.Name] = true
+	// Check type declaration and related consts/vars for used packages
+	for _, genDecl := range append([]*ast.GenDecl{typeDecl}, relatedDecls...) {
+		ast.Inspect(genDecl, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					usedPackages[ident.Name] = true
+				}
 			}
-		}
 
-		return true
-	})
+			return true
+		})
+	}
 
-	// Check methods for used packages
+	// Check constructors and methods for used packages
+	for _, fn := range constructors {
+		for pkg := range findUsedPackages(fn.FuncDecl) {
+			usedPackages[pkg] = true
+		}
+	}
 	for _, method := range methods {
 		for pkg := range findUsedPackages(method.FuncDecl) {
 			usedPackages[pkg] = true
@@ -397,22 +996,29 @@ func writeTypeWithMethods(filename string, typeDecl *ast.GenDecl, methods []Publ
 			usedImports = append(usedImports, imp)
 		}
 	}
+	relatedAstDecls := make([]ast.Decl, 0, len(relatedDecls))
+	for _, genDecl := range relatedDecls {
+		relatedAstDecls = append(relatedAstDecls, genDecl)
+	}
+	usedImports = dedupeImports(keepEmbedImport(relatedAstDecls, usedImports, imports))
 
 	if len(usedImports) > 0 {
-		importDecl := &ast.GenDecl{
-			Tok:   token.IMPORT,
-			Specs: make([]ast.Spec, len(usedImports)),
-		}
-		for i, imp := range usedImports {
-			importDecl.Specs[i] = imp
-		}
-		decls = append(decls, importDecl)
+		decls = append(decls, importDeclaration(usedImports))
 	}
 
 	// Add the type declaration
 	decls = append(decls, typeDecl)
+	for _, genDecl := range relatedDecls {
+		decls = append(decls, genDecl)
+	}
 
-	// Add all methods
+	// Add constructors right after the type, followed by all methods
+	for _, fn := range constructors {
+		if fn.Comments != nil {
+			fn.FuncDecl.Doc = fn.Comments
+		}
+		decls = append(decls, fn.FuncDecl)
+	}
 	for _, method := range methods {
 		if method.Comments != nil {
 			method.FuncDecl.Doc = method.Comments
@@ -420,26 +1026,80 @@ func writeTypeWithMethods(filename string, typeDecl *ast.GenDecl, methods []Publ
 		decls = append(decls, method.FuncDecl)
 	}
 
-	// Create an AST file
-	astFile := &ast.File{
-		Name:  &ast.Ident{Name: packageName},
-		Decls: decls,
+	// Add standalone comments of the type, and standalone and inline
+	// comments from constructors and methods
+	otherComments := append([]*ast.CommentGroup{}, typeComments...)
+	for _, fn := range constructors {
+		otherComments = append(otherComments, fn.StandaloneComments...)
+		otherComments = append(otherComments, fn.InlineComments...)
 	}
-
-	// Add comments from methods
-	var allComments []*ast.CommentGroup
 	for _, method := range methods {
-		allComments = append(allComments, method.StandaloneComments...)
-		allComments = append(allComments, method.InlineComments...)
+		otherComments = append(otherComments, method.StandaloneComments...)
+		otherComments = append(otherComments, method.InlineComments...)
 	}
-	if len(allComments) > 0 {
-		astFile.Comments = allComments
+
+	// Comments print by position, so the declarations are printed in source
+	// order and then moved into place: the type, its consts and vars, the
+	// constructors and the methods
+	sort.SliceStable(decls, func(i, j int) bool {
+		return !isImportDecl(decls[j]) && (isImportDecl(decls[i]) || decls[i].Pos() < decls[j].Pos())
+	})
+	astFile := &ast.File{
+		Name:     &ast.Ident{Name: packageName},
+		Decls:    decls,
+		Comments: fileComments(decls, otherComments),
 	}
 
-	// Format and write to file
-	if err := formatAndWriteFile(filename, astFile, fset); err != nil {
+	src, err := formatFile(provenance, astFile, fset)
+	if err != nil {
 		return err
 	}
+	src, err = reorderDeclarations(filename, src, func(decl ast.Decl) (int, string) {
+		rank, _ := declarationOrder(decl)
 
-	return nil
+		return rank, ""
+	})
+	if err != nil {
+		return err
+	}
+
+	// Methods split from other files of the package may already be there,
+	// unless the type is being split from that very file
+	if fset.Position(typeDecl.Specs[0].Pos()).Filename != filename {
+		existing, err := os.ReadFile(filename)
+		if err == nil {
+			return mergeSources(filename, src, existing)
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read existing file: %w", err)
+		}
+	}
+
+	return writeSource(filename, src)
+}
+
+// appendMethodsToFile writes methods to filename after the declarations it
+// already holds, like appendFunctionsToFile.
+func appendMethodsToFile(filename string, methods []PublicMethod, packageName string, imports []*ast.ImportSpec, fset *token.FileSet) error {
+	fns := make([]PublicFunction, 0, len(methods))
+	for _, method := range methods {
+		fns = append(fns, PublicFunction{
+			Name:               method.Name,
+			FuncDecl:           method.FuncDecl,
+			Comments:           method.Comments,
+			StandaloneComments: method.StandaloneComments,
+			InlineComments:     method.InlineComments,
+			Imports:            method.Imports,
+			Package:            method.Package,
+			Provenance:         method.Provenance,
+		})
+	}
+
+	return appendFunctionsToFile(filename, fns, packageName, imports, fset)
+}
+
+func isImportDecl(decl ast.Decl) bool {
+	genDecl, ok := decl.(*ast.GenDecl)
+
+	return ok && genDecl.Tok == token.IMPORT
 }
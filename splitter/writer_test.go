@@ -2,6 +2,7 @@ package splitter
 
 import (
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
@@ -150,7 +151,7 @@ func TestWriteMethodsWithStructs(t *testing.T) {
 	}
 
 	fset := token.NewFileSet()
-	if err := writeMethodsWithStructs(tmpDir, publicDecls, methods, "test", nil, fset); err != nil {
+	if err := writeMethodsWithStructs(Options{}, tmpDir, publicDecls, nil, methods, "test", nil, fset); err != nil {
 		t.Fatalf("writeMethodsWithStructs failed: %v", err)
 	}
 
@@ -238,7 +239,7 @@ func TestWriteTypeWithMethods(t *testing.T) {
 
 	outputFile := filepath.Join(tmpDir, "my_type.go")
 	fset := token.NewFileSet()
-	if err := writeTypeWithMethods(outputFile, typeDecl, methods, "test", nil, fset); err != nil {
+	if err := writeTypeWithMethods(outputFile, "", typeDecl, nil, nil, nil, methods, "test", nil, fset); err != nil {
 		t.Fatalf("writeTypeWithMethods failed: %v", err)
 	}
 
@@ -285,7 +286,7 @@ func TestFormatAndWriteFile(t *testing.T) {
 	}
 
 	outputFile := filepath.Join(tmpDir, "output.go")
-	if err := formatAndWriteFile(outputFile, astFile, fset); err != nil {
+	if err := formatAndWriteFile(outputFile, "", astFile, fset); err != nil {
 		t.Fatalf("formatAndWriteFile failed: %v", err)
 	}
 
@@ -307,3 +308,236 @@ func TestFormatAndWriteFile(t *testing.T) {
 		t.Error("Output file should contain function declaration")
 	}
 }
+
+func TestWriteFunctionDocCommentOnce(t *testing.T) {
+	src := `package test
+
+import "testing"
+
+// Padding is a standalone comment for Run.
+
+// Run runs things.
+func Run() {
+	// inline comment
+}
+
+// TestRun tests Run.
+func TestRun(t *testing.T) {
+	// inline test comment
+	Run()
+}
+
+// TestPlain has only a doc comment.
+func TestPlain(t *testing.T) {}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	funcs := extractPublicFunctions(node, Options{}, fset)
+	tests := extractTestFunctions(node, fset)
+
+	outputs := map[string]string{
+		"Run":       filepath.Join(tmpDir, "run.go"),
+		"TestRun":   filepath.Join(tmpDir, "run_test.go"),
+		"TestPlain": filepath.Join(tmpDir, "plain_test.go"),
+	}
+	for _, fn := range funcs {
+		if fn.Name == "Run" {
+			if err := writePublicFunction(outputs[fn.Name], fn, fset); err != nil {
+				t.Fatalf("writePublicFunction failed: %v", err)
+			}
+		}
+	}
+	for _, test := range tests {
+		if err := writeTestFunction(outputs[test.Name], test, fset); err != nil {
+			t.Fatalf("writeTestFunction failed: %v", err)
+		}
+	}
+
+	expected := map[string][]string{
+		"Run":       {"// Run runs things.\nfunc Run() {", "// inline comment"},
+		"TestRun":   {"// TestRun tests Run.\nfunc TestRun(", "// inline test comment"},
+		"TestPlain": {"import \"testing\"\n\n// TestPlain has only a doc comment.\nfunc TestPlain("},
+	}
+	for name, wants := range expected {
+		content, err := os.ReadFile(outputs[name])
+		if err != nil {
+			t.Fatalf("Expected output for %s: %v", name, err)
+		}
+		for _, want := range wants {
+			if strings.Count(string(content), want) != 1 {
+				t.Errorf("%s output should contain %q exactly once, got:\n%s", name, want, content)
+			}
+		}
+	}
+}
+
+func TestWritersEmitEachCommentOnce(t *testing.T) {
+	src := `package test
+
+import "testing"
+
+// User is a user.
+type User struct {
+	// Name is the user's name.
+	Name string
+}
+
+// NewUser creates a user.
+func NewUser() *User {
+	// construct it
+	return &User{}
+}
+
+// GetName returns the name.
+func (u User) GetName() string {
+	// read the field
+	return u.Name
+}
+
+// TestGetName tests GetName.
+func TestGetName(t *testing.T) {
+	// check the name
+	_ = User{}.GetName()
+}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	funcs := extractPublicFunctions(node, Options{}, fset)
+	methods := extractPublicMethods(node, fset)
+	tests := extractTestFunctions(node, fset)
+	typeDecl, ok := node.Decls[1].(*ast.GenDecl)
+	if !ok {
+		t.Fatal("Expected GenDecl")
+	}
+
+	writes := map[string]func(string) error{
+		"functions": func(f string) error { return writeFunctionsToFile(f, funcs, "test", node.Imports, fset) },
+		"method":    func(f string) error { return writePublicMethod(f, methods[0], fset) },
+		"tests":     func(f string) error { return writeTestsToFile(f, tests, fset) },
+		"type": func(f string) error {
+			return writeTypeWithMethods(f, "", typeDecl, nil, nil, funcs, methods, "test", node.Imports, fset)
+		},
+	}
+	expected := map[string][]string{
+		"functions": {"// NewUser creates a user.", "// construct it"},
+		"method":    {"// GetName returns the name.", "// read the field"},
+		"tests":     {"// TestGetName tests GetName.", "// check the name"},
+		"type": {
+			"// User is a user.", "// Name is the user's name.",
+			"// NewUser creates a user.", "// construct it",
+			"// GetName returns the name.", "// read the field",
+		},
+	}
+
+	for name, write := range writes {
+		outputFile := filepath.Join(tmpDir, name+".go")
+		if err := write(outputFile); err != nil {
+			t.Fatalf("%s: write failed: %v", name, err)
+		}
+
+		content, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written, err := parser.ParseFile(token.NewFileSet(), outputFile, content, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("%s: output does not parse: %v\n%s", name, err, content)
+		}
+
+		for _, text := range expected[name] {
+			count := 0
+			for _, cg := range written.Comments {
+				for _, c := range cg.List {
+					if c.Text == text {
+						count++
+					}
+				}
+			}
+			if count != 1 {
+				t.Errorf("%s: comment %q appears %d times, want 1:\n%s", name, text, count, content)
+			}
+		}
+	}
+}
+
+func TestWritePublicFunctionKeepsSignatures(t *testing.T) {
+	src := `package sig
+
+import (
+	"context"
+	"io"
+)
+
+// Sum adds its arguments.
+func Sum(base int, rest ...int) (total int) {
+	total = base
+	for _, n := range rest {
+		total += n
+	}
+
+	return total
+}
+
+// Copy copies with named results.
+func Copy(ctx context.Context, dst io.Writer, src io.Reader) (written int64, err error) {
+	return io.Copy(dst, src)
+}
+
+// Split returns several results.
+func Split(s string, seps ...rune) ([]string, int, error) {
+	return nil, len(seps), nil
+}
+
+// Apply takes a variadic func parameter.
+func Apply[T any](v T, fns ...func(T) (T, error)) (out T, err error) {
+	out = v
+	for _, fn := range fns {
+		if out, err = fn(out); err != nil {
+			return
+		}
+	}
+
+	return
+}
+`
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "sig.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	for _, fn := range extractPublicFunctions(node, Options{}, fset) {
+		// The written function must match the source byte for byte
+		want := src[fset.Position(fn.Comments.Pos()).Offset:fset.Position(fn.FuncDecl.End()).Offset]
+
+		outputFile := filepath.Join(tmpDir, functionNameToSnakeCase(fn.Name)+".go")
+		if err := writePublicFunction(outputFile, fn, fset); err != nil {
+			t.Fatalf("writePublicFunction(%s) failed: %v", fn.Name, err)
+		}
+
+		content, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s output should contain the function unchanged:\n%s\ngot:\n%s", fn.Name, want, content)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), outputFile, content, 0); err != nil {
+			t.Errorf("%s output does not parse: %v", fn.Name, err)
+		}
+	}
+}